@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/mattn/go-sqlite3"
 	"gorm.io/driver/sqlite"
@@ -23,19 +24,31 @@ var (
 )
 
 func init() {
-	db, err := gorm.Open(sqlite.Open(dburl+"?_fk=1"), &gorm.Config{})
+	// foreign keys are off while the schema migrates, sqlite rebuilds a table to change its constraints
+	// and dropping the old table would cascade to the rows referencing it
+	db, err := gorm.Open(sqlite.Open(dburl), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database")
 	}
-
-	if err := db.Exec("PRAGMA foreign_keys = ON", nil).Error; err != nil {
-		log.Fatalf("failed to enable foreign key support: %v", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic("failed to connect database")
 	}
+	// the pragmas apply to a single connection
+	sqlDB.SetMaxOpenConns(1)
 
 	err = db.AutoMigrate(models.MigrateModels...)
 	if err != nil {
 		panic("failed to migrate database")
 	}
+
+	if err := db.Exec("PRAGMA foreign_keys = ON", nil).Error; err != nil {
+		log.Fatalf("failed to enable foreign key support: %v", err)
+	}
+
+	if err := repositories.Migrate(db); err != nil {
+		log.Fatalf("failed to migrate data: %v", err)
+	}
 }
 
 type Service interface {
@@ -0,0 +1,277 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+)
+
+const (
+	containerPath = "META-INF/container.xml"
+	// the container and package documents are small, anything larger is not an epub we want to read
+	maxDocumentSize = 1 << 20
+)
+
+var (
+	ErrNotEPUB         = errors.New("the file is not an epub")
+	ErrMissingPackage  = errors.New("the epub has no package document")
+	ErrMissingTitle    = errors.New("the epub has no title")
+	ErrInvalidDocument = errors.New("the epub package document is invalid")
+)
+
+// Metadata is the book description read from the OPF package document
+type Metadata struct {
+	Title       string
+	Creators    []string
+	Identifiers []string
+	// ISBN is the first identifier that is a valid isbn, empty when there is none
+	ISBN        isbn.ISBN
+	Subjects    []string
+	Language    string
+	Description string
+	Cover       []byte
+	CoverType   string
+}
+
+// Authors joins the creators the way providers format them
+func (m *Metadata) Authors() string {
+	return strings.Join(m.Creators, ", ")
+}
+
+// CoverDataURI returns the cover as a data uri, empty when the epub has no cover
+func (m *Metadata) CoverDataURI() string {
+	if len(m.Cover) == 0 {
+		return ""
+	}
+	return "data:" + m.CoverType + ";base64," + base64.StdEncoding.EncodeToString(m.Cover)
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles   []string `xml:"title"`
+		Creators []struct {
+			ID    string `xml:"id,attr"`
+			Role  string `xml:"role,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"creator"`
+		Identifiers  []string `xml:"identifier"`
+		Subjects     []string `xml:"subject"`
+		Languages    []string `xml:"language"`
+		Descriptions []string `xml:"description"`
+		Metas        []struct {
+			Name     string `xml:"name,attr"`
+			Content  string `xml:"content,attr"`
+			Property string `xml:"property,attr"`
+			Refines  string `xml:"refines,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// Parse reads the metadata of an epub, covers larger than maxCoverSize are left out
+func Parse(r io.ReaderAt, size int64, maxCoverSize int) (*Metadata, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrNotEPUB
+	}
+
+	var c container
+	if err := decodeFile(archive, containerPath, &c); err != nil {
+		return nil, err
+	}
+	packagePath := ""
+	for _, rootfile := range c.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			packagePath = rootfile.FullPath
+			break
+		}
+	}
+	if packagePath == "" {
+		return nil, ErrMissingPackage
+	}
+
+	var opf opfPackage
+	if err := decodeFile(archive, packagePath, &opf); err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{
+		Title:       first(opf.Metadata.Titles),
+		Language:    first(opf.Metadata.Languages),
+		Description: stripTags(first(opf.Metadata.Descriptions)),
+	}
+	if metadata.Title == "" {
+		return nil, ErrMissingTitle
+	}
+
+	// epub 3 keeps the creator roles in meta elements refining the creator
+	roles := map[string]string{}
+	coverID := ""
+	for _, meta := range opf.Metadata.Metas {
+		if meta.Property == "role" && strings.HasPrefix(meta.Refines, "#") {
+			roles[strings.TrimPrefix(meta.Refines, "#")] = strings.TrimSpace(meta.Value)
+		}
+		if meta.Name == "cover" {
+			coverID = meta.Content
+		}
+	}
+	for _, creator := range opf.Metadata.Creators {
+		role := creator.Role
+		if role == "" {
+			role = roles[creator.ID]
+		}
+		name := strings.TrimSpace(creator.Value)
+		// illustrators, editors and translators are creators too, only the authors are kept
+		if name == "" || (role != "" && role != "aut") {
+			continue
+		}
+		metadata.Creators = append(metadata.Creators, name)
+	}
+
+	for _, identifier := range opf.Metadata.Identifiers {
+		value := strings.TrimSpace(identifier)
+		if value == "" {
+			continue
+		}
+		metadata.Identifiers = append(metadata.Identifiers, value)
+		if metadata.ISBN == "" {
+			if parsed, err := isbn.Parse(trimISBNPrefix(value)); err == nil {
+				metadata.ISBN = parsed
+			}
+		}
+	}
+
+	for _, subject := range opf.Metadata.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			metadata.Subjects = append(metadata.Subjects, subject)
+		}
+	}
+
+	coverHref, coverType := "", ""
+	for _, item := range opf.Manifest {
+		isCover := strings.Contains(" "+item.Properties+" ", " cover-image ") || (coverID != "" && item.ID == coverID)
+		if isCover && strings.HasPrefix(item.MediaType, "image/") {
+			coverHref, coverType = item.Href, item.MediaType
+			break
+		}
+	}
+	if coverHref != "" {
+		// hrefs are relative to the package document and may be url encoded
+		if unescaped, err := url.PathUnescape(coverHref); err == nil {
+			coverHref = unescaped
+		}
+		cover, err := readFile(archive, path.Join(path.Dir(packagePath), coverHref), maxCoverSize)
+		if err == nil {
+			metadata.Cover, metadata.CoverType = cover, coverType
+		}
+	}
+
+	return metadata, nil
+}
+
+func decodeFile(archive *zip.Reader, name string, v any) error {
+	data, err := readFile(archive, name, maxDocumentSize)
+	if errors.Is(err, errFileTooLarge) {
+		return fmt.Errorf("%w: %s is too large", ErrInvalidDocument, name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDocument, name)
+	}
+	return nil
+}
+
+var errFileTooLarge = errors.New("file is too large")
+
+func readFile(archive *zip.Reader, name string, limit int) ([]byte, error) {
+	file, err := archive.Open(name)
+	if err != nil {
+		if name == containerPath {
+			return nil, ErrNotEPUB
+		}
+		return nil, ErrMissingPackage
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(limit)+1))
+	if err != nil {
+		// a corrupted archive fails while it is decompressed
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocument, name)
+	}
+	if len(data) > limit {
+		return nil, errFileTooLarge
+	}
+	return data, nil
+}
+
+func first(values []string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// trimISBNPrefix removes the "urn:isbn:" and "isbn:" prefixes epubs put before the isbn
+func trimISBNPrefix(value string) string {
+	lower := strings.ToLower(value)
+	for _, prefix := range []string{"urn:isbn:", "isbn:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return value[len(prefix):]
+		}
+	}
+	return value
+}
+
+// stripTags removes the html markup descriptions are often written in, block elements become spaces
+func stripTags(s string) string {
+	var b, tag strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+			tag.Reset()
+		case r == '>' && inTag:
+			inTag = false
+			fields := strings.Fields(tag.String())
+			if len(fields) == 0 {
+				continue
+			}
+			name := strings.ToLower(strings.TrimLeft(fields[0], "/"))
+			if slices.Contains([]string{"p", "br", "br/", "div", "li", "h1", "h2", "h3", "h4"}, name) {
+				b.WriteRune(' ')
+			}
+		case inTag:
+			tag.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -41,6 +41,12 @@ var (
 			SetInternal(toast)
 	}
 
+	HttpErrorServiceUnavailable = func(err error) *echo.HTTPError {
+		toast := toast.Warning(err.Error())
+		return echo.NewHTTPError(http.StatusServiceUnavailable, toast).
+			SetInternal(toast)
+	}
+
 	HttpErrorForbidden = func(err error) *echo.HTTPError {
 		toast := toast.Warning(err.Error())
 		return echo.NewHTTPError(http.StatusForbidden, toast).
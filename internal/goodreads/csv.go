@@ -0,0 +1,151 @@
+package goodreads
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ShelfRead             = "read"
+	ShelfCurrentlyReading = "currently-reading"
+	ShelfToRead           = "to-read"
+	ShelfDidNotFinish     = "did-not-finish"
+	ShelfOnHold           = "on-hold"
+
+	dateLayout = "2006/01/02"
+)
+
+var (
+	ErrEmptyFile     = errors.New("the file is empty")
+	ErrMissingColumn = errors.New("the file is not a goodreads export, missing column")
+)
+
+// Row is a single book of a Goodreads library export
+type Row struct {
+	// Line is the line of the row in the file, the header is line 1
+	Line        int
+	GoodreadsID string
+	Title       string
+	Author      string
+	// AdditionalAuthors are the co-authors separated with ", "
+	AdditionalAuthors string
+	ISBN              string
+	ISBN13            string
+	Pages             int
+	YearPublished     string
+	Rating            int
+	DateRead          *time.Time
+	DateAdded         *time.Time
+	ExclusiveShelf    string
+}
+
+// SearchTitle drops the series suffix Goodreads appends to titles, "Dune (Dune Chronicles, #1)" becomes "Dune"
+func (r Row) SearchTitle() string {
+	if i := strings.LastIndex(r.Title, " ("); i > 0 && strings.HasSuffix(r.Title, ")") {
+		return strings.TrimSpace(r.Title[:i])
+	}
+	return strings.TrimSpace(r.Title)
+}
+
+// Parse reads a Goodreads "Export Library" csv file
+func Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	if _, ok := columns["Title"]; !ok {
+		return nil, fmt.Errorf("%w %q", ErrMissingColumn, "Title")
+	}
+	_, hasAuthor := columns["Author"]
+	if _, hasLastFirst := columns["Author l-f"]; !hasAuthor && !hasLastFirst {
+		return nil, fmt.Errorf("%w %q", ErrMissingColumn, "Author")
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		// reviews can span several lines, the position of the first field is the line of the row
+		line, _ := reader.FieldPos(0)
+		row := Row{
+			Line:              line,
+			GoodreadsID:       value("Book Id"),
+			Title:             value("Title"),
+			Author:            value("Author"),
+			AdditionalAuthors: value("Additional Authors"),
+			YearPublished:     value("Year Published"),
+			ISBN:              unquoteISBN(value("ISBN")),
+			ISBN13:            unquoteISBN(value("ISBN13")),
+			ExclusiveShelf:    value("Exclusive Shelf"),
+			DateRead:          parseDate(value("Date Read")),
+			DateAdded:         parseDate(value("Date Added")),
+		}
+		if row.Author == "" {
+			row.Author = firstLast(value("Author l-f"))
+		}
+		row.Pages, _ = strconv.Atoi(value("Number of Pages"))
+		row.Rating, _ = strconv.Atoi(value("My Rating"))
+		if row.Title == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// firstLast turns the "Last, First" name of the "Author l-f" column around
+func firstLast(author string) string {
+	last, first, ok := strings.Cut(author, ",")
+	if !ok || strings.TrimSpace(first) == "" {
+		return strings.TrimSpace(author)
+	}
+	return strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+}
+
+// unquoteISBN strips the ="..." wrapper Goodreads puts around isbns so spreadsheets keep the leading zeros
+func unquoteISBN(s string) string {
+	s = strings.TrimPrefix(s, "=")
+	return strings.Trim(s, "\"")
+}
+
+func parseDate(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,104 @@
+package goodreads
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header is the column layout of a Goodreads "Export Library" file, Goodreads imports files with these columns
+var Header = []string{
+	"Book Id", "Title", "Author", "Author l-f", "Additional Authors", "ISBN", "ISBN13",
+	"My Rating", "Average Rating", "Publisher", "Binding", "Number of Pages", "Year Published",
+	"Original Publication Year", "Date Read", "Date Added", "Bookshelves", "Bookshelves with positions",
+	"Exclusive Shelf", "My Review", "Spoiler", "Private Notes", "Read Count", "Owned Copies",
+}
+
+// Writer writes rows in the Goodreads export format
+type Writer struct {
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{csv: csv.NewWriter(w)}
+}
+
+func (w *Writer) Write(row Row) error {
+	if !w.headerWritten {
+		if err := w.csv.Write(Header); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	readCount := "0"
+	if row.ExclusiveShelf == ShelfRead {
+		readCount = "1"
+	}
+	record := map[string]string{
+		"Book Id":            row.GoodreadsID,
+		"Title":              row.Title,
+		"Author":             row.Author,
+		"Author l-f":         lastFirst(row.Author),
+		"Additional Authors": row.AdditionalAuthors,
+		"ISBN":               quoteISBN(row.ISBN),
+		"ISBN13":             quoteISBN(row.ISBN13),
+		"My Rating":          strconv.Itoa(row.Rating),
+		"Number of Pages":    positive(row.Pages),
+		"Year Published":     row.YearPublished,
+		"Date Read":          formatDate(row.DateRead),
+		"Date Added":         formatDate(row.DateAdded),
+		"Bookshelves":        row.ExclusiveShelf,
+		"Exclusive Shelf":    row.ExclusiveShelf,
+		"Read Count":         readCount,
+		"Owned Copies":       "0",
+	}
+	values := make([]string, len(Header))
+	for i, column := range Header {
+		values[i] = record[column]
+	}
+	return w.csv.Write(values)
+}
+
+// Flush writes the buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	if !w.headerWritten {
+		if err := w.csv.Write(Header); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// quoteISBN wraps the isbn the way Goodreads does so spreadsheets keep the leading zeros
+func quoteISBN(s string) string {
+	return "=\"" + s + "\""
+}
+
+// lastFirst turns "Frank Herbert" into "Herbert, Frank"
+func lastFirst(author string) string {
+	i := strings.LastIndex(author, " ")
+	if i < 0 {
+		return author
+	}
+	return author[i+1:] + ", " + author[:i]
+}
+
+func positive(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
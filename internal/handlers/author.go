@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	webAuthors "github.com/FilipBudzynski/book_it/cmd/web/authors"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type AuthorService interface {
+	Get(id string) (*models.Author, error)
+	GetByName(name string) (*models.Author, error)
+	NearbyOffers(author *models.Author, user *models.User) ([]*models.BookOffer, error)
+}
+
+type AuthorHandler struct {
+	authorService    AuthorService
+	userService      UserService
+	userBooksService UserBookService
+}
+
+func NewAuthorHandler(authorService AuthorService, userBookService UserBookService, userService UserService) *AuthorHandler {
+	return &AuthorHandler{
+		authorService:    authorService,
+		userBooksService: userBookService,
+		userService:      userService,
+	}
+}
+
+func (h *AuthorHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/authors")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("", h.FindByName)
+	group.GET("/:id", h.Get)
+}
+
+// FindByName sends the links built from Book.Authors to the author's page
+func (h *AuthorHandler) FindByName(c echo.Context) error {
+	author, err := h.authorService.GetByName(c.QueryParam("name"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/authors/%d", author.ID))
+}
+
+func (h *AuthorHandler) Get(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	author, err := h.authorService.Get(c.Param("id"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	user, err := h.userService.GetByGoogleID(userID)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	userBooks, err := h.userBooksService.GetAll(userID)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	offers, err := h.authorService.NearbyOffers(author, user)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+
+	return utils.RenderView(c, webAuthors.AuthorPage(author, userBooks, offers, user.Location != nil))
+}
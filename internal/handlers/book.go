@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,6 +10,7 @@ import (
 	webUser "github.com/FilipBudzynski/book_it/cmd/web/user"
 	"github.com/FilipBudzynski/book_it/internal/errs"
 	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
 	"github.com/FilipBudzynski/book_it/utils"
 	"github.com/labstack/echo/v4"
 )
@@ -15,17 +18,43 @@ import (
 type BookService interface {
 	Create(book *models.Book) error
 	Delete(bookID string) error
-	GetByQuery(query string, queryType QueryType, page int) ([]*models.Book, error)
-	GetByID(id string) (*models.Book, error)
-	FetchReccomendations(genres []models.Genre, userBooks []*models.UserBook) ([]*models.Book, error)
+	GetByQuery(ctx context.Context, query SearchQuery, filters models.SearchFilters, page int) ([]*models.Book, int, error)
+	SearchLocal(query string, filters models.SearchFilters, page int) ([]*models.Book, int, error)
+	GetByID(ctx context.Context, id string) (*models.Book, error)
+	GetByISBN(ctx context.Context, isbn string) (*models.Book, error)
+	GetEditions(workID uint) ([]*models.Book, error)
+	CreateManual(userID string, input *models.ManualBook) (*models.Book, error)
+	LinkManualBook(ctx context.Context, userID, manualID, target string) (*models.Book, error)
+	FetchReccomendations(ctx context.Context, genres []models.Genre, userBooks []*models.UserBook, feedback []*models.RecommendationFeedback, page int) ([]*models.Recommendation, error)
 	WithProvider(provider BookProvider) BookService
+	WithSimilarBooks(similarBooks SimilarBooks, weight float64) BookService
+	WithSeries(series SeriesVolumes) BookService
+	WithCoverDir(dir string) BookService
 	Provider() BookProvider
 }
 
+// SimilarBooks reads the collaborative filter table, the books shelved together by the same readers
+type SimilarBooks interface {
+	GetForBooks(bookIDs []string) ([]models.BookSimilarity, error)
+}
+
+// SeriesVolumes finds the volume to read next in the series of the user's library
+type SeriesVolumes interface {
+	NextVolumes(userBooks []*models.UserBook) ([]*models.NextVolume, error)
+}
+
+// RecommendationFeedbackService keeps the recommendations a user turned down
+type RecommendationFeedbackService interface {
+	Save(userID, bookID string, kind models.FeedbackKind) error
+	GetAll(userID string) ([]*models.RecommendationFeedback, error)
+}
+
+// BookProvider calls an external catalogue, the context cancels the upstream request
+// when the client goes away
 type BookProvider interface {
-	GetBook(id string) (*models.Book, error)
-	GetBooksByQuery(query string, queryType QueryType, limit, page int) ([]*models.Book, error)
-	GetBooksByGenre(genre string) ([]*models.Book, error)
+	GetBook(ctx context.Context, id string) (*models.Book, error)
+	GetBooksByQuery(ctx context.Context, query SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error)
+	GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error)
 	QueryTypeToString(queryType QueryType) string
 	Convert(response any) *models.Book
 }
@@ -34,8 +63,15 @@ type BookHandler struct {
 	bookService      BookService
 	userService      UserService
 	userBooksService UserBookService
+	feedbackService  RecommendationFeedbackService
 }
 
+// SearchSourceLocal limits the search to the books saved in the database
+const SearchSourceLocal = "local"
+
+// RecommendationsPageSize is how many recommendations a page of the carousel loads
+const RecommendationsPageSize = 30
+
 func NewBookHandler(bookService BookService, userBookService UserBookService, userService UserService) *BookHandler {
 	return &BookHandler{
 		bookService:      bookService,
@@ -44,6 +80,12 @@ func NewBookHandler(bookService BookService, userBookService UserBookService, us
 	}
 }
 
+// WithFeedback lets users turn recommendations down, without it every book stays recommended
+func (h *BookHandler) WithFeedback(feedbackService RecommendationFeedbackService) *BookHandler {
+	h.feedbackService = feedbackService
+	return h
+}
+
 func (h *BookHandler) RegisterRoutes(app *echo.Echo) {
 	group := app.Group("/books")
 	group.GET("", h.ListBooks)
@@ -51,6 +93,13 @@ func (h *BookHandler) RegisterRoutes(app *echo.Echo) {
 	group.GET("/reduced/search", h.ReducedSearch)
 	group.GET("/partial", h.BooksPartial)
 	group.GET("/recommendations", h.Recommend)
+	if h.feedbackService != nil {
+		group.POST("/recommendations/:id/feedback", h.RecommendationFeedback, utils.CheckLoggedInMiddleware)
+	}
+	group.GET("/new", h.NewManualBook, utils.CheckLoggedInMiddleware)
+	group.POST("/new", h.CreateManualBook, utils.CheckLoggedInMiddleware)
+	group.GET("/:id/link", h.LinkManualBookModal, utils.CheckLoggedInMiddleware)
+	group.POST("/:id/link", h.LinkManualBook, utils.CheckLoggedInMiddleware)
 }
 
 func (h *BookHandler) ListBooks(c echo.Context) error {
@@ -58,32 +107,50 @@ func (h *BookHandler) ListBooks(c echo.Context) error {
 		return utils.RenderView(c, web_books.BooksSearch())
 	}
 
-	query := c.FormValue("query")
-	queryType := c.FormValue("type")
+	query := ParseSearchQuery(c.FormValue("query"), stringToQueryType(c.FormValue("type")))
+	source := c.FormValue("source")
+	filters, err := bindSearchFilters(c)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
 
-	books, userBooks, err := h.getBooksAndUserData(c, query, queryType, 1)
+	books, next, userBooks, err := h.getBooksAndUserData(c, query, source, filters, 1)
+	if errors.Is(err, models.ErrProviderUnavailable) {
+		return errs.HttpErrorServiceUnavailable(models.ErrProviderUnavailable)
+	}
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
-	return utils.RenderView(c, web_books.BooksPost(books, userBooks, 2, query))
+	return utils.RenderView(c, web_books.BooksPost(books, userBooks, next, query.String(), source, filters))
 }
 
 func (h *BookHandler) BooksPartial(c echo.Context) error {
-	query := c.QueryParam("query")
+	// the next page links carry the query written with field prefixes, see SearchQuery.String
+	query := ParseSearchQuery(c.QueryParam("query"), QueryTypeText)
 	page, err := strconv.Atoi(c.QueryParam("page"))
 	if err != nil || page < 1 {
 		page = 1
 	}
 
-	books, userBooks, err := h.getBooksAndUserData(c, query, "title", page)
+	source := c.QueryParam("source")
+	filters, err := bindSearchFilters(c)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	books, next, userBooks, err := h.getBooksAndUserData(c, query, source, filters, page)
+	if errors.Is(err, models.ErrProviderUnavailable) {
+		return errs.HttpErrorServiceUnavailable(models.ErrProviderUnavailable)
+	}
 	if err != nil {
 		return err
 	}
-	if len(books) == 0 {
+	// a page emptied by the filters still carries the link to the next one
+	if len(books) == 0 && next == 0 {
 		return c.NoContent(http.StatusNoContent)
 	}
 
-	return utils.RenderView(c, web_books.BooksPost(books, userBooks, page+1, query))
+	return utils.RenderView(c, web_books.BooksPost(books, userBooks, next, query.String(), source, filters))
 }
 
 func (h *BookHandler) List(c echo.Context) error {
@@ -92,7 +159,10 @@ func (h *BookHandler) List(c echo.Context) error {
 
 func (h *BookHandler) ReducedSearch(c echo.Context) error {
 	query := c.FormValue("book-title")
-	books, err := h.bookService.GetByQuery(query, QueryTypeTitle, 1)
+	books, _, err := h.bookService.GetByQuery(c.Request().Context(), NewSearchQuery(query, QueryTypeTitle), models.SearchFilters{}, 1)
+	if errors.Is(err, models.ErrProviderUnavailable) {
+		return errs.HttpErrorServiceUnavailable(models.ErrProviderUnavailable)
+	}
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
@@ -106,43 +176,140 @@ func (h *BookHandler) Recommend(c echo.Context) error {
 		return errs.HttpErrorUnauthorized(err)
 	}
 
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
 	user, err := h.userService.GetByGoogleID(userID)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
+	userBooks, err := h.userBooksService.GetAll(userID)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
 
-	if len(user.Genres) == 0 {
-		return utils.RenderView(c, webUser.Recommendations(nil))
+	if len(user.Genres) == 0 && len(userBooks) == 0 {
+		return utils.RenderView(c, webUser.Recommendations(nil, 0))
 	}
-	userBooks, err := h.userBooksService.GetAll(userID)
+	var feedback []*models.RecommendationFeedback
+	if h.feedbackService != nil {
+		if feedback, err = h.feedbackService.GetAll(userID); err != nil {
+			return errs.HttpErrorInternalServerError(err)
+		}
+	}
+	recommendations, err := h.bookService.FetchReccomendations(c.Request().Context(), user.Genres, userBooks, feedback, page)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
-	recommendedBooks, err := h.bookService.FetchReccomendations(user.Genres, userBooks)
+
+	// a full page means there may be more
+	nextPage := 0
+	if len(recommendations) == RecommendationsPageSize {
+		nextPage = page + 1
+	}
+	if page > 1 {
+		return utils.RenderView(c, webUser.RecommendationItems(recommendations, nextPage))
+	}
+	return utils.RenderView(c, webUser.Recommendations(recommendations, nextPage))
+}
+
+// RecommendationFeedback turns a recommendation down, the card is removed on the client
+func (h *BookHandler) RecommendationFeedback(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
 	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	kind, err := models.ParseFeedbackKind(c.FormValue("kind"))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	if err := h.feedbackService.Save(userID, c.Param("id"), kind); err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+
+	if kind == models.FeedbackAlreadyRead {
+		_ = toast.Info("Got it, we will count it as read").SetHXTriggerHeader(c)
+	} else {
+		_ = toast.Info("We will not recommend it again").SetHXTriggerHeader(c)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *BookHandler) NewManualBook(c echo.Context) error {
+	return utils.RenderView(c, web_books.ManualBookForm())
+}
+
+func (h *BookHandler) CreateManualBook(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	input, err := bindManualBook(c)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	book, err := h.bookService.CreateManual(userID, input)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	if err := h.userBooksService.Create(userID, book.ID); err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
 
-	return utils.RenderView(c, webUser.Recommendations(recommendedBooks))
+	_ = toast.Success(c, "Book added!")
+	return utils.RenderView(c, web_books.ManualBookCreated(book))
 }
 
-func (h *BookHandler) getBooksAndUserData(c echo.Context, query, queryTypeString string, page int) ([]*models.Book, []*models.UserBook, error) {
-	queryType := stringToQueryType(queryTypeString)
+func (h *BookHandler) LinkManualBookModal(c echo.Context) error {
+	book, err := h.bookService.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	return utils.RenderView(c, web_books.LinkBookModal(book))
+}
+
+func (h *BookHandler) LinkManualBook(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	book, err := h.bookService.LinkManualBook(c.Request().Context(), userID, c.Param("id"), c.FormValue("target"))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	_ = toast.Success(c, "Book linked!")
+	return utils.RenderView(c, web_books.LinkBookDone(book))
+}
 
-	books, err := h.bookService.GetByQuery(query, queryType, page)
+func (h *BookHandler) getBooksAndUserData(c echo.Context, query SearchQuery, source string, filters models.SearchFilters, page int) ([]*models.Book, int, []*models.UserBook, error) {
+	var books []*models.Book
+	var next int
+	var err error
+	if source == SearchSourceLocal {
+		books, next, err = h.bookService.SearchLocal(query.Words(), filters, page)
+	} else {
+		books, next, err = h.bookService.GetByQuery(c.Request().Context(), query, filters, page)
+	}
 	if err != nil {
-		return nil, nil, err
+		return nil, 0, nil, err
 	}
 
 	userID, err := utils.GetUserIDFromSession(c.Request())
 	if err != nil {
-		return nil, nil, err
+		return nil, 0, nil, err
 	}
 
 	userBooks, err := h.userBooksService.GetAll(userID)
 	if err != nil {
-		return nil, nil, err
+		return nil, 0, nil, err
 	}
 
-	return books, userBooks, nil
+	return books, next, userBooks, nil
 }
@@ -1,5 +1,18 @@
 package handlers
 
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
 type QueryType int
 
 const (
@@ -7,6 +20,8 @@ const (
 	QueryTypeAuthor
 	QueryTypeSubject
 	QueryTypeISBN
+	// QueryTypeText matches the words in any field
+	QueryTypeText
 )
 
 func stringToQueryType(queryType string) QueryType {
@@ -19,7 +34,119 @@ func stringToQueryType(queryType string) QueryType {
 		return QueryTypeSubject
 	case "isbn":
 		return QueryTypeISBN
+	case "text":
+		return QueryTypeText
 	default:
 		return QueryTypeTitle
 	}
 }
+
+var ErrInvalidSearchFilter = errors.New("invalid search filter")
+
+// bindSearchFilters reads the filters of the search form, empty fields leave the filter off
+func bindSearchFilters(c echo.Context) (models.SearchFilters, error) {
+	filters := models.SearchFilters{
+		Language:  models.NormalizeLanguage(c.FormValue("language")),
+		PrintType: models.PrintType(c.FormValue("print-type")),
+		OrderBy:   models.SearchOrder(c.FormValue("order-by")),
+	}
+
+	switch filters.PrintType {
+	case models.PrintTypeAll, models.PrintTypeBooks, models.PrintTypeMagazines:
+	default:
+		return filters, fmt.Errorf("%w: print type %q", ErrInvalidSearchFilter, filters.PrintType)
+	}
+	switch filters.OrderBy {
+	case models.SearchOrderRelevance, models.SearchOrderNewest:
+	default:
+		return filters, fmt.Errorf("%w: order %q", ErrInvalidSearchFilter, filters.OrderBy)
+	}
+
+	for field, target := range map[string]*int{
+		"published-from": &filters.PublishedFrom,
+		"published-to":   &filters.PublishedTo,
+		"min-pages":      &filters.MinPages,
+	} {
+		value := strings.TrimSpace(c.FormValue(field))
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filters, fmt.Errorf("%w: %s must be a positive number", ErrInvalidSearchFilter, field)
+		}
+		*target = n
+	}
+	if filters.PublishedFrom > 0 && filters.PublishedTo > 0 && filters.PublishedFrom > filters.PublishedTo {
+		return filters, fmt.Errorf("%w: the published range ends before it starts", ErrInvalidSearchFilter)
+	}
+
+	return filters, nil
+}
+
+func bindManualBook(c echo.Context) (*models.ManualBook, error) {
+	input := &models.ManualBook{
+		Title:   c.FormValue("title"),
+		Authors: c.FormValue("authors"),
+		ISBN:    c.FormValue("isbn"),
+		Cover:   strings.TrimSpace(c.FormValue("cover-url")),
+	}
+
+	if pages := strings.TrimSpace(c.FormValue("pages")); pages != "" {
+		n, err := strconv.Atoi(pages)
+		if err != nil {
+			return nil, err
+		}
+		input.Pages = n
+	}
+
+	for _, genre := range strings.Split(c.FormValue("genres"), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			input.Genres = append(input.Genres, genre)
+		}
+	}
+
+	cover, err := coverDataURI(c)
+	if err != nil {
+		return nil, err
+	}
+	if cover != "" {
+		input.Cover = cover
+	}
+
+	return input, nil
+}
+
+// coverDataURI reads the uploaded cover into a data uri, the book service stores it as a file
+func coverDataURI(c echo.Context) (string, error) {
+	header, err := c.FormFile("cover-file")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if header.Size > models.ManualBookMaxCoverSize {
+		return "", models.ErrManualBookCoverTooLarge
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, models.ManualBookMaxCoverSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > models.ManualBookMaxCoverSize {
+		return "", models.ErrManualBookCoverTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", models.ErrManualBookInvalidCover
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
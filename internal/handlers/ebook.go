@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	web_books "github.com/FilipBudzynski/book_it/cmd/web/books"
+	"github.com/FilipBudzynski/book_it/internal/epub"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type EbookService interface {
+	Upload(ctx context.Context, userID, fileName string, data []byte, keep bool) (*models.Book, error)
+	File(userID, bookID string) (*models.EbookFile, error)
+}
+
+type EbookHandler struct {
+	ebookService EbookService
+}
+
+func NewEbookHandler(ebookService EbookService) *EbookHandler {
+	return &EbookHandler{
+		ebookService: ebookService,
+	}
+}
+
+func (h *EbookHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/ebooks")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("/upload", h.UploadForm)
+	group.POST("", h.Upload)
+	group.GET("/:book_id", h.Download)
+}
+
+func (h *EbookHandler) UploadForm(c echo.Context) error {
+	return utils.RenderView(c, web_books.EpubUploadForm())
+}
+
+func (h *EbookHandler) Upload(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	if fileHeader.Size > models.EbookMaxFileSize {
+		return errs.HttpErrorBadRequest(models.ErrEbookFileTooLarge)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, models.EbookMaxFileSize+1))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	keep := c.FormValue("keep-file") != ""
+	book, err := h.ebookService.Upload(c.Request().Context(), userID, fileHeader.Filename, data, keep)
+	if err != nil {
+		return ebookError(err)
+	}
+
+	_ = toast.Success(c, "Book added!")
+	return utils.RenderView(c, web_books.EpubUploaded(book, keep))
+}
+
+func (h *EbookHandler) Download(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	file, err := h.ebookService.File(userID, c.Param("book_id"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	return c.Attachment(file.Path, file.FileName)
+}
+
+// ebookError answers 400 for the files that cannot be read or give an invalid book and 500 for the rest
+func ebookError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrEbookFileTooLarge), errors.Is(err, models.ErrEbookStorageMissing),
+		errors.Is(err, epub.ErrNotEPUB), errors.Is(err, epub.ErrMissingPackage),
+		errors.Is(err, epub.ErrMissingTitle), errors.Is(err, epub.ErrInvalidDocument),
+		errors.Is(err, models.ErrManualBookTitleRequired), errors.Is(err, models.ErrManualBookAuthorsRequired),
+		errors.Is(err, models.ErrManualBookTooManyGenres), errors.Is(err, models.ErrManualBookInvalidCover),
+		errors.Is(err, models.ErrManualBookCoverTooLarge), errors.Is(err, models.ErrManualBookISBNExists):
+		return errs.HttpErrorBadRequest(err)
+	default:
+		return errs.HttpErrorInternalServerError(err)
+	}
+}
@@ -17,14 +17,14 @@ import (
 )
 
 type ExchangeService interface {
-	Create(userId, userEmail, desiredBookID string, userBookIDs []string, latitude, longitude float64) (*models.ExchangeRequest, error)
+	Create(userId, userEmail, desiredBookID string, userBookIDs []string, latitude, longitude float64, anyEdition bool) (*models.ExchangeRequest, error)
 	Get(id, userId string) (*models.ExchangeRequest, error)
 	GetAll(userId string) ([]*models.ExchangeRequest, error)
 	GetAllWithStatus(userId string, status models.ExchangeRequestStatus) ([]*models.ExchangeRequest, error)
 	Delete(id string) error
 	FindMatchingRequests(requestId, userId string) ([]*models.ExchangeRequest, error)
 
-	CreateMatch(request, otherRequest *models.ExchangeRequest) (*models.ExchangeMatch, error)
+	CreateMatch(request, otherRequest *models.ExchangeRequest, rule models.MatchRule) (*models.ExchangeMatch, error)
 	GetMatches(requestId string) ([]*models.ExchangeMatch, error)
 	GetMatchesDistanceFiltered(requestId string, distanceThreshold float64) ([]*models.ExchangeMatch, error)
 	AcceptMatch(requestId, matchedRequestId string) (*models.ExchangeMatch, error)
@@ -97,6 +97,7 @@ func (h *exchangeHandler) CreateExchange(c echo.Context) error {
 		exchangeBind.UserBookIDs,
 		lat,
 		lon,
+		exchangeBind.AnyEdition,
 	)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
@@ -179,7 +180,7 @@ func (h *exchangeHandler) GetPrefilledExchangeModal(c echo.Context) error {
 
 	bookID := c.QueryParam("book-id")
 
-	book, err := h.bookService.GetByID(bookID)
+	book, err := h.bookService.GetByID(c.Request().Context(), bookID)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
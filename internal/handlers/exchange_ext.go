@@ -20,6 +20,7 @@ type exchangeFormBinding struct {
 	UserBookIDs   []string
 	Latitude      string `form:"latitude"`
 	Longitude     string `form:"longitude"`
+	AnyEdition    bool
 }
 
 func (e *exchangeFormBinding) bind(c echo.Context) error {
@@ -35,6 +36,7 @@ func (e *exchangeFormBinding) bind(c echo.Context) error {
 			}
 		}
 	}
+	e.AnyEdition = c.FormValue("any-edition") != ""
 	e.UserBookIDs = []string{}
 	for i := 0; i <= 4; i++ {
 		fieldName := fmt.Sprintf("offered-book-%d", i)
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrExportUnknownFormat = errors.New("unknown export format, use csv or json")
+
+type ExportService interface {
+	WriteCSV(userID string, w io.Writer) error
+	WriteJSON(userID string, w io.Writer) error
+}
+
+type ExportHandler struct {
+	exportService ExportService
+}
+
+func NewExportHandler(exportService ExportService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+	}
+}
+
+func (h *ExportHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/export")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("", h.Export)
+}
+
+// Export streams the library as a download, ?format=csv gives a Goodreads compatible file and ?format=json everything
+func (h *ExportHandler) Export(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	format := c.QueryParam("format")
+	var write func(userID string, w io.Writer) error
+	var contentType string
+	switch format {
+	case "", "csv":
+		format, contentType, write = "csv", "text/csv; charset=utf-8", h.exportService.WriteCSV
+	case "json":
+		contentType, write = echo.MIMEApplicationJSONCharsetUTF8, h.exportService.WriteJSON
+	default:
+		return errs.HttpErrorBadRequest(ErrExportUnknownFormat)
+	}
+
+	fileName := fmt.Sprintf("book-it-library-%s.%s", time.Now().Format(time.DateOnly), format)
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+
+	if err := write(userID, c.Response()); err != nil {
+		if !c.Response().Committed {
+			return errs.HttpErrorInternalServerError(err)
+		}
+		// the response is already partly sent, the status can no longer change
+		log.Printf("export for %s failed: %v", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type HealthChecker interface {
+	Health() map[string]string
+}
+
+type HealthHandler struct {
+	database  HealthChecker
+	providers HealthChecker
+	cache     HealthChecker
+}
+
+func NewHealthHandler(database, providers, cache HealthChecker) *HealthHandler {
+	return &HealthHandler{
+		database:  database,
+		providers: providers,
+		cache:     cache,
+	}
+}
+
+func (h *HealthHandler) RegisterRoutes(app *echo.Echo) {
+	app.GET("/health", h.Health)
+}
+
+// Health reports the database, the book providers and the provider cache hits, the status is
+// "degraded" while a provider's circuit breaker is open since searching still works from the local catalogue.
+// The route is public, so the database only shows up or down and its pool stats stay private.
+func (h *HealthHandler) Health(c echo.Context) error {
+	database := h.database.Health()
+	providers := h.providers.Health()
+
+	status, code := "up", http.StatusOK
+	if providers["status"] != "up" {
+		status = "degraded"
+	}
+	if database["status"] != "up" {
+		status, code = "down", http.StatusServiceUnavailable
+	}
+
+	return c.JSON(code, map[string]any{
+		"status":    status,
+		"database":  database["status"],
+		"providers": providers,
+		"cache":     h.cache.Health(),
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	webHighlights "github.com/FilipBudzynski/book_it/cmd/web/highlights"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type HighlightService interface {
+	Create(userID, userBookID string, input *models.Highlight) (*models.Highlight, error)
+	Get(userID, id string) (*models.Highlight, error)
+	Update(userID, id string, input *models.Highlight) (*models.Highlight, error)
+	Delete(userID, id string) error
+	GetByUserBook(userID, userBookID string) ([]*models.Highlight, error)
+	Search(userID, query string) ([]*models.Highlight, error)
+}
+
+type HighlightHandler struct {
+	highlightService HighlightService
+	progressService  ProgressService
+}
+
+func NewHighlightHandler(highlightService HighlightService, progressService ProgressService) *HighlightHandler {
+	return &HighlightHandler{
+		highlightService: highlightService,
+		progressService:  progressService,
+	}
+}
+
+func (h *HighlightHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/highlights")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("", h.List)
+	group.GET("/search", h.Search)
+	group.GET("/user-books/:user_book_id", h.GetByUserBook)
+	group.POST("/user-books/:user_book_id", h.Create)
+	group.GET("/:id", h.Get)
+	group.GET("/:id/edit", h.Edit)
+	group.PUT("/:id", h.Update)
+	group.DELETE("/:id", h.Delete)
+}
+
+func (h *HighlightHandler) List(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	highlights, err := h.highlightService.Search(userID, "")
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	return utils.RenderView(c, webHighlights.SearchPage(highlights))
+}
+
+func (h *HighlightHandler) Search(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	highlights, err := h.highlightService.Search(userID, c.QueryParam("query"))
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	return utils.RenderView(c, webHighlights.SearchResults(highlights))
+}
+
+func (h *HighlightHandler) GetByUserBook(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+	return h.renderBookHighlights(c, userID, c.Param("user_book_id"))
+}
+
+func (h *HighlightHandler) Create(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	input, err := highlightFromForm(c)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	if _, err := h.highlightService.Create(userID, c.Param("user_book_id"), input); err != nil {
+		return highlightError(err)
+	}
+
+	_ = toast.Success(c, "Highlight saved!")
+	return h.renderBookHighlights(c, userID, c.Param("user_book_id"))
+}
+
+func (h *HighlightHandler) Get(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	highlight, err := h.highlightService.Get(userID, c.Param("id"))
+	if err != nil {
+		return highlightError(err)
+	}
+	return utils.RenderView(c, webHighlights.HighlightItem(highlight, false))
+}
+
+func (h *HighlightHandler) Edit(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	highlight, err := h.highlightService.Get(userID, c.Param("id"))
+	if err != nil {
+		return highlightError(err)
+	}
+	return utils.RenderView(c, webHighlights.HighlightEdit(highlight, h.journalLogs(strconv.Itoa(int(highlight.UserBookID)))))
+}
+
+func (h *HighlightHandler) Update(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	input, err := highlightFromForm(c)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	highlight, err := h.highlightService.Update(userID, c.Param("id"), input)
+	if err != nil {
+		return highlightError(err)
+	}
+	return utils.RenderView(c, webHighlights.HighlightItem(highlight, false))
+}
+
+func (h *HighlightHandler) Delete(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	if err := h.highlightService.Delete(userID, c.Param("id")); err != nil {
+		return highlightError(err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *HighlightHandler) renderBookHighlights(c echo.Context, userID, userBookID string) error {
+	highlights, err := h.highlightService.GetByUserBook(userID, userBookID)
+	if err != nil {
+		return highlightError(err)
+	}
+	userBook, err := strconv.Atoi(userBookID)
+	if err != nil {
+		return errs.HttpErrorBadRequest(models.ErrUserBookQueryWithoutId)
+	}
+	return utils.RenderView(c, webHighlights.BookHighlights(uint(userBook), highlights, h.journalLogs(userBookID)))
+}
+
+// journalLogs returns the days of the book's reading journal up to today, a highlight can be captured on them
+func (h *HighlightHandler) journalLogs(userBookID string) []models.DailyProgressLog {
+	progress, err := h.progressService.GetByUserBookId(userBookID)
+	if err != nil {
+		return nil
+	}
+	logs := []models.DailyProgressLog{}
+	for _, log := range progress.DailyProgress {
+		if !log.Date.After(utils.TodaysDate()) {
+			logs = append(logs, log)
+		}
+	}
+	return logs
+}
+
+func highlightFromForm(c echo.Context) (*models.Highlight, error) {
+	input := &models.Highlight{
+		Text: c.FormValue("text"),
+		Note: c.FormValue("note"),
+		Tags: c.FormValue("tags"),
+	}
+	if page := c.FormValue("page"); page != "" {
+		parsed, err := strconv.Atoi(page)
+		if err != nil {
+			return nil, models.ErrHighlightInvalidPage
+		}
+		input.Page = &parsed
+	}
+	if logID := c.FormValue("log-id"); logID != "" {
+		parsed, err := strconv.ParseUint(logID, 10, 64)
+		if err != nil {
+			return nil, models.ErrHighlightLogNotOfBook
+		}
+		id := uint(parsed)
+		input.DailyProgressLogID = &id
+	}
+	return input, nil
+}
+
+func highlightError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrHighlightNotOwner):
+		return errs.HttpErrorForbidden(err)
+	case errors.Is(err, models.ErrHighlightTextEmpty), errors.Is(err, models.ErrHighlightInvalidPage), errors.Is(err, models.ErrHighlightLogNotOfBook):
+		return errs.HttpErrorBadRequest(err)
+	default:
+		return errs.HttpErrorNotFound(err)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	webImport "github.com/FilipBudzynski/book_it/cmd/web/import"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// MaxImportFileSize limits the uploaded export, a library of a few thousand books is well below it
+const MaxImportFileSize = 10 << 20
+
+var ErrImportFileTooLarge = errors.New("the file is too large, the limit is 10MB")
+
+type ImportService interface {
+	StartGoodreads(ctx context.Context, userID, fileName string, data []byte) (*models.ImportJob, error)
+	StartKindle(ctx context.Context, userID, fileName string, data []byte, createMissing bool) (*models.ImportJob, error)
+	Get(id, userID string) (*models.ImportJob, error)
+	GetAll(userID string) ([]*models.ImportJob, error)
+}
+
+type ImportHandler struct {
+	importService ImportService
+}
+
+func NewImportHandler(importService ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+func (h *ImportHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/import")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("", h.List)
+	group.POST("", h.Create)
+	group.GET("/:id", h.Get)
+}
+
+func (h *ImportHandler) List(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	jobs, err := h.importService.GetAll(userID)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+
+	return utils.RenderView(c, webImport.ImportPage(jobs))
+}
+
+func (h *ImportHandler) Create(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	if fileHeader.Size > MaxImportFileSize {
+		return errs.HttpErrorBadRequest(ErrImportFileTooLarge)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	var job *models.ImportJob
+	if c.FormValue("source") == models.ImportSourceKindle {
+		job, err = h.importService.StartKindle(c.Request().Context(), userID, fileHeader.Filename, data, c.FormValue("create-missing") == "on")
+	} else {
+		job, err = h.importService.StartGoodreads(c.Request().Context(), userID, fileHeader.Filename, data)
+	}
+	switch {
+	case errors.Is(err, models.ErrImportAlreadyImported):
+		_ = toast.Info("This file was already imported").SetHXTriggerHeader(c)
+	case err != nil:
+		return errs.HttpErrorBadRequest(err)
+	default:
+		_ = toast.Success(c, "Import started!")
+	}
+
+	c.Response().Header().Set("HX-Push-Url", "/import/"+strconv.FormatUint(uint64(job.ID), 10))
+	return utils.RenderView(c, webImport.ReportPage(job))
+}
+
+func (h *ImportHandler) Get(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	job, err := h.importService.Get(c.Param("id"), userID)
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+
+	// the report polls itself while the job runs, the polling request only needs the report
+	if c.Request().Header.Get("HX-Trigger") == "import-report" {
+		return utils.RenderView(c, webImport.Report(job))
+	}
+	return utils.RenderView(c, webImport.ReportPage(job))
+}
@@ -6,10 +6,12 @@ import (
 	"strconv"
 
 	webProgress "github.com/FilipBudzynski/book_it/cmd/web/progress"
+	webReviews "github.com/FilipBudzynski/book_it/cmd/web/reviews"
 	"github.com/FilipBudzynski/book_it/internal/errs"
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"github.com/FilipBudzynski/book_it/internal/toast"
 	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
 )
 
@@ -33,6 +35,7 @@ type ProgressService interface {
 type progressHandler struct {
 	progressService ProgressService
 	userBookService UserBookService
+	reviewService   ReviewService
 }
 
 func NewProgressHandler(s ProgressService, u UserBookService) *progressHandler {
@@ -42,6 +45,12 @@ func NewProgressHandler(s ProgressService, u UserBookService) *progressHandler {
 	}
 }
 
+// WithReviews asks the user to review a book when they finish it
+func (h *progressHandler) WithReviews(reviewService ReviewService) *progressHandler {
+	h.reviewService = reviewService
+	return h
+}
+
 func (h *progressHandler) RegisterRoutes(app *echo.Echo) {
 	group := app.Group("/progress")
 	group.Use(utils.CheckLoggedInMiddleware) 
@@ -129,6 +138,11 @@ func (h *progressHandler) UpdateLog(c echo.Context) error {
 		return errs.HttpErrorBadRequest(err)
 	}
 
+	previous, err := h.progressService.GetProgressAssosiatedWithLogId(id)
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+
 	log, err := h.progressService.UpdateLog(id, pagesRead, comment)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
@@ -158,7 +172,18 @@ func (h *progressHandler) UpdateLog(c echo.Context) error {
 		return errs.HttpErrorInternalServerError(err)
 	}
 
-	return utils.RenderView(c, webProgress.ProgressDetailsOverview(progress, userBook))
+	view := webProgress.ProgressDetailsOverview(progress, userBook)
+	if !previous.Completed && progress.Completed && h.reviewService != nil {
+		own, err := h.reviewService.GetOwn(userBook.UserGoogleId, &userBook.Book)
+		if err != nil {
+			return errs.HttpErrorInternalServerError(err)
+		}
+		if own == nil {
+			view = templ.Join(view, webReviews.ReviewPrompt(&userBook.Book))
+		}
+	}
+
+	return utils.RenderView(c, view)
 }
 
 func (h *progressHandler) GetLogModal(c echo.Context) error {
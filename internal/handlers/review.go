@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	webReviews "github.com/FilipBudzynski/book_it/cmd/web/reviews"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type ReviewService interface {
+	Create(userID string, book *models.Book, rating float64, text string, spoiler bool) (*models.Review, error)
+	Update(userID, id string, rating float64, text string, spoiler bool) (*models.Review, error)
+	Delete(userID, id string) (*models.Review, error)
+	GetForBook(book *models.Book) ([]*models.Review, *models.ReviewStats, error)
+	GetOwn(userID string, book *models.Book) (*models.Review, error)
+}
+
+type ReviewHandler struct {
+	reviewService ReviewService
+	bookService   BookService
+}
+
+func NewReviewHandler(reviewService ReviewService, bookService BookService) *ReviewHandler {
+	return &ReviewHandler{
+		reviewService: reviewService,
+		bookService:   bookService,
+	}
+}
+
+func (h *ReviewHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/reviews")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("/books/:book_id", h.GetModal)
+	group.POST("/books/:book_id", h.Create)
+	group.PUT("/:id", h.Update)
+	group.DELETE("/:id", h.Delete)
+}
+
+func (h *ReviewHandler) GetModal(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	book, err := h.bookService.GetByID(c.Request().Context(), c.Param("book_id"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	return h.renderModal(c, userID, book)
+}
+
+func (h *ReviewHandler) Create(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	book, err := h.bookService.GetByID(c.Request().Context(), c.Param("book_id"))
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	rating, err := strconv.ParseFloat(c.FormValue("rating"), 64)
+	if err != nil {
+		return errs.HttpErrorBadRequest(models.ErrReviewInvalidRating)
+	}
+
+	_, err = h.reviewService.Create(userID, book, rating, c.FormValue("text"), c.FormValue("spoiler") == "on")
+	if errors.Is(err, models.ErrReviewExists) {
+		return errs.HttpErrorConflict(err)
+	}
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	_ = toast.Success(c, "Review saved!")
+	return h.renderModal(c, userID, book)
+}
+
+func (h *ReviewHandler) Update(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	rating, err := strconv.ParseFloat(c.FormValue("rating"), 64)
+	if err != nil {
+		return errs.HttpErrorBadRequest(models.ErrReviewInvalidRating)
+	}
+
+	review, err := h.reviewService.Update(userID, c.Param("id"), rating, c.FormValue("text"), c.FormValue("spoiler") == "on")
+	if err != nil {
+		return reviewError(err)
+	}
+
+	_ = toast.Success(c, "Review updated!")
+	return h.renderModal(c, userID, &review.Book)
+}
+
+func (h *ReviewHandler) Delete(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	review, err := h.reviewService.Delete(userID, c.Param("id"))
+	if err != nil {
+		return reviewError(err)
+	}
+	return h.renderModal(c, userID, &review.Book)
+}
+
+func (h *ReviewHandler) renderModal(c echo.Context, userID string, book *models.Book) error {
+	reviews, stats, err := h.reviewService.GetForBook(book)
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	var own *models.Review
+	for _, review := range reviews {
+		if review.UserGoogleId == userID {
+			own = review
+		}
+	}
+	return utils.RenderView(c, webReviews.ReviewsModal(book, reviews, stats, own, userID))
+}
+
+func reviewError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrReviewNotOwner):
+		return errs.HttpErrorForbidden(err)
+	case errors.Is(err, models.ErrReviewInvalidRating):
+		return errs.HttpErrorBadRequest(err)
+	default:
+		return errs.HttpErrorNotFound(err)
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import "strings"
+
+// SearchQuery combines several fields in one search, every field that is set has to match.
+// Words are matched one by one so misspelled or partial queries still find books,
+// "quoted phrases" are matched as a whole.
+type SearchQuery struct {
+	Text    string
+	Title   string
+	Author  string
+	Subject string
+	ISBN    string
+}
+
+// SearchField is a single field of a SearchQuery
+type SearchField struct {
+	Type  QueryType
+	Value string
+}
+
+// the prefixes of the search box syntax, the order is the order of SearchQuery.Fields
+var searchFieldPrefixes = []struct {
+	prefix    string
+	queryType QueryType
+}{
+	{"title", QueryTypeTitle},
+	{"author", QueryTypeAuthor},
+	{"subject", QueryTypeSubject},
+	{"isbn", QueryTypeISBN},
+}
+
+// NewSearchQuery searches a single field
+func NewSearchQuery(query string, queryType QueryType) SearchQuery {
+	var q SearchQuery
+	q.set(queryType, strings.TrimSpace(query))
+	return q
+}
+
+// ParseSearchQuery reads the search box, where `author:lem subject:"science fiction" solaris` searches
+// three fields at once. Terms without a prefix go to the field of defaultType.
+func ParseSearchQuery(input string, defaultType QueryType) SearchQuery {
+	var q SearchQuery
+	for _, term := range SplitTerms(input) {
+		queryType, value := defaultType, term
+		if name, rest, ok := strings.Cut(term, ":"); ok && rest != "" {
+			for _, field := range searchFieldPrefixes {
+				if strings.EqualFold(name, field.prefix) {
+					queryType, value = field.queryType, rest
+				}
+			}
+		}
+		q.add(queryType, value)
+	}
+	return q
+}
+
+func (q *SearchQuery) field(queryType QueryType) *string {
+	switch queryType {
+	case QueryTypeTitle:
+		return &q.Title
+	case QueryTypeAuthor:
+		return &q.Author
+	case QueryTypeSubject:
+		return &q.Subject
+	case QueryTypeISBN:
+		return &q.ISBN
+	default:
+		return &q.Text
+	}
+}
+
+func (q *SearchQuery) set(queryType QueryType, value string) {
+	*q.field(queryType) = value
+}
+
+func (q *SearchQuery) add(queryType QueryType, value string) {
+	field := q.field(queryType)
+	if *field != "" {
+		*field += " "
+	}
+	*field += value
+}
+
+func (q SearchQuery) IsZero() bool {
+	return len(q.Fields()) == 0
+}
+
+// Fields returns the fields that are set, free text first
+func (q SearchQuery) Fields() []SearchField {
+	var fields []SearchField
+	if value := strings.TrimSpace(q.Text); value != "" {
+		fields = append(fields, SearchField{Type: QueryTypeText, Value: value})
+	}
+	for _, prefix := range searchFieldPrefixes {
+		if value := strings.TrimSpace(*q.field(prefix.queryType)); value != "" {
+			fields = append(fields, SearchField{Type: prefix.queryType, Value: value})
+		}
+	}
+	return fields
+}
+
+// String writes the query in the search box syntax, parsing it again gives the same query
+func (q SearchQuery) String() string {
+	var terms []string
+	for _, field := range q.Fields() {
+		prefix := ""
+		for _, p := range searchFieldPrefixes {
+			if p.queryType == field.Type {
+				prefix = p.prefix + ":"
+			}
+		}
+		for _, term := range SplitTerms(field.Value) {
+			terms = append(terms, prefix+term)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// Key identifies the query in cache keys, it ignores case and spacing
+func (q SearchQuery) Key() string {
+	return strings.ToLower(q.String())
+}
+
+// Words returns the values of all fields without quotes, for searches that have no fields
+func (q SearchQuery) Words() string {
+	var words []string
+	for _, field := range q.Fields() {
+		words = append(words, strings.Fields(strings.ReplaceAll(field.Value, `"`, " "))...)
+	}
+	return strings.Join(words, " ")
+}
+
+// SplitTerms splits s on spaces, keeping "quoted phrases" together with their quotes.
+// A phrase may follow a prefix, `author:"stanislaw lem"` is a single term.
+func SplitTerms(s string) []string {
+	var terms []string
+	var term strings.Builder
+	flush := func() {
+		if t := term.String(); t != "" && t != `""` {
+			terms = append(terms, t)
+		}
+		term.Reset()
+	}
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		// an unclosed quote closes at the end of the query
+		term.WriteRune('"')
+	}
+	flush()
+	return terms
+}
+
+// IsPhrase reports whether the term is a quoted phrase
+func IsPhrase(term string) bool {
+	return len(term) >= 2 && strings.HasPrefix(term, `"`) && strings.HasSuffix(term, `"`)
+}
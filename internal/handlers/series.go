@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"strconv"
+
+	webSeries "github.com/FilipBudzynski/book_it/cmd/web/series"
+	"github.com/FilipBudzynski/book_it/internal/errs"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type SeriesService interface {
+	SeriesVolumes
+	GetForBooks(bookIDs []string) ([]*models.Series, error)
+	AddBook(userID, bookID, name string, position float64) (*models.Series, error)
+	RemoveBook(userID, seriesID, bookID string) error
+}
+
+type SeriesHandler struct {
+	seriesService SeriesService
+	bookService   BookService
+}
+
+func NewSeriesHandler(seriesService SeriesService, bookService BookService) *SeriesHandler {
+	return &SeriesHandler{
+		seriesService: seriesService,
+		bookService:   bookService,
+	}
+}
+
+func (h *SeriesHandler) RegisterRoutes(app *echo.Echo) {
+	group := app.Group("/series")
+	group.Use(utils.CheckLoggedInMiddleware)
+	group.GET("/books/:book_id", h.GetModal)
+	group.POST("/books/:book_id", h.AddBook)
+	group.DELETE("/:id/books/:book_id", h.RemoveBook)
+}
+
+func (h *SeriesHandler) GetModal(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+	return h.renderModal(c, userID, c.Param("book_id"))
+}
+
+func (h *SeriesHandler) AddBook(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	position, err := strconv.ParseFloat(c.FormValue("position"), 64)
+	if err != nil {
+		return errs.HttpErrorBadRequest(models.ErrSeriesInvalidPosition)
+	}
+	series, err := h.seriesService.AddBook(userID, c.Param("book_id"), c.FormValue("name"), position)
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	_ = toast.Success(c, "Added to "+series.Name)
+	return h.renderModal(c, userID, c.Param("book_id"))
+}
+
+func (h *SeriesHandler) RemoveBook(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	if err := h.seriesService.RemoveBook(userID, c.Param("id"), c.Param("book_id")); err != nil {
+		return errs.HttpErrorForbidden(err)
+	}
+	return h.renderModal(c, userID, c.Param("book_id"))
+}
+
+func (h *SeriesHandler) renderModal(c echo.Context, userID, bookID string) error {
+	book, err := h.bookService.GetByID(c.Request().Context(), bookID)
+	if err != nil {
+		return errs.HttpErrorNotFound(err)
+	}
+	series, err := h.seriesService.GetForBooks([]string{book.ID})
+	if err != nil {
+		return errs.HttpErrorInternalServerError(err)
+	}
+	return utils.RenderView(c, webSeries.SeriesModal(book, series, userID))
+}
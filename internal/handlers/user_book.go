@@ -9,6 +9,7 @@ import (
 	webUserBooks "github.com/FilipBudzynski/book_it/cmd/web/user_books"
 	"github.com/FilipBudzynski/book_it/internal/errs"
 	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/toast"
 	"github.com/FilipBudzynski/book_it/utils"
 	"github.com/labstack/echo/v4"
 )
@@ -19,11 +20,13 @@ type UserBookService interface {
 	GetAll(userId string) ([]*models.UserBook, error)
 	Delete(id string) error
 	DeleteByBookId(bookId string) error
-	Search(userId, query string) ([]*models.UserBook, error)
+	Search(userId, query string, status models.UserBookStatus) ([]*models.UserBook, error)
+	SetStatus(userID, id string, status models.UserBookStatus) (*models.UserBook, error)
 }
 
 type UserBookHandler struct {
 	userBookService UserBookService
+	seriesVolumes   SeriesVolumes
 }
 
 func NewUserBookHandler(userBookService UserBookService) *UserBookHandler {
@@ -32,6 +35,12 @@ func NewUserBookHandler(userBookService UserBookService) *UserBookHandler {
 	}
 }
 
+// WithSeries shows the next volume of every series the user has started on the library page
+func (h *UserBookHandler) WithSeries(seriesVolumes SeriesVolumes) *UserBookHandler {
+	h.seriesVolumes = seriesVolumes
+	return h
+}
+
 func (h *UserBookHandler) RegisterRoutes(app *echo.Echo) {
 	group := app.Group("/user-books")
 	group.Use(utils.CheckLoggedInMiddleware) 
@@ -42,6 +51,7 @@ func (h *UserBookHandler) RegisterRoutes(app *echo.Echo) {
 	group.GET("/create_modal/:user_book_id", h.GetCreateProgressModal)
 	group.GET("/exchange/books", h.GetOfferedBooks)
 	group.GET("/search", h.Search)
+	group.PUT("/:id/status", h.SetStatus)
 }
 
 func (h *UserBookHandler) Create(c echo.Context) error {
@@ -94,12 +104,24 @@ func (h *UserBookHandler) List(c echo.Context) error {
 		return errs.HttpErrorUnauthorized(err)
 	}
 
+	status, err := models.ParseUserBookStatus(c.QueryParam("status"))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
 	userBooks, err := h.userBookService.GetAll(userId)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
 
-	return utils.RenderView(c, webUserBooks.List(userBooks))
+	next := []*models.NextVolume{}
+	if h.seriesVolumes != nil {
+		if next, err = h.seriesVolumes.NextVolumes(userBooks); err != nil {
+			return errs.HttpErrorInternalServerError(err)
+		}
+	}
+
+	return utils.RenderView(c, webUserBooks.List(models.FilterByStatus(userBooks, status), next, status))
 }
 
 func (h *UserBookHandler) GetCreateProgressModal(c echo.Context) error {
@@ -136,10 +158,40 @@ func (h *UserBookHandler) Search(c echo.Context) error {
 		return errs.HttpErrorUnauthorized(err)
 	}
 	search := c.QueryParam("query")
+	status, err := models.ParseUserBookStatus(c.QueryParam("status"))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
 
-	results, err := h.userBookService.Search(userId, search)
+	results, err := h.userBookService.Search(userId, search, status)
 	if err != nil {
 		return errs.HttpErrorInternalServerError(err)
 	}
 	return utils.RenderView(c, webUserBooks.BooksTableRows(results))
 }
+
+func (h *UserBookHandler) SetStatus(c echo.Context) error {
+	userID, err := utils.GetUserIDFromSession(c.Request())
+	if err != nil {
+		return errs.HttpErrorUnauthorized(err)
+	}
+
+	status, err := models.ParseUserBookStatus(c.FormValue("status"))
+	if err != nil {
+		return errs.HttpErrorBadRequest(err)
+	}
+
+	userBook, err := h.userBookService.SetStatus(userID, c.Param("id"), status)
+	switch err {
+	case nil:
+	case models.ErrUserBookUnknownStatus:
+		return errs.HttpErrorBadRequest(err)
+	case models.ErrUserBookNotOwner:
+		return errs.HttpErrorForbidden(err)
+	default:
+		return errs.HttpErrorInternalServerError(err)
+	}
+
+	_ = toast.Success(c, "Moved to "+userBook.Status.Label())
+	return utils.RenderView(c, webUserBooks.StatusCell(userBook))
+}
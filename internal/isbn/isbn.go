@@ -0,0 +1,113 @@
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidLength    = errors.New("isbn must have 10 or 13 digits")
+	ErrInvalidCharacter = errors.New("isbn contains an invalid character")
+	ErrInvalidChecksum  = errors.New("isbn checksum does not match")
+	ErrInvalidPrefix    = errors.New("isbn-13 must start with 978 or 979")
+)
+
+// ISBN is a validated ISBN kept in its 13 digit form
+type ISBN string
+
+// Parse accepts an ISBN-10 or ISBN-13, with or without hyphens and spaces,
+// validates its check digit and returns it normalized to ISBN-13
+func Parse(s string) (ISBN, error) {
+	digits := Clean(s)
+
+	for i, r := range digits {
+		// only the last character of an ISBN-10 can be an X
+		if !isDigit(r) && !(r == 'X' && i == 9 && len(digits) == 10) {
+			return "", ErrInvalidCharacter
+		}
+	}
+
+	switch len(digits) {
+	case 10:
+		if checkDigit10(digits[:9]) != digits[9:] {
+			return "", ErrInvalidChecksum
+		}
+		base := "978" + digits[:9]
+		return ISBN(base + checkDigit13(base)), nil
+	case 13:
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return "", ErrInvalidPrefix
+		}
+		if checkDigit13(digits[:12]) != digits[12:] {
+			return "", ErrInvalidChecksum
+		}
+		return ISBN(digits), nil
+	default:
+		return "", ErrInvalidLength
+	}
+}
+
+// Clean strips the separators and uppercases the ISBN-10 check character without validating anything
+func Clean(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Valid reports whether s is a correct ISBN-10 or ISBN-13
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+func (i ISBN) String() string {
+	return string(i)
+}
+
+func (i ISBN) ISBN13() string {
+	return string(i)
+}
+
+// ISBN10 returns the 10 digit form, ISBNs with the 979 prefix have none
+func (i ISBN) ISBN10() string {
+	if !strings.HasPrefix(string(i), "978") {
+		return ""
+	}
+	base := string(i)[3:12]
+	return base + checkDigit10(base)
+}
+
+// checkDigit10 computes the mod 11 check character of the first 9 digits
+func checkDigit10(base string) string {
+	sum := 0
+	for i, r := range base {
+		sum += (10 - i) * int(r-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return "X"
+	}
+	return string(rune('0' + check))
+}
+
+// checkDigit13 computes the mod 10 check digit of the first 12 digits
+func checkDigit13(base string) string {
+	sum := 0
+	for i, r := range base {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(r-'0')
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
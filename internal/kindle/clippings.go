@@ -0,0 +1,392 @@
+package kindle
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindHighlight Kind = "highlight"
+	KindNote      Kind = "note"
+	KindBookmark  Kind = "bookmark"
+
+	separator = "=========="
+)
+
+var (
+	ErrEmptyFile    = errors.New("the file is empty")
+	ErrNotClippings = errors.New(`the file is not a Kindle "My Clippings.txt"`)
+)
+
+// Clipping is a single entry of a Kindle "My Clippings.txt" file
+type Clipping struct {
+	// Entry is the position of the entry in the file, the first entry is 1
+	Entry  int
+	Title  string
+	Author string
+	Kind   Kind
+	// Page is 0 when the book has no page numbers
+	Page          int
+	LocationStart int
+	LocationEnd   int
+	AddedAt       *time.Time
+	Text          string
+	// Note is the note the reader wrote on the highlight, see Attach
+	Note string
+}
+
+// FirstAuthor returns the first of the authors first name first. Kindle separates the authors
+// with semicolons, so a comma only appears in a name written "Herbert, Frank".
+func (c Clipping) FirstAuthor() string {
+	author, _, _ := strings.Cut(c.Author, ";")
+	last, first, ok := strings.Cut(author, ",")
+	if !ok || strings.TrimSpace(first) == "" {
+		return strings.TrimSpace(author)
+	}
+	return strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+}
+
+// Location returns the location range as Kindle shows it, empty when it is unknown
+func (c Clipping) Location() string {
+	switch {
+	case c.LocationStart == 0:
+		return ""
+	case c.LocationEnd > c.LocationStart:
+		return fmt.Sprintf("%d-%d", c.LocationStart, c.LocationEnd)
+	default:
+		return strconv.Itoa(c.LocationStart)
+	}
+}
+
+// Key identifies the clipping across imports by its book and its starting location, or its page when
+// the book has no locations. A highlight the reader extends or writes a note on keeps its key, so
+// importing the grown file updates it instead of adding it again.
+func (c Clipping) Key() string {
+	start := "loc" + strconv.Itoa(c.LocationStart)
+	if c.LocationStart == 0 {
+		start = "page" + strconv.Itoa(c.Page)
+	}
+	book := strings.ToLower(strings.Join(strings.Fields(c.Title+"|"+c.Author), " "))
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s", book, c.Kind, start)))
+	return "kindle:" + hex.EncodeToString(sum[:12])
+}
+
+// the metadata line words of the languages Kindle is sold in, matched on the lowercased line
+var (
+	kindWords = []struct {
+		kind  Kind
+		words []string
+	}{
+		{KindBookmark, []string{"bookmark", "lesezeichen", "signet", "marcador", "segnalibro", "zakładka"}},
+		{KindHighlight, []string{"highlight", "clip", "markierung", "surlignement", "subrayado", "evidenziazione", "zakreślenie", "destaque"}},
+		{KindNote, []string{"note", "notiz", "nota", "notatka"}},
+	}
+	pageWords     = []string{"page", "seite", "página", "pagina", "stronie", "strona"}
+	locationWords = []string{"location", "loc.", "position", "posición", "posizione", "posição", "emplacement", "lokalizacja", "pozycja", "pozycji"}
+	addedWords    = []string{"added", "hinzugefügt", "ajouté", "añadido", "aggiunto", "dodano", "adicionado"}
+
+	numberRange = regexp.MustCompile(`(\d+)(?:\s*-\s*(\d+))?`)
+)
+
+// Parse reads a Kindle "My Clippings.txt" file. Entries whose metadata line is not understood are skipped,
+// bookmarks are kept with an empty text.
+func Parse(r io.Reader) ([]Clipping, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	clippings := []Clipping{}
+	entries := 0
+	lines := []string{}
+	empty := true
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		entries++
+		if clipping, ok := parseEntry(lines); ok {
+			clipping.Entry = entries
+			clippings = append(clippings, clipping)
+		}
+		lines = lines[:0]
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.ReplaceAll(scanner.Text(), "\ufeff", ""), "\r")
+		if strings.TrimSpace(line) != "" {
+			empty = false
+		}
+		if strings.TrimSpace(line) == separator {
+			flush()
+			continue
+		}
+		if len(lines) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if empty {
+		return nil, ErrEmptyFile
+	}
+	if len(clippings) == 0 {
+		return nil, ErrNotClippings
+	}
+	return clippings, nil
+}
+
+func parseEntry(lines []string) (Clipping, bool) {
+	if len(lines) < 2 || !strings.HasPrefix(strings.TrimSpace(lines[1]), "-") {
+		return Clipping{}, false
+	}
+	clipping := Clipping{}
+	clipping.Title, clipping.Author = splitTitle(strings.TrimSpace(lines[0]))
+	if !parseMetadata(&clipping, strings.TrimSpace(lines[1])) {
+		return Clipping{}, false
+	}
+	clipping.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if clipping.Kind != KindBookmark && clipping.Text == "" {
+		return Clipping{}, false
+	}
+	return clipping, true
+}
+
+// splitTitle takes the author from the last parentheses, "Dune (Dune Chronicles) (Frank Herbert)"
+// gives "Dune (Dune Chronicles)" by "Frank Herbert"
+func splitTitle(line string) (string, string) {
+	if !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				title := strings.TrimSpace(line[:i])
+				if title == "" {
+					return line, ""
+				}
+				return title, strings.TrimSpace(line[i+1 : len(line)-1])
+			}
+		}
+	}
+	return line, ""
+}
+
+// parseMetadata reads "- Your Highlight on page 12 | Location 170-172 | Added on Sunday, 5 January 2020 10:12:33"
+// and its translations
+func parseMetadata(clipping *Clipping, line string) bool {
+	segments := strings.Split(strings.TrimPrefix(line, "-"), "|")
+	lower := strings.ToLower(segments[0])
+	for _, kind := range kindWords {
+		if containsAny(lower, kind.words) {
+			clipping.Kind = kind.kind
+			break
+		}
+	}
+	if clipping.Kind == "" {
+		return false
+	}
+
+	for i, segment := range segments {
+		lower := strings.ToLower(segment)
+		switch {
+		case containsAny(lower, addedWords) || (i == len(segments)-1 && i > 0 && clipping.AddedAt == nil && !containsAny(lower, locationWords) && !containsAny(lower, pageWords)):
+			clipping.AddedAt = parseDate(lower)
+		case containsAny(lower, pageWords):
+			if match := numberRange.FindStringSubmatch(lower); match != nil {
+				clipping.Page, _ = strconv.Atoi(match[1])
+			}
+		case containsAny(lower, locationWords):
+			if match := numberRange.FindStringSubmatch(lower); match != nil {
+				clipping.LocationStart, _ = strconv.Atoi(match[1])
+				clipping.LocationEnd = locationEnd(match[1], match[2])
+			}
+		}
+	}
+	return true
+}
+
+// locationEnd expands the shortened ranges of older Kindles, "1406-12" ends at 1412
+func locationEnd(start, end string) int {
+	if end == "" {
+		parsed, _ := strconv.Atoi(start)
+		return parsed
+	}
+	if len(end) < len(start) {
+		end = start[:len(start)-len(end)] + end
+	}
+	parsed, _ := strconv.Atoi(end)
+	return parsed
+}
+
+func containsAny(s string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	months = map[string]time.Month{}
+	clock  = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+)
+
+func init() {
+	names := [][]string{
+		{"january", "januar", "janvier", "enero", "gennaio", "janeiro", "stycznia", "styczeń"},
+		{"february", "februar", "février", "febrero", "febbraio", "fevereiro", "lutego", "luty"},
+		{"march", "märz", "mars", "marzo", "março", "marca", "marzec"},
+		{"april", "avril", "abril", "aprile", "kwietnia", "kwiecień"},
+		{"may", "mai", "mayo", "maggio", "maio", "maja", "maj"},
+		{"june", "juni", "juin", "junio", "giugno", "junho", "czerwca", "czerwiec"},
+		{"july", "juli", "juillet", "julio", "luglio", "julho", "lipca", "lipiec"},
+		{"august", "août", "agosto", "sierpnia", "sierpień"},
+		{"september", "septembre", "septiembre", "setiembre", "settembre", "setembro", "września", "wrzesień"},
+		{"october", "oktober", "octobre", "octubre", "ottobre", "outubro", "października", "październik"},
+		{"november", "novembre", "noviembre", "novembro", "listopada", "listopad"},
+		{"december", "dezember", "décembre", "diciembre", "dicembre", "dezembro", "grudnia", "grudzień"},
+	}
+	for i, monthNames := range names {
+		for _, name := range monthNames {
+			months[name] = time.Month(i + 1)
+		}
+	}
+}
+
+// parseDate reads the date of the metadata line in any word order, "Sunday, 5 January 2020 10:12:33",
+// "Sunday, January 5, 2020 10:12:33 AM" or "domingo, 5 de enero de 2020 10:12:33". The time is local
+// to the reader's Kindle and kept as UTC. Dates without a month name give nil.
+func parseDate(segment string) *time.Time {
+	fields := strings.FieldsFunc(segment, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\u00a0'
+	})
+
+	var (
+		month                            time.Month
+		year, day, hour, minute, seconds = 0, 0, -1, 0, 0
+		pm, am                           bool
+	)
+	for _, field := range fields {
+		field = strings.TrimSuffix(field, ".")
+		if match := clock.FindStringSubmatch(field); match != nil {
+			hour, _ = strconv.Atoi(match[1])
+			minute, _ = strconv.Atoi(match[2])
+			seconds, _ = strconv.Atoi(match[3])
+			continue
+		}
+		if m, ok := months[field]; ok {
+			month = m
+			continue
+		}
+		switch field {
+		case "pm", "p.m":
+			pm = true
+			continue
+		case "am", "a.m":
+			am = true
+			continue
+		}
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		switch {
+		case number > 31:
+			year = number
+		case day == 0:
+			day = number
+		}
+	}
+	if month == 0 || year == 0 || day == 0 || hour < 0 {
+		return nil
+	}
+	if pm && hour < 12 {
+		hour += 12
+	}
+	if am && hour == 12 {
+		hour = 0
+	}
+	date := time.Date(year, month, day, hour, minute, seconds, 0, time.UTC)
+	return &date
+}
+
+// Attach drops the bookmarks and repeated clippings and moves the notes onto the highlight they were
+// written on, a note sits at the last location of its highlight. A highlight that was later extended
+// is kept only in its longest version. Notes without a highlight are kept on their own.
+func Attach(clippings []Clipping) []Clipping {
+	attached := []Clipping{}
+	book := func(c Clipping) string { return c.Title + "|" + c.Author }
+
+	for _, clipping := range clippings {
+		if clipping.Kind != KindHighlight {
+			continue
+		}
+		replaced := false
+		for i := range attached {
+			previous := &attached[i]
+			if book(*previous) != book(clipping) || !overlaps(*previous, clipping) {
+				continue
+			}
+			if strings.Contains(clipping.Text, previous.Text) {
+				*previous, replaced = clipping, true
+				break
+			}
+			if strings.Contains(previous.Text, clipping.Text) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			attached = append(attached, clipping)
+		}
+	}
+
+	for _, note := range clippings {
+		if note.Kind != KindNote {
+			continue
+		}
+		highlight := -1
+		for i := range attached {
+			candidate := attached[i]
+			if candidate.Kind == KindHighlight && book(candidate) == book(note) && note.LocationStart != 0 &&
+				note.LocationStart >= candidate.LocationStart && note.LocationStart <= candidate.LocationEnd {
+				highlight = i
+			}
+		}
+		switch {
+		case highlight < 0:
+			attached = append(attached, note)
+		case attached[highlight].Note == "":
+			attached[highlight].Note = note.Text
+		case !strings.Contains(attached[highlight].Note, note.Text):
+			attached[highlight].Note += "\n" + note.Text
+		}
+	}
+	return attached
+}
+
+func overlaps(a, b Clipping) bool {
+	if a.LocationStart == 0 || b.LocationStart == 0 {
+		return a.Page == b.Page && a.Text == b.Text
+	}
+	return a.LocationStart <= b.LocationEnd && b.LocationStart <= a.LocationEnd
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuthorNameEmpty = errors.New("author name is empty")
+
+// Author is a person credited on books, Book.Authors keeps the names as the provider sent them
+type Author struct {
+	gorm.Model
+	Name string `gorm:"not null" json:"name"`
+	// Key is the normalized name, see AuthorKey, the spellings of the same name share it
+	Key   string `gorm:"uniqueIndex;not null" json:"-"`
+	Books []Book `gorm:"many2many:book_authors;constraint:OnDelete:CASCADE;"`
+}
+
+// AuthorKey normalizes an author's name for matching, case, accents and punctuation are dropped
+// and the name parts are sorted, so "J.R.R. Tolkien" and "Tolkien, J. R. R." give the same key
+func AuthorKey(name string) string {
+	words := normalizedWords(name)
+	slices.Sort(words)
+	return strings.Join(words, " ")
+}
+
+// SplitAuthors splits Book.Authors into names, providers join them with ", ". The names are
+// written first name first, the importers turn the "Last, First" names of Goodreads and Kindle around.
+func SplitAuthors(authors string) []string {
+	var names []string
+	for _, name := range strings.Split(authors, ",") {
+		if name = strings.Join(strings.Fields(name), " "); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// BookOffer is a book of an author another user offers in an active exchange request
+type BookOffer struct {
+	Book            *Book
+	ExchangeRequest *ExchangeRequest
+	// Distance is in kilometers from the user's location, unknown without one
+	Distance      float64
+	DistanceKnown bool
+}
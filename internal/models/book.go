@@ -1,17 +1,94 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"errors"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+	"gorm.io/gorm"
+)
+
+var ErrBookISBNNotFound = errors.New("no book found with this isbn")
 
 type Book struct {
 	gorm.Model
-	ID            string  `gorm:"primaryKey"`
-	ISBN          uint    `json:"isbn"`
-	Title         string  `json:"title"`
-	Authors       string  `json:"authors"`
-	Description   string  `json:"description"`
-	ImageLink     string  `json:"thumbnail"`
-	Genres        []Genre `gorm:"many2many:book_genres;constraint:OnDelete:CASCADE;"`
-	Link          string
-	PublishedDate string
-	Pages         int
+	ID             string  `gorm:"primaryKey"`
+	ISBN13         string  `gorm:"index" json:"isbn13"`
+	ISBN10         string  `gorm:"index" json:"isbn10"`
+	WorkID         *uint   `gorm:"index" json:"work_id"`
+	ProviderWorkID string  `gorm:"index" json:"provider_work_id"`
+	Title          string  `json:"title"`
+	Authors        string  `json:"authors"`
+	Description    string  `json:"description"`
+	ImageLink      string  `json:"thumbnail"`
+	Genres         []Genre `gorm:"many2many:book_genres;constraint:OnDelete:CASCADE;"`
+	Link           string
+	PublishedDate  string
+	Pages          int
+	// Language is the ISO 639-1 code of the edition, see NormalizeLanguage
+	Language string `gorm:"index" json:"language"`
+	// MatchKey is the WorkMatchKey of the title and authors, used to find editions in queries
+	MatchKey string `gorm:"index" json:"-"`
+	// CreatedBy is the google id of the user who added a manual book, empty for provider books
+	CreatedBy string `gorm:"index" json:"created_by,omitempty"`
+	// LinkedBookID points a manual book to the provider book that replaced it
+	LinkedBookID string `json:"linked_book_id,omitempty"`
+	// AuthorList links the names of Authors to their Author, filled when the book is saved
+	AuthorList []Author `gorm:"many2many:book_authors;constraint:OnDelete:CASCADE;" json:"-"`
+	// Series is the series the provider puts the book in, it is saved as a SeriesEntry
+	Series *SeriesInfo `gorm:"-" json:"series,omitempty"`
+}
+
+// SetISBN stores both forms of the ISBN
+func (b *Book) SetISBN(i isbn.ISBN) {
+	b.ISBN13 = i.ISBN13()
+	b.ISBN10 = i.ISBN10()
+}
+
+// FillMissing copies the fields left empty by one provider from another source of the same edition
+func (b *Book) FillMissing(other *Book) {
+	if other == nil {
+		return
+	}
+	if b.ISBN13 == "" {
+		b.ISBN13 = other.ISBN13
+		b.ISBN10 = other.ISBN10
+	}
+	if b.ProviderWorkID == "" {
+		b.ProviderWorkID = other.ProviderWorkID
+	}
+	if b.Title == "" {
+		b.Title = other.Title
+	}
+	if b.Authors == "" {
+		b.Authors = other.Authors
+	}
+	if b.Description == "" {
+		b.Description = other.Description
+	}
+	if b.ImageLink == "" {
+		b.ImageLink = other.ImageLink
+	}
+	if b.Link == "" {
+		b.Link = other.Link
+	}
+	if b.PublishedDate == "" {
+		b.PublishedDate = other.PublishedDate
+	}
+	if b.Pages <= 0 {
+		b.Pages = other.Pages
+	}
+	if b.Language == "" {
+		b.Language = other.Language
+	}
+	if b.Series == nil {
+		b.Series = other.Series
+	}
+	if len(b.Genres) == 0 {
+		b.Genres = other.Genres
+	}
+}
+
+// HasMissingFields reports whether the book lacks data another provider could fill in
+func (b *Book) HasMissingFields() bool {
+	return b.Pages <= 0 || b.Description == "" || b.ImageLink == ""
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// BookSimilarity says how often the readers who shelved BookID also shelved SimilarBookID,
+// every pair is stored in both directions
+type BookSimilarity struct {
+	BookID        string `gorm:"primaryKey"`
+	SimilarBookID string `gorm:"primaryKey"`
+	Score         float64
+	// Readers is the number of users who have both books
+	Readers   int
+	UpdatedAt time.Time
+}
+
+// ShelfEntry is a book on a user's shelf, the input of the collaborative filter
+type ShelfEntry struct {
+	UserGoogleId string
+	BookID       string
+	Completed    bool
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const EbookMaxFileSize = 50 << 20
+
+var (
+	ErrEbookFileTooLarge   = errors.New("the ebook cannot be larger than 50MB")
+	ErrEbookStorageMissing = errors.New("keeping ebook files is not enabled")
+)
+
+// EbookFile is an uploaded epub the user asked to keep, the file itself lives in the storage directory
+type EbookFile struct {
+	gorm.Model
+	UserGoogleId string `gorm:"not null;index"`
+	BookID       string `gorm:"not null;index"`
+	FileName     string
+	Path         string
+	Size         int64
+}
@@ -66,6 +66,8 @@ type ExchangeRequest struct {
 	Matches       []ExchangeMatch `gorm:"constraint:OnDelete:SET NULL,OnUpdate:CASCADE"`
 	Latitude      float64
 	Longitude     float64
+	// AnyEdition lets other editions of the desired book match, not only the exact one
+	AnyEdition bool
 }
 
 func (r *ExchangeRequest) GetMatchStatus(otherRequestId uint) MatchStatus {
@@ -77,6 +79,55 @@ func (r *ExchangeRequest) GetMatchStatus(otherRequestId uint) MatchStatus {
 	return MatchStatusPending
 }
 
+// MatchWith reports whether both requests offer what the other one desires.
+// The returned rule is the loosest equivalence any side of the match relies on.
+func (e *ExchangeRequest) MatchWith(other *ExchangeRequest) (MatchRule, bool) {
+	ourRule, ok := e.acceptsFrom(other.OfferedBooks)
+	if !ok {
+		return "", false
+	}
+	theirRule, ok := other.acceptsFrom(e.OfferedBooks)
+	if !ok {
+		return "", false
+	}
+	if theirRule.looserThan(ourRule) {
+		return theirRule, true
+	}
+	return ourRule, true
+}
+
+// acceptsFrom returns the strictest rule under which one of the offered books satisfies the desired book
+func (e *ExchangeRequest) acceptsFrom(offeredBooks []OfferedBook) (MatchRule, bool) {
+	var best MatchRule
+	for _, offered := range offeredBooks {
+		if offered.BookID == e.DesiredBookID {
+			return MatchRuleExact, true
+		}
+		if !e.AnyEdition {
+			continue
+		}
+		if rule := EditionEquivalence(&e.DesiredBook, &offered.Book); rule != "" && (best == "" || best.looserThan(rule)) {
+			best = rule
+		}
+	}
+	return best, best != ""
+}
+
+// EditionEquivalence returns the rule under which two books are editions of the same title,
+// or an empty rule when they are not
+func EditionEquivalence(a, b *Book) MatchRule {
+	if a.ID != "" && a.ID == b.ID {
+		return MatchRuleExact
+	}
+	if (a.ISBN13 != "" && a.ISBN13 == b.ISBN13) || (a.ISBN10 != "" && a.ISBN10 == b.ISBN10) {
+		return MatchRuleISBN
+	}
+	if key := WorkMatchKey(a.Title, a.Authors); key != "" && key == WorkMatchKey(b.Title, b.Authors) {
+		return MatchRuleTitleAuthor
+	}
+	return ""
+}
+
 func (e *ExchangeRequest) Validate() error {
 	if e.DesiredBookID == "" {
 		return ErrExchangeRequestNoDesiredBookProvided
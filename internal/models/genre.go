@@ -1,13 +1,66 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strings"
+	"unicode"
 
+	"gorm.io/gorm"
+)
+
+// GenrePathSeparator separates the levels of a category, Google sends "Fiction / Science Fiction / Space Opera"
+const GenrePathSeparator = " / "
+
+var ErrGenreEmpty = errors.New("genre name is empty")
+
+// Genre is a node of the genre hierarchy. Path holds the names from the root down to the genre,
+// the descendants of a genre are the genres whose path starts with its path. Names repeat across
+// branches, "Pets / Dogs" and "Juvenile Fiction / Animals / Dogs" are different genres.
 type Genre struct {
 	gorm.Model
     ID            uint   `gorm:"primary_key"`
-	Name          string `gorm:"unique;not null"`
+	Name          string `gorm:"not null;index"`
+	ParentID      *uint  `gorm:"index"`
+	Path          string `gorm:"index"`
 }
 
 func (g Genre) String() string {
     return g.Name
 }
+
+// Ancestors returns the names from the root down to the genre, the genre itself is the last one
+func (g Genre) Ancestors() []string {
+	if g.Path != "" {
+		return strings.Split(g.Path, GenrePathSeparator)
+	}
+	return GenrePath(g.Name)
+}
+
+// GenreAlias maps a normalized category name onto its canonical genre, see GenreKey
+type GenreAlias struct {
+	Alias   string `gorm:"primaryKey"`
+	GenreID uint   `gorm:"not null;index"`
+	Genre   Genre  `gorm:"foreignKey:GenreID;constraint:OnDelete:CASCADE;"`
+}
+
+// GenreKey normalizes a category for matching, "Sci-Fi & Fantasy" becomes "sci fi and fantasy"
+func GenreKey(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// GenrePath splits a provider category into its levels. The "General" level Google puts
+// under most categories says nothing and is dropped.
+func GenrePath(category string) []string {
+	var path []string
+	for _, level := range strings.Split(category, "/") {
+		level = strings.Join(strings.Fields(level), " ")
+		if level == "" || (GenreKey(level) == "general" && len(path) > 0) {
+			continue
+		}
+		path = append(path, level)
+	}
+	return path
+}
@@ -0,0 +1,53 @@
+package models
+
+// GenreTaxonomy is the hierarchy the categories of the providers are mapped onto, every path
+// lists the other names providers use for the genre. Categories not in here join the hierarchy
+// as they arrive.
+var GenreTaxonomy = map[string][]string{
+	"Fiction":                   {"Novels", "Literary Fiction", "Literature"},
+	"Fiction / Science Fiction": {"Sci-Fi", "SciFi", "SF", "Science-Fiction"},
+	"Fiction / Science Fiction / Space Opera": {},
+	"Fiction / Science Fiction / Cyberpunk":   {},
+	"Fiction / Science Fiction / Dystopian":   {"Dystopia", "Dystopias", "Dystopian Fiction"},
+	"Fiction / Science Fiction / Time Travel": {},
+	"Fiction / Fantasy":                       {"Fantasy Fiction"},
+	"Fiction / Fantasy / Epic":                {"Epic Fantasy", "High Fantasy"},
+	"Fiction / Fantasy / Urban":               {"Urban Fantasy"},
+	"Fiction / Fantasy / Dark Fantasy":        {},
+	"Fiction / Mystery & Detective":           {"Mystery", "Mysteries", "Detective and Mystery Stories", "Detective"},
+	"Fiction / Thrillers":                     {"Thriller", "Suspense"},
+	"Fiction / Horror":                        {"Horror Fiction", "Horror Tales"},
+	"Fiction / Romance":                       {"Romance Fiction", "Love Stories"},
+	"Fiction / Historical":                    {"Historical Fiction"},
+	"Fiction / Classics":                      {"Classic Literature"},
+	"Fiction / Humorous":                      {"Humor Fiction"},
+	"Fiction / Short Stories":                 {},
+	"Fiction / Action & Adventure":            {"Adventure", "Adventure Stories", "Adventure Fiction"},
+	"Juvenile Fiction":                        {"Children's Fiction", "Children's Stories"},
+	"Young Adult Fiction":                     {"Young Adult", "YA", "YA Fiction"},
+	"Comics & Graphic Novels":                 {"Comics", "Graphic Novels", "Manga"},
+	"Poetry":                                  {"Poems"},
+	"Drama":                                   {"Plays"},
+	"Biography & Autobiography":               {"Biography", "Autobiography", "Memoir", "Memoirs"},
+	"History":                                 {},
+	"Philosophy":                              {},
+	"Psychology":                              {},
+	"Religion":                                {},
+	"Science":                                 {"Popular Science"},
+	"Science / Physics":                       {},
+	"Science / Life Sciences / Biology":       {"Biology"},
+	"Science / Space Science / Astronomy":     {"Astronomy"},
+	"Computers":                               {"Computer Science", "Programming"},
+	"Business & Economics":                    {"Business", "Economics"},
+	"Self-Help":                               {"Self Help", "Personal Development"},
+	"Cooking":                                 {"Cookbooks", "Cookery"},
+	"Travel":                                  {},
+	"Art":                                     {},
+	"Music":                                   {},
+	"Political Science":                       {"Politics"},
+	"Social Science":                          {"Sociology"},
+	"Sports & Recreation":                     {"Sports"},
+	"True Crime":                              {},
+	"Health & Fitness":                        {"Health"},
+	"Education":                               {},
+}
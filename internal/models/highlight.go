@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrHighlightTextEmpty    = errors.New("highlight text is required")
+	ErrHighlightInvalidPage  = errors.New("page must be a positive number")
+	ErrHighlightLogNotOfBook = errors.New("the progress log is not from this book")
+	ErrHighlightNotOwner     = errors.New("you can only change highlights of your own books")
+)
+
+// Highlight is a quote the user kept from a book of their library, with the page and the day
+// of their reading journal it was captured on when they gave them
+type Highlight struct {
+	gorm.Model
+	UserBookID         uint     `gorm:"not null;index"`
+	UserBook           UserBook `gorm:"foreignKey:UserBookID;constraint:OnDelete:CASCADE;"`
+	Page               *int
+	DailyProgressLogID *uint             `gorm:"index"`
+	DailyProgressLog   *DailyProgressLog `gorm:"foreignKey:DailyProgressLogID;constraint:OnDelete:SET NULL;"`
+	Text               string            `gorm:"not null"`
+	Note               string
+	// Tags are kept lowercase and comma separated, see ParseTags
+	Tags string
+	// SourceKey identifies an imported highlight so importing the same file again skips or updates it
+	SourceKey string `gorm:"index"`
+}
+
+// ParseTags splits the comma separated tags, dropping the empty and repeated ones
+func ParseTags(tags string) []string {
+	parsed := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+		if tag != "" && !slices.Contains(parsed, tag) {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
+
+func (h *Highlight) TagList() []string {
+	return ParseTags(h.Tags)
+}
+
+func (h *Highlight) Validate() error {
+	if strings.TrimSpace(h.Text) == "" {
+		return ErrHighlightTextEmpty
+	}
+	if h.Page != nil && *h.Page <= 0 {
+		return ErrHighlightInvalidPage
+	}
+	return nil
+}
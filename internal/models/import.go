@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type (
+	ImportJobStatus string
+	ImportRowStatus string
+)
+
+const (
+	ImportJobStatusPending   ImportJobStatus = "pending"
+	ImportJobStatusRunning   ImportJobStatus = "running"
+	ImportJobStatusCompleted ImportJobStatus = "completed"
+	ImportJobStatusFailed    ImportJobStatus = "failed"
+
+	ImportRowStatusPending   ImportRowStatus = "pending"
+	ImportRowStatusMatched   ImportRowStatus = "matched"
+	ImportRowStatusAmbiguous ImportRowStatus = "ambiguous"
+	ImportRowStatusFailed    ImportRowStatus = "failed"
+
+	ImportSourceGoodreads = "goodreads"
+	// ImportSourceKindle is a Kindle "My Clippings.txt" file, it is also the source form value of its upload
+	ImportSourceKindle = "kindle"
+)
+
+var (
+	ErrImportEmptyFile       = errors.New("the import file has no books")
+	ErrImportAlreadyImported = errors.New("this file was already imported")
+	ErrImportInterrupted     = errors.New("the import was interrupted by a server restart, upload the file again")
+	ErrImportPanicked        = errors.New("the import stopped unexpectedly")
+)
+
+func (s ImportJobStatus) String() string {
+	return string(s)
+}
+
+func (s ImportJobStatus) Badge() string {
+	switch s {
+	case ImportJobStatusCompleted:
+		return "success"
+	case ImportJobStatusRunning, ImportJobStatusPending:
+		return "info"
+	case ImportJobStatusFailed:
+		return "error"
+	}
+	return "secondary"
+}
+
+func (s ImportJobStatus) Finished() bool {
+	return s == ImportJobStatusCompleted || s == ImportJobStatusFailed
+}
+
+func (s ImportRowStatus) String() string {
+	return string(s)
+}
+
+func (s ImportRowStatus) Badge() string {
+	switch s {
+	case ImportRowStatusMatched:
+		return "success"
+	case ImportRowStatusAmbiguous:
+		return "warning"
+	case ImportRowStatusFailed:
+		return "error"
+	}
+	return "secondary"
+}
+
+// ImportJob is a library import running in the background, FileHash makes uploading the same file twice a no-op
+type ImportJob struct {
+	gorm.Model
+	UserGoogleId string `gorm:"not null;index"`
+	Source       string
+	FileName     string
+	FileHash     string `gorm:"index"`
+	Status       ImportJobStatus
+	Error        string
+	Total        int
+	Matched      int
+	Ambiguous    int
+	Failed       int
+	Rows         []ImportRow `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (j *ImportJob) Processed() int {
+	return j.Matched + j.Ambiguous + j.Failed
+}
+
+// ImportRow is the report of a single row of an import
+type ImportRow struct {
+	gorm.Model
+	ImportJobID uint `gorm:"not null;index"`
+	Line        int
+	Title       string
+	Author      string
+	ISBN        string
+	Status      ImportRowStatus
+	BookID      string
+	Message     string
+}
@@ -0,0 +1,68 @@
+package models
+
+import "strings"
+
+// Language is a language searches can be restricted to, Code is its ISO 639-1 code
+type Language struct {
+	Code string
+	Name string
+	// MARC is the three letter code Open Library uses
+	MARC string
+}
+
+var SearchLanguages = []Language{
+	{Code: "en", Name: "English", MARC: "eng"},
+	{Code: "pl", Name: "Polish", MARC: "pol"},
+	{Code: "de", Name: "German", MARC: "ger"},
+	{Code: "fr", Name: "French", MARC: "fre"},
+	{Code: "es", Name: "Spanish", MARC: "spa"},
+	{Code: "it", Name: "Italian", MARC: "ita"},
+	{Code: "pt", Name: "Portuguese", MARC: "por"},
+	{Code: "nl", Name: "Dutch", MARC: "dut"},
+	{Code: "sv", Name: "Swedish", MARC: "swe"},
+	{Code: "cs", Name: "Czech", MARC: "cze"},
+	{Code: "uk", Name: "Ukrainian", MARC: "ukr"},
+	{Code: "ru", Name: "Russian", MARC: "rus"},
+	{Code: "ja", Name: "Japanese", MARC: "jpn"},
+	{Code: "zh", Name: "Chinese", MARC: "chi"},
+}
+
+// the ISO 639-2 terminology codes of the languages whose MARC code differs
+var terminologyCodes = map[string]string{
+	"deu": "de",
+	"fra": "fr",
+	"nld": "nl",
+	"ces": "cs",
+	"zho": "zh",
+}
+
+// NormalizeLanguage turns the language tags books come with, "en-US", "eng" or "/languages/pol",
+// into the two letter code stored on the book. Three letter codes of unknown languages are kept.
+func NormalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.TrimPrefix(code, "/languages/")
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	if len(code) == 3 {
+		if short, ok := terminologyCodes[code]; ok {
+			return short
+		}
+		for _, language := range SearchLanguages {
+			if language.MARC == code {
+				return language.Code
+			}
+		}
+	}
+	return code
+}
+
+// MARCLanguage returns the Open Library code of a two letter code, empty when it is not a search language
+func MARCLanguage(code string) string {
+	for _, language := range SearchLanguages {
+		if language.Code == code {
+			return language.MARC
+		}
+	}
+	return ""
+}
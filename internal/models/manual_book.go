@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+)
+
+// ManualBookIDPrefix keeps user created books apart from the ids of the providers
+const ManualBookIDPrefix = "manual-"
+
+const (
+	ManualBookMaxGenres    = 5
+	ManualBookMaxCoverSize = 1 << 20
+)
+
+var (
+	ErrManualBookTitleRequired   = errors.New("title is required")
+	ErrManualBookAuthorsRequired = errors.New("authors are required")
+	ErrManualBookPagesNegative   = errors.New("pages cannot be negative")
+	ErrManualBookTooManyGenres   = errors.New("a book can have at most 5 genres")
+	ErrManualBookInvalidCover    = errors.New("cover must be an http(s) link or an image")
+	ErrManualBookCoverTooLarge   = errors.New("cover image cannot be larger than 1MB")
+	ErrManualBookISBNExists      = errors.New("a book with this isbn already exists")
+	ErrManualBookNotManual       = errors.New("only manually added books can be linked")
+	ErrManualBookNotCreator      = errors.New("only the user who added the book can link it")
+	ErrManualBookLinkToManual    = errors.New("a manual book can only be linked to a provider book")
+)
+
+// ManualBook is the user input a manual book is created from
+type ManualBook struct {
+	Title   string
+	Authors string
+	Pages   int
+	ISBN    string
+	Genres  []string
+	// Cover is a link or a data uri of an uploaded image
+	Cover       string
+	Description string
+	Language    string
+}
+
+func (m *ManualBook) Validate() error {
+	if strings.TrimSpace(m.Title) == "" {
+		return ErrManualBookTitleRequired
+	}
+	if strings.TrimSpace(m.Authors) == "" {
+		return ErrManualBookAuthorsRequired
+	}
+	if m.Pages < 0 {
+		return ErrManualBookPagesNegative
+	}
+	if strings.TrimSpace(m.ISBN) != "" {
+		if _, err := isbn.Parse(m.ISBN); err != nil {
+			return err
+		}
+	}
+	if len(m.Genres) > ManualBookMaxGenres {
+		return ErrManualBookTooManyGenres
+	}
+	if m.Cover != "" {
+		if !strings.HasPrefix(m.Cover, "http://") && !strings.HasPrefix(m.Cover, "https://") && !strings.HasPrefix(m.Cover, "data:image/") {
+			return ErrManualBookInvalidCover
+		}
+		if len(m.Cover) > ManualBookMaxCoverSize*4/3+64 {
+			return ErrManualBookCoverTooLarge
+		}
+	}
+	return nil
+}
+
+// Book builds the book saved for the input, it has to be validated first
+func (m *ManualBook) Book(userID string) *Book {
+	book := &Book{
+		ID:          NewManualBookID(),
+		Title:       strings.TrimSpace(m.Title),
+		Authors:     strings.TrimSpace(m.Authors),
+		Pages:       m.Pages,
+		ImageLink:   m.Cover,
+		Description: strings.TrimSpace(m.Description),
+		Language:    NormalizeLanguage(m.Language),
+		CreatedBy:   userID,
+	}
+	if parsed, err := isbn.Parse(m.ISBN); err == nil {
+		book.SetISBN(parsed)
+	}
+	for _, name := range m.Genres {
+		if name = strings.TrimSpace(name); name != "" {
+			book.Genres = append(book.Genres, Genre{Name: name})
+		}
+	}
+	return book
+}
+
+func NewManualBookID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return ManualBookIDPrefix + hex.EncodeToString(b)
+}
+
+func (b *Book) IsManual() bool {
+	return strings.HasPrefix(b.ID, ManualBookIDPrefix)
+}
@@ -5,6 +5,8 @@ import "gorm.io/gorm"
 type (
 	MatchStatus   string
 	MatchDecision string
+	// MatchRule tells which equivalence between the desired and offered books produced a match
+	MatchRule string
 )
 
 const (
@@ -15,8 +17,32 @@ const (
 	MatchDecisionPending  MatchDecision = "pending"
 	MatchDecisionAccepted MatchDecision = "accepted"
 	MatchDecisionDeclined MatchDecision = "declined"
+
+	MatchRuleExact       MatchRule = "exact"
+	MatchRuleISBN        MatchRule = "isbn"
+	MatchRuleTitleAuthor MatchRule = "title_author"
 )
 
+func (r MatchRule) String() string {
+	return string(r)
+}
+
+func (r MatchRule) Label() string {
+	switch r {
+	case MatchRuleISBN:
+		return "Same ISBN"
+	case MatchRuleTitleAuthor:
+		return "Same title and author"
+	}
+	return "Same edition"
+}
+
+// looserThan orders the rules from the exact edition to the weakest equivalence
+func (r MatchRule) looserThan(other MatchRule) bool {
+	rank := map[MatchRule]int{MatchRuleExact: 0, MatchRuleISBN: 1, MatchRuleTitleAuthor: 2}
+	return rank[r] > rank[other]
+}
+
 func (s MatchDecision) Accepted() bool {
 	return s == MatchDecisionAccepted
 }
@@ -52,6 +78,7 @@ type ExchangeMatch struct {
 	Request2Decision         MatchDecision
 	Status                   MatchStatus
 	Distance                 float64
+	MatchRule                MatchRule
 }
 
 func (e *ExchangeMatch) MatchedRequest(requestId uint) *ExchangeRequest {
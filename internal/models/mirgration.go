@@ -3,12 +3,26 @@ package models
 var MigrateModels = []any{
 	&User{},
 	&UserBook{},
+	&UserBookStatusChange{},
 	&Book{},
+	&Work{},
 	&ReadingProgress{},
 	&DailyProgressLog{},
 	&ExchangeRequest{},
 	&OfferedBook{},
 	&ExchangeMatch{},
 	&Genre{},
+	&Author{},
+	&Series{},
+	&SeriesEntry{},
+	&GenreAlias{},
     &Location{},
+	&ProviderCacheEntry{},
+	&ImportJob{},
+	&ImportRow{},
+	&EbookFile{},
+	&BookSimilarity{},
+	&RecommendationFeedback{},
+	&Review{},
+	&Highlight{},
 }
@@ -33,17 +33,67 @@ type ReadingProgress struct {
 	DailyTargetPages int
 	DailyProgress    []DailyProgressLog `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Completed        bool
+	// wasCompleted is the stored Completed before the save, BeforeSave reads it
+	wasCompleted bool
 }
 
+// AfterCreate moves the book to the reading shelf, or to the read shelf when the progress starts completed
+func (r *ReadingProgress) AfterCreate(db *gorm.DB) error {
+	status := UserBookStatusReading
+	if r.Completed {
+		status = UserBookStatusRead
+	}
+	return r.moveUserBook(db, status)
+}
+
+// BeforeSave remembers whether the stored progress was completed, so AfterSave only
+// moves the book when it gets finished and leaves the shelves the user picked alone
+func (r *ReadingProgress) BeforeSave(db *gorm.DB) error {
+	if r.ID == 0 {
+		return nil
+	}
+	completed := []bool{}
+	err := db.Session(&gorm.Session{NewDB: true}).Model(&ReadingProgress{}).
+		Where("id = ?", r.ID).Pluck("completed", &completed).Error
+	if err != nil {
+		return err
+	}
+	r.wasCompleted = len(completed) > 0 && completed[0]
+	return nil
+}
+
+// AfterSave stores the Completed flag when it changed and moves the book to the read
+// shelf when the progress goes from not completed to completed
 func (r *ReadingProgress) AfterSave(db *gorm.DB) error {
-	if r.CurrentPage == r.TotalPages {
-		r.Completed = true
-	} else {
-		r.Completed = false
+	r.Completed = r.IsCompleted()
+	if r.Completed == r.wasCompleted {
+		return nil
+	}
+	if r.ID != 0 {
+		err := db.Session(&gorm.Session{NewDB: true}).Model(&ReadingProgress{}).
+			Where("id = ?", r.ID).UpdateColumn("completed", r.Completed).Error
+		if err != nil {
+			return err
+		}
+	}
+	r.wasCompleted = r.Completed
+	if r.Completed {
+		return r.moveUserBook(db, UserBookStatusRead)
 	}
 	return nil
 }
 
+func (r *ReadingProgress) moveUserBook(db *gorm.DB, status UserBookStatus) error {
+	if r.UserBookID == 0 {
+		return nil
+	}
+	_, err := MoveUserBook(db, r.UserBookID, status)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	return err
+}
+
 func (r *ReadingProgress) Validate() error {
 	if r.TotalPages <= 0 {
 		return ErrProgressInvalidTotalPages
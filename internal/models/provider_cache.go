@@ -0,0 +1,16 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrProviderUnavailable is returned while a book provider is rate limiting us or its circuit breaker is open
+var ErrProviderUnavailable = errors.New("book search is temporarily unavailable, try again in a moment")
+
+// ProviderCacheEntry stores a serialized provider response under the key of the call that produced it
+type ProviderCacheEntry struct {
+	Key       string `gorm:"primaryKey"`
+	Payload   []byte
+	FetchedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Recommendation is a book scored against the user's library. The parts of the score are kept
+// so the user can be told why the book was picked, every part and the score are between 0 and 1.
+type Recommendation struct {
+	Book         *Book
+	Score        float64
+	GenreScore   float64
+	AuthorScore  float64
+	ContentScore float64
+	// CollaborativeScore comes from what readers with the same books have shelved, see recommend.Blend
+	CollaborativeScore float64
+	// Reason is the part that contributed the most to the score
+	Reason RecommendationReason
+}
+
+type ReasonKind string
+
+const (
+	ReasonGenre       ReasonKind = "genre"
+	ReasonAuthor      ReasonKind = "author"
+	ReasonSimilarBook ReasonKind = "similar_book"
+	ReasonReaders     ReasonKind = "readers"
+	ReasonSeries      ReasonKind = "series"
+)
+
+// RecommendationReason explains a recommendation, Subject is the genre, the author, the title
+// of the library book the recommendation comes from or the series with the volume number
+type RecommendationReason struct {
+	Kind    ReasonKind
+	Subject string
+}
+
+func (r RecommendationReason) String() string {
+	switch r.Kind {
+	case ReasonGenre:
+		return "Because you like " + r.Subject
+	case ReasonAuthor:
+		return "Because you read " + r.Subject
+	case ReasonSimilarBook:
+		return "Similar to " + r.Subject
+	case ReasonReaders:
+		return "Readers of " + r.Subject + " also read this"
+	case ReasonSeries:
+		return "Next in " + r.Subject
+	default:
+		return ""
+	}
+}
+
+type FeedbackKind string
+
+const (
+	FeedbackNotInterested FeedbackKind = "not_interested"
+	FeedbackAlreadyRead   FeedbackKind = "already_read"
+)
+
+var ErrFeedbackUnknownKind = errors.New("unknown recommendation feedback, use not_interested or already_read")
+
+func ParseFeedbackKind(kind string) (FeedbackKind, error) {
+	switch FeedbackKind(kind) {
+	case FeedbackNotInterested, FeedbackAlreadyRead:
+		return FeedbackKind(kind), nil
+	default:
+		return "", ErrFeedbackUnknownKind
+	}
+}
+
+// RecommendationFeedback is a recommended book the user turned down, the book is not recommended
+// to them again. A book they have already read also counts as a finished book of their library.
+type RecommendationFeedback struct {
+	UserGoogleId string       `gorm:"primaryKey"`
+	BookID       string       `gorm:"primaryKey"`
+	Book         Book         `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;"`
+	Kind         FeedbackKind `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	ReviewMinRating = 1.0
+	ReviewMaxRating = 5.0
+	// ReviewRatingStep allows half stars
+	ReviewRatingStep = 0.5
+)
+
+var (
+	ErrReviewInvalidRating = errors.New("rating must be between 1 and 5 stars, in half stars")
+	ErrReviewExists        = errors.New("you have already reviewed this book")
+	ErrReviewNotOwner      = errors.New("you can only change your own reviews")
+)
+
+// Review is a user's rating of a book with an optional text. A user reviews a book once,
+// the editions of the same work share the review.
+type Review struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserGoogleId string `gorm:"not null;uniqueIndex:idx_reviews_user_book"`
+	User         User   `gorm:"foreignKey:UserGoogleId;constraint:OnDelete:CASCADE;"`
+	BookID       string `gorm:"not null;uniqueIndex:idx_reviews_user_book;index"`
+	Book         Book   `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;"`
+	Rating       float64
+	Text         string
+	// Spoiler hides the text until the reader asks for it
+	Spoiler   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func ValidateRating(rating float64) error {
+	if rating < ReviewMinRating || rating > ReviewMaxRating || math.Mod(rating, ReviewRatingStep) != 0 {
+		return ErrReviewInvalidRating
+	}
+	return nil
+}
+
+// Edited tells if the review was changed after it was written
+func (r *Review) Edited() bool {
+	return r.UpdatedAt.Sub(r.CreatedAt) > time.Second
+}
+
+// ReviewStats sums up the ratings of a book, Histogram counts the reviews of every rating
+// from ReviewMinRating to ReviewMaxRating in ReviewRatingStep steps
+type ReviewStats struct {
+	Average   float64
+	Count     int
+	Histogram []int
+}
+
+func NewReviewStats(reviews []*Review) *ReviewStats {
+	stats := &ReviewStats{
+		Histogram: make([]int, int((ReviewMaxRating-ReviewMinRating)/ReviewRatingStep)+1),
+	}
+	sum := 0.0
+	for _, review := range reviews {
+		if ValidateRating(review.Rating) != nil {
+			continue
+		}
+		stats.Histogram[stats.bucket(review.Rating)]++
+		stats.Count++
+		sum += review.Rating
+	}
+	if stats.Count > 0 {
+		stats.Average = sum / float64(stats.Count)
+	}
+	return stats
+}
+
+func (s *ReviewStats) bucket(rating float64) int {
+	return int((rating - ReviewMinRating) / ReviewRatingStep)
+}
+
+// Rating returns the rating of the histogram bucket
+func (s *ReviewStats) Rating(bucket int) float64 {
+	return ReviewMinRating + float64(bucket)*ReviewRatingStep
+}
+
+// Share returns the part of the reviews in the histogram bucket as a percentage
+func (s *ReviewStats) Share(bucket int) int {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Histogram[bucket] * 100 / s.Count
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+type PrintType string
+
+const (
+	PrintTypeAll       PrintType = ""
+	PrintTypeBooks     PrintType = "books"
+	PrintTypeMagazines PrintType = "magazines"
+)
+
+type SearchOrder string
+
+const (
+	SearchOrderRelevance SearchOrder = ""
+	SearchOrderNewest    SearchOrder = "newest"
+)
+
+// SearchFilters narrow a search down. Providers apply the filters they support in the request,
+// Apply filters the results for the ones they do not.
+type SearchFilters struct {
+	// Language is an ISO 639-1 code
+	Language  string
+	PrintType PrintType
+	OrderBy   SearchOrder
+	// PublishedFrom and PublishedTo are inclusive years, zero leaves the range open
+	PublishedFrom int
+	PublishedTo   int
+	MinPages      int
+}
+
+// Matches reports whether the book passes the filters, books missing the filtered field never pass.
+// Books carry no print type, so it is left to the providers.
+func (f SearchFilters) Matches(book *Book) bool {
+	if f.Language != "" && book.Language != f.Language {
+		return false
+	}
+	if f.MinPages > 0 && book.Pages < f.MinPages {
+		return false
+	}
+	if f.PublishedFrom > 0 || f.PublishedTo > 0 {
+		year := book.PublishedYear()
+		if year == 0 || (f.PublishedFrom > 0 && year < f.PublishedFrom) || (f.PublishedTo > 0 && year > f.PublishedTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply drops the books that do not match. The order is left to the providers, sorting a single page
+// would not order the results across pages.
+func (f SearchFilters) Apply(books []*Book) []*Book {
+	filtered := make([]*Book, 0, len(books))
+	for _, book := range books {
+		if f.Matches(book) {
+			filtered = append(filtered, book)
+		}
+	}
+	return filtered
+}
+
+// SortNewest orders the books by their published date, the latest first
+func SortNewest(books []*Book) {
+	// dates come as "2005", "2005-08" or "2005-08-02", comparing them as strings orders them by date
+	slices.SortStableFunc(books, func(a, b *Book) int {
+		return cmp.Compare(b.PublishedDate, a.PublishedDate)
+	})
+}
+
+// Values encodes the filters as the search form fields, see handlers bindSearchFilters
+func (f SearchFilters) Values() url.Values {
+	values := url.Values{}
+	if f.Language != "" {
+		values.Set("language", f.Language)
+	}
+	if f.PrintType != PrintTypeAll {
+		values.Set("print-type", string(f.PrintType))
+	}
+	if f.OrderBy != SearchOrderRelevance {
+		values.Set("order-by", string(f.OrderBy))
+	}
+	if f.PublishedFrom > 0 {
+		values.Set("published-from", strconv.Itoa(f.PublishedFrom))
+	}
+	if f.PublishedTo > 0 {
+		values.Set("published-to", strconv.Itoa(f.PublishedTo))
+	}
+	if f.MinPages > 0 {
+		values.Set("min-pages", strconv.Itoa(f.MinPages))
+	}
+	return values
+}
+
+// Key identifies the filters in cache keys
+func (f SearchFilters) Key() string {
+	return fmt.Sprintf("%s|%s|%s|%d|%d|%d", f.Language, f.PrintType, f.OrderBy, f.PublishedFrom, f.PublishedTo, f.MinPages)
+}
+
+// PublishedYear reads the year the published date starts with, zero when it has none
+func (b *Book) PublishedYear() int {
+	if len(b.PublishedDate) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(b.PublishedDate[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeriesNameEmpty       = errors.New("series name is required")
+	ErrSeriesInvalidPosition = errors.New("the volume number must be greater than 0")
+	ErrSeriesEntryNotFound   = errors.New("the book is not in this series or was not added by you")
+)
+
+// Series orders the books of a cycle, the editions of a volume can share its position
+type Series struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+	// Key is the normalized name, see SeriesKey
+	Key string `gorm:"uniqueIndex;not null"`
+	// ProviderSeriesID is the id the provider groups the volumes under, prefixed with the provider name
+	ProviderSeriesID string        `gorm:"index"`
+	Entries          []SeriesEntry `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// SeriesEntry puts a book in a series, CreatedBy is the google id of the user who added it,
+// empty when it came from a provider
+type SeriesEntry struct {
+	SeriesID  uint    `gorm:"primaryKey"`
+	BookID    string  `gorm:"primaryKey"`
+	Book      Book    `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;"`
+	Position  float64 `gorm:"not null"`
+	CreatedBy string
+}
+
+// SeriesInfo is the series a provider puts a book in, it is saved with the book as a SeriesEntry
+type SeriesInfo struct {
+	Name       string  `json:"name,omitempty"`
+	ProviderID string  `json:"provider_id,omitempty"`
+	Position   float64 `json:"position"`
+}
+
+// NextVolume is the first volume of a series after the last one the user has finished
+type NextVolume struct {
+	Series   *Series
+	Book     *Book
+	Position float64
+}
+
+func SeriesKey(name string) string {
+	return strings.Join(normalizedWords(name), " ")
+}
+
+// FormatPosition writes whole volume numbers without decimals, novellas between volumes keep them
+func FormatPosition(position float64) string {
+	return strconv.FormatFloat(position, 'f', -1, 64)
+}
+
+var seriesPosition = regexp.MustCompile(`^(.*?)[\s,;:(#-]*(?:(?i:book|vol\.?|volume|tom|part|no\.?)\s*)?#?\s*(\d+(?:\.\d+)?)\)?\s*$`)
+
+// ParseSeries reads the series strings of Open Library, "The Witcher ; 1", "Wiedźmin (tom 2)" or
+// "Discworld #3". A name without a number is not a series entry and gives nil.
+func ParseSeries(series string) *SeriesInfo {
+	match := seriesPosition.FindStringSubmatch(strings.TrimSpace(series))
+	if match == nil {
+		return nil
+	}
+	name := strings.TrimSpace(strings.TrimRight(match[1], " ,;:(#-"))
+	position, err := strconv.ParseFloat(match[2], 64)
+	if name == "" || err != nil || position <= 0 {
+		return nil
+	}
+	return &SeriesInfo{Name: name, Position: position}
+}
+
+// SortEntries orders the entries by position, then by title
+func (s *Series) SortEntries() {
+	slices.SortStableFunc(s.Entries, func(a, b SeriesEntry) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.Book.Title, b.Book.Title))
+	})
+}
+
+// NextVolume returns the first volume after the last one the user has finished that they have
+// not finished yet, a volume counts as finished when any of its editions is. Series the user
+// has not started give nil.
+func (s *Series) NextVolume(userBooks []*UserBook) *NextVolume {
+	s.SortEntries()
+	finished := map[float64]bool{}
+	last := -1.0
+	for _, entry := range s.Entries {
+		if UserBookFor(&entry.Book, userBooks).IsRead() {
+			finished[entry.Position] = true
+			last = max(last, entry.Position)
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	var next *NextVolume
+	for i := range s.Entries {
+		entry := &s.Entries[i]
+		if entry.Position <= last || finished[entry.Position] {
+			continue
+		}
+		if next != nil && entry.Position != next.Position {
+			break
+		}
+		// the user's own edition of the volume is preferred
+		if next == nil || (UserBookFor(&entry.Book, userBooks) != nil && UserBookFor(next.Book, userBooks) == nil) {
+			next = &NextVolume{Series: s, Book: &entry.Book, Position: entry.Position}
+		}
+	}
+	return next
+}
@@ -24,6 +24,8 @@ type User struct {
     Genres           []Genre           `gorm:"many2many:user_genres;constraint:OnDelete:CASCADE;"` // CASCADE delete on user_genres
 	AvatarURL        string
 	Location         *Location `gorm:"foreignKey:UserGoogleId;constraint:OnDelete:CASCADE;"`
+	// RecommendationFeedback is only declared for the cascade, it is never preloaded
+	RecommendationFeedback []RecommendationFeedback `gorm:"foreignKey:UserGoogleId;constraint:OnDelete:CASCADE;"`
 }
 
 type Location struct {
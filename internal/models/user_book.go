@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -9,14 +10,157 @@ import (
 var (
 	ErrUserBookQueryWithoutId          = errors.New("user book ID not provided in query parameters")
 	ErrUserBookInActiveExchangeRequest = errors.New("user book in active exchange request")
+	ErrUserBookUnknownStatus           = errors.New("unknown reading status, use want_to_read, reading, read, did_not_finish or on_hold")
+	ErrUserBookNotOwner                = errors.New("you can only change your own books")
 )
 
+type UserBookStatus string
+
+const (
+	UserBookStatusWantToRead   UserBookStatus = "want_to_read"
+	UserBookStatusReading      UserBookStatus = "reading"
+	UserBookStatusRead         UserBookStatus = "read"
+	UserBookStatusDidNotFinish UserBookStatus = "did_not_finish"
+	UserBookStatusOnHold       UserBookStatus = "on_hold"
+)
+
+// UserBookStatuses are the shelves in the order they are shown
+var UserBookStatuses = []UserBookStatus{
+	UserBookStatusWantToRead,
+	UserBookStatusReading,
+	UserBookStatusOnHold,
+	UserBookStatusRead,
+	UserBookStatusDidNotFinish,
+}
+
+// ParseUserBookStatus reads the status of a form or a query, an empty status gives an empty status
+func ParseUserBookStatus(status string) (UserBookStatus, error) {
+	switch UserBookStatus(status) {
+	case "", UserBookStatusWantToRead, UserBookStatusReading, UserBookStatusRead, UserBookStatusDidNotFinish, UserBookStatusOnHold:
+		return UserBookStatus(status), nil
+	default:
+		return "", ErrUserBookUnknownStatus
+	}
+}
+
+func (s UserBookStatus) String() string {
+	return string(s)
+}
+
+// Label is the name of the shelf shown to the user, an empty status labels every shelf
+func (s UserBookStatus) Label() string {
+	switch s {
+	case UserBookStatusWantToRead:
+		return "Want to read"
+	case UserBookStatusReading:
+		return "Reading"
+	case UserBookStatusRead:
+		return "Read"
+	case UserBookStatusDidNotFinish:
+		return "Did not finish"
+	case UserBookStatusOnHold:
+		return "On hold"
+	}
+	return "All"
+}
+
 type UserBook struct {
 	gorm.Model
 	UserGoogleId    string           `gorm:"not null;"`
 	BookID          string           `gorm:"not null;"`
 	Book            Book             `gorm:"foreignKey:BookID;constraint:OnDelete:SET NULL;"`
 	ReadingProgress *ReadingProgress `gorm:"foreignKey:UserBookID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Status          UserBookStatus   `gorm:"index"`
+	StatusChangedAt *time.Time
+	// StartedAt is the first time the book moved to reading, FinishedAt the last time it moved to read
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+	StatusChanges []UserBookStatusChange `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// UserBookStatusChange records the day a book moved from one shelf to another
+type UserBookStatusChange struct {
+	ID         uint `gorm:"primaryKey"`
+	UserBookID uint `gorm:"not null;index"`
+	From       UserBookStatus
+	To         UserBookStatus `gorm:"not null"`
+	ChangedAt  time.Time
+}
+
+// BeforeCreate puts a new book on the want to read shelf, or on the read or reading shelf
+// when it comes with its progress
+func (ub *UserBook) BeforeCreate(tx *gorm.DB) error {
+	if ub.Status != "" {
+		return nil
+	}
+	status := UserBookStatusWantToRead
+	if ub.ReadingProgress != nil {
+		status = UserBookStatusReading
+		if ub.ReadingProgress.Completed {
+			status = UserBookStatusRead
+		}
+	}
+	ub.SetStatus(status, time.Now())
+	return nil
+}
+
+// SetStatus moves the book to the shelf and adds the move to StatusChanges,
+// it returns false when the book is already there
+func (ub *UserBook) SetStatus(status UserBookStatus, at time.Time) bool {
+	if ub.Status == status {
+		return false
+	}
+	ub.StatusChanges = append(ub.StatusChanges, UserBookStatusChange{
+		UserBookID: ub.ID,
+		From:       ub.Status,
+		To:         status,
+		ChangedAt:  at,
+	})
+	ub.Status = status
+	ub.StatusChangedAt = &at
+	switch status {
+	case UserBookStatusReading:
+		if ub.StartedAt == nil {
+			ub.StartedAt = &at
+		}
+	case UserBookStatusRead:
+		ub.FinishedAt = &at
+		if ub.StartedAt == nil {
+			ub.StartedAt = &at
+		}
+	}
+	return true
+}
+
+// MoveUserBook saves the book on the shelf together with the move, it is shared by the
+// reading progress hooks and the user book repository
+func MoveUserBook(db *gorm.DB, userBookID uint, status UserBookStatus) (*UserBook, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+	userBook := &UserBook{}
+	if err := db.First(userBook, userBookID).Error; err != nil {
+		return nil, err
+	}
+	if !userBook.SetStatus(status, time.Now()) {
+		return userBook, nil
+	}
+	if err := db.Omit("Book", "ReadingProgress").Save(userBook).Error; err != nil {
+		return nil, err
+	}
+	return userBook, nil
+}
+
+// FilterByStatus keeps the books on the shelf, an empty status keeps every book
+func FilterByStatus(userBooks []*UserBook, status UserBookStatus) []*UserBook {
+	if status == "" {
+		return userBooks
+	}
+	filtered := []*UserBook{}
+	for _, userBook := range userBooks {
+		if userBook.Status == status {
+			filtered = append(filtered, userBook)
+		}
+	}
+	return filtered
 }
 
 func BookInUserBooks(bookID string, userBooks []*UserBook) bool {
@@ -27,3 +171,22 @@ func BookInUserBooks(bookID string, userBooks []*UserBook) bool {
 	}
 	return false
 }
+
+// UserBookFor returns the user's copy of the book, another edition of the same work counts
+func UserBookFor(book *Book, userBooks []*UserBook) *UserBook {
+	var edition *UserBook
+	for _, userBook := range userBooks {
+		if userBook.BookID == book.ID {
+			return userBook
+		}
+		if edition == nil && book.WorkID != nil && userBook.Book.WorkID != nil && *userBook.Book.WorkID == *book.WorkID {
+			edition = userBook
+		}
+	}
+	return edition
+}
+
+// IsRead says if the user has finished the book
+func (ub *UserBook) IsRead() bool {
+	return ub != nil && (ub.Status == UserBookStatusRead || (ub.ReadingProgress != nil && ub.ReadingProgress.Completed))
+}
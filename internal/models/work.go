@@ -0,0 +1,86 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// Work groups the editions of the same title, every Book is a single edition of a Work
+type Work struct {
+	gorm.Model
+	Title   string
+	Authors string
+	// MatchKey is the normalized title and first author, see WorkMatchKey
+	MatchKey string `gorm:"index"`
+	// ProviderWorkID is the id the provider groups editions under, prefixed with the provider name
+	ProviderWorkID string `gorm:"index"`
+	Editions       []Book `gorm:"foreignKey:WorkID"`
+}
+
+// WorkMatchKey builds the key used to recognize editions of the same work that share no identifiers.
+// The subtitle and leading article are dropped from the title, the first author's name parts are
+// sorted so "Herbert, Frank" and "Frank Herbert" give the same key.
+func WorkMatchKey(title, authors string) string {
+	if i := strings.IndexAny(title, ":(["); i > 0 {
+		title = title[:i]
+	}
+	titleWords := normalizedWords(title)
+	if len(titleWords) > 1 && slices.Contains([]string{"the", "a", "an"}, titleWords[0]) {
+		titleWords = titleWords[1:]
+	}
+	if len(titleWords) == 0 {
+		return ""
+	}
+
+	// authors are joined with ", ", unless the first part is a lone surname from a "Last, First" entry
+	parts := strings.Split(authors, ",")
+	firstAuthor := parts[0]
+	if len(normalizedWords(firstAuthor)) == 1 && len(parts) > 1 {
+		firstAuthor += " " + parts[1]
+	}
+	authorWords := normalizedWords(firstAuthor)
+	slices.Sort(authorWords)
+
+	return strings.Join(titleWords, " ") + "|" + strings.Join(authorWords, " ")
+}
+
+// DedupeByWork keeps the first edition of every work, books without a work are always kept
+func DedupeByWork(books []*Book) []*Book {
+	seen := make(map[uint]bool)
+	deduped := make([]*Book, 0, len(books))
+	for _, book := range books {
+		if book.WorkID != nil {
+			if seen[*book.WorkID] {
+				continue
+			}
+			seen[*book.WorkID] = true
+		}
+		deduped = append(deduped, book)
+	}
+	return deduped
+}
+
+// letters that do not decompose into a base letter and a diacritic
+var letterReplacer = strings.NewReplacer("ł", "l", "ø", "o", "đ", "d", "ß", "ss", "æ", "ae", "œ", "oe")
+
+// normalizedWords lowercases s, removes diacritics and splits it on everything that is not a letter or digit
+func normalizedWords(s string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(letterReplacer.Replace(strings.ToLower(s))) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'':
+			continue
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
@@ -0,0 +1,233 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	DefaultCacheTTL         = 24 * time.Hour
+	DefaultCacheStaleWindow = 7 * 24 * time.Hour
+)
+
+type ProviderCacheRepository interface {
+	Get(key string) (*models.ProviderCacheEntry, error)
+	Save(entry *models.ProviderCacheEntry) error
+	DeleteOlderThan(t time.Time) error
+}
+
+type CacheStats struct {
+	Hits      int64
+	StaleHits int64
+	Misses    int64
+	Errors    int64
+}
+
+// NewCachedProvider decorates a provider with a persistent response cache.
+// Entries younger than the ttl are served directly. Entries older than the ttl but still
+// inside the stale window are served immediately while a refresh runs in the background,
+// so repeated searches keep working for a while when the provider is down.
+func NewCachedProvider(provider handlers.BookProvider, repo ProviderCacheRepository) *cachedProvider {
+	return &cachedProvider{
+		provider:    provider,
+		repo:        repo,
+		ttl:         DefaultCacheTTL,
+		staleWindow: DefaultCacheStaleWindow,
+		now:         time.Now,
+		refreshing:  make(map[string]bool),
+	}
+}
+
+type cachedProvider struct {
+	provider    handlers.BookProvider
+	repo        ProviderCacheRepository
+	ttl         time.Duration
+	staleWindow time.Duration
+	now         func() time.Time
+
+	hits      atomic.Int64
+	staleHits atomic.Int64
+	misses    atomic.Int64
+	errors    atomic.Int64
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+func (p *cachedProvider) WithTTL(ttl time.Duration) *cachedProvider {
+	p.ttl = ttl
+	return p
+}
+
+func (p *cachedProvider) WithStaleWindow(staleWindow time.Duration) *cachedProvider {
+	p.staleWindow = staleWindow
+	return p
+}
+
+func (p *cachedProvider) WithClock(now func() time.Time) *cachedProvider {
+	p.now = now
+	return p
+}
+
+func (p *cachedProvider) Stats() CacheStats {
+	return CacheStats{
+		Hits:      p.hits.Load(),
+		StaleHits: p.staleHits.Load(),
+		Misses:    p.misses.Load(),
+		Errors:    p.errors.Load(),
+	}
+}
+
+// Health reports the hits and misses of the cache since the server started
+func (p *cachedProvider) Health() map[string]string {
+	stats := p.Stats()
+	return map[string]string{
+		"hits":       strconv.FormatInt(stats.Hits, 10),
+		"stale_hits": strconv.FormatInt(stats.StaleHits, 10),
+		"misses":     strconv.FormatInt(stats.Misses, 10),
+		"errors":     strconv.FormatInt(stats.Errors, 10),
+	}
+}
+
+// Prune removes the entries that are too old to be served even as stale
+func (p *cachedProvider) Prune() error {
+	return p.repo.DeleteOlderThan(p.now().Add(-(p.ttl + p.staleWindow)))
+}
+
+func (p *cachedProvider) GetBook(ctx context.Context, bookID string) (*models.Book, error) {
+	books, err := p.cached(ctx, bookKey(bookID), func(ctx context.Context) ([]*models.Book, error) {
+		book, err := p.provider.GetBook(ctx, bookID)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.Book{book}, nil
+	})
+	if err != nil {
+		return &models.Book{}, err
+	}
+	if len(books) == 0 || books[0] == nil {
+		return &models.Book{}, fmt.Errorf("book %s not found in cache entry", bookID)
+	}
+	return books[0], nil
+}
+
+func (p *cachedProvider) GetBooksByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	return p.cached(ctx, queryKey(query, filters, limit, page), func(ctx context.Context) ([]*models.Book, error) {
+		return p.provider.GetBooksByQuery(ctx, query, filters, limit, page)
+	})
+}
+
+func (p *cachedProvider) GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error) {
+	return p.cached(ctx, genreKey(genre), func(ctx context.Context) ([]*models.Book, error) {
+		return p.provider.GetBooksByGenre(ctx, genre)
+	})
+}
+
+func (p *cachedProvider) QueryTypeToString(queryType handlers.QueryType) string {
+	return p.provider.QueryTypeToString(queryType)
+}
+
+func (p *cachedProvider) Convert(response any) *models.Book {
+	return p.provider.Convert(response)
+}
+
+func (p *cachedProvider) cached(ctx context.Context, key string, fetch func(ctx context.Context) ([]*models.Book, error)) ([]*models.Book, error) {
+	if entry, err := p.repo.Get(key); err == nil && entry != nil {
+		var books []*models.Book
+		if err := json.Unmarshal(entry.Payload, &books); err == nil {
+			age := p.now().Sub(entry.FetchedAt)
+			if age < p.ttl {
+				p.hits.Add(1)
+				return books, nil
+			}
+			if age < p.ttl+p.staleWindow {
+				p.staleHits.Add(1)
+				p.refresh(ctx, key, fetch)
+				return books, nil
+			}
+		}
+	}
+
+	p.misses.Add(1)
+	books, err := fetch(ctx)
+	if err != nil {
+		p.errors.Add(1)
+		return nil, err
+	}
+	p.store(key, books)
+	return books, nil
+}
+
+// refresh fetches the entry again in the background, at most one refresh per key runs at a time.
+// The refresh outlives the request that triggered it, so it does not inherit its cancellation.
+func (p *cachedProvider) refresh(ctx context.Context, key string, fetch func(ctx context.Context) ([]*models.Book, error)) {
+	p.mu.Lock()
+	if p.refreshing[key] {
+		p.mu.Unlock()
+		return
+	}
+	p.refreshing[key] = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.refreshing, key)
+			p.mu.Unlock()
+		}()
+
+		books, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			p.errors.Add(1)
+			log.Printf("cache refresh of %q failed: %v", key, err)
+			return
+		}
+		p.store(key, books)
+	}()
+}
+
+func (p *cachedProvider) store(key string, books []*models.Book) {
+	payload, err := json.Marshal(books)
+	if err != nil {
+		log.Printf("cache encode of %q failed: %v", key, err)
+		return
+	}
+	entry := &models.ProviderCacheEntry{
+		Key:       key,
+		Payload:   payload,
+		FetchedAt: p.now(),
+	}
+	if err := p.repo.Save(entry); err != nil {
+		log.Printf("cache save of %q failed: %v", key, err)
+	}
+}
+
+func normalizeKeyPart(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func bookKey(bookID string) string {
+	return "book|" + bookID
+}
+
+func queryKey(query handlers.SearchQuery, filters models.SearchFilters, limit, page int) string {
+	key := fmt.Sprintf("query|%s|%d|%d", query.Key(), limit, page)
+	if filters != (models.SearchFilters{}) {
+		key += "|" + filters.Key()
+	}
+	return key
+}
+
+func genreKey(genre string) string {
+	return "genre|" + normalizeKeyPart(genre)
+}
@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+var (
+	ErrNoProviders          = errors.New("composite provider has no providers configured")
+	ErrProviderBookNotFound = errors.New("no provider knows a book with this id")
+)
+
+// idOwner is implemented by providers that recognise the format of their own book ids,
+// the composite provider only asks them for ids they own
+type idOwner interface {
+	OwnsID(bookID string) bool
+}
+
+// NewCompositeProvider wraps several providers, the order of the arguments is the priority order.
+// The first provider is treated as the primary one, the rest are used as a fallback
+// and as a source of fields the primary one leaves empty.
+func NewCompositeProvider(providers ...handlers.BookProvider) handlers.BookProvider {
+	return &compositeProvider{
+		providers: providers,
+	}
+}
+
+type compositeProvider struct {
+	providers []handlers.BookProvider
+}
+
+func (p *compositeProvider) GetBook(ctx context.Context, bookID string) (*models.Book, error) {
+	if len(p.providers) == 0 {
+		return &models.Book{}, ErrNoProviders
+	}
+
+	var errs []error
+	for i, provider := range p.providers {
+		if owner, ok := provider.(idOwner); ok && !owner.OwnsID(bookID) {
+			continue
+		}
+		book, err := provider.GetBook(ctx, bookID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if book == nil {
+			errs = append(errs, ErrProviderBookNotFound)
+			continue
+		}
+		if book.HasMissingFields() {
+			p.fillFromOthers(ctx, book, i)
+		}
+		return book, nil
+	}
+
+	if len(errs) == 0 {
+		return &models.Book{}, ErrProviderBookNotFound
+	}
+	return &models.Book{}, errors.Join(errs...)
+}
+
+// fillFromOthers looks the book up by ISBN in every other provider and fills in the missing fields
+func (p *compositeProvider) fillFromOthers(ctx context.Context, book *models.Book, source int) {
+	if book.ISBN13 == "" {
+		return
+	}
+
+	for i, provider := range p.providers {
+		if i == source {
+			continue
+		}
+		books, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery(book.ISBN13, handlers.QueryTypeISBN), models.SearchFilters{}, 1, 1)
+		if err != nil {
+			continue
+		}
+		for _, other := range books {
+			if other != nil && other.ISBN13 == book.ISBN13 {
+				book.FillMissing(other)
+			}
+		}
+		if !book.HasMissingFields() {
+			return
+		}
+	}
+}
+
+// GetBooksByQuery queries all providers concurrently and merges the results by ISBN-13,
+// keeping the order of the primary provider, or the newest first when ordered by date.
+// It fails only when every provider fails.
+func (p *compositeProvider) GetBooksByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	if len(p.providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	results := make([][]*models.Book, len(p.providers))
+	errs := make([]error, len(p.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range p.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = provider.GetBooksByQuery(ctx, query, filters, limit, page)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := false
+	for _, err := range errs {
+		if err == nil {
+			succeeded = true
+		}
+	}
+	if !succeeded {
+		return nil, errors.Join(errs...)
+	}
+
+	books := mergeByISBN(results...)
+	if filters.OrderBy == models.SearchOrderNewest {
+		// every provider sorts its page, the merged page keeps that order across the providers
+		models.SortNewest(books)
+	}
+	return books, nil
+}
+
+// GetBooksByGenre returns the books of the first provider that answers
+func (p *compositeProvider) GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error) {
+	if len(p.providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	var errs []error
+	for _, provider := range p.providers {
+		books, err := provider.GetBooksByGenre(ctx, genre)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return books, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func (p *compositeProvider) QueryTypeToString(queryType handlers.QueryType) string {
+	if len(p.providers) == 0 {
+		return ""
+	}
+	return p.providers[0].QueryTypeToString(queryType)
+}
+
+func (p *compositeProvider) Convert(response any) *models.Book {
+	for _, provider := range p.providers {
+		if book := provider.Convert(response); book != nil {
+			return book
+		}
+	}
+	return nil
+}
+
+// mergeByISBN flattens the result lists in order, an edition that was already seen
+// is not added again, instead the first occurrence is completed with its data
+func mergeByISBN(results ...[]*models.Book) []*models.Book {
+	merged := []*models.Book{}
+	seen := make(map[string]*models.Book)
+
+	for _, books := range results {
+		for _, book := range books {
+			if book == nil {
+				continue
+			}
+			if book.ISBN13 != "" {
+				if first, ok := seen[book.ISBN13]; ok {
+					first.FillMissing(book)
+					continue
+				}
+				seen[book.ISBN13] = book
+			}
+			merged = append(merged, book)
+		}
+	}
+
+	return merged
+}
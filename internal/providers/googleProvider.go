@@ -1,16 +1,17 @@
 package providers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"regexp"
 	"strings"
 
 	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/isbn"
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"github.com/joho/godotenv"
 )
@@ -25,21 +26,31 @@ func init() {
 	GoogleAPIKEY = os.Getenv("GOOGLE_API_KET")
 }
 
+// google volume ids are twelve url safe base64 characters
+var googleVolumeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{12}$`)
+
 const (
 	GoogleBooksAPI          = "https://www.googleapis.com/books/v1/volumes"
 	GoogleBooksAPIMaxResult = 40
 )
 
 func NewGoogleProvider() handlers.BookProvider {
+	return NewGoogleProviderWithClient(defaultHTTPClient)
+}
+
+// NewGoogleProviderWithClient creates the provider with a custom http client, see NewHTTPClient
+func NewGoogleProviderWithClient(client *http.Client) handlers.BookProvider {
 	return &googleProvider{
 		apiUrl:     GoogleBooksAPI,
 		maxResults: GoogleBooksAPIMaxResult,
+		client:     client,
 	}
 }
 
 type googleProvider struct {
 	apiUrl     string
 	maxResults int
+	client     *http.Client
 }
 
 func (p *googleProvider) WithLimit(limit int) handlers.BookProvider {
@@ -68,6 +79,7 @@ type (
 		Subtitle      string   `json:"subtitle,omitempty"`
 		Authors       []string `json:"authors"`
 		PublishedDate string   `json:"publishedDate"`
+		Language      string   `json:"language"`
 		Description   string   `json:"description,omitempty"`
 		Pages         int      `json:"pageCount"`
 		Genres        []string `json:"categories"`
@@ -75,42 +87,57 @@ type (
 			SmallThumbnail string `json:"smallThumbnail"`
 			Thumbnail      string `json:"thumbnail"`
 		} `json:"imageLinks,omitempty"`
-		IndustryIdentifiers []struct {
-			Type       string `json:"type"`
-			Identifier string `json:"identifier"`
-		} `json:"industryIdentifiers"`
+		IndustryIdentifiers []IndustryIdentifier `json:"industryIdentifiers"`
+		SeriesInfo          *struct {
+			VolumeSeries []struct {
+				SeriesID    string  `json:"seriesId"`
+				OrderNumber float64 `json:"orderNumber"`
+			} `json:"volumeSeries"`
+		} `json:"seriesInfo,omitempty"`
 	}
-)
 
-func (p *googleProvider) getResponse(url string) (*http.Response, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+	SeriesResponse struct {
+		Series []struct {
+			SeriesID string `json:"seriesId"`
+			Title    string `json:"title"`
+		} `json:"series"`
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	IndustryIdentifier struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
 	}
+)
 
-	return resp, nil
+func (p *googleProvider) OwnsID(bookID string) bool {
+	return googleVolumeIDPattern.MatchString(bookID) && !IsOpenLibraryID(bookID)
 }
 
-func (p *googleProvider) GetBook(bookID string) (*models.Book, error) {
+func (p *googleProvider) GetBook(ctx context.Context, bookID string) (*models.Book, error) {
 	url := fmt.Sprintf(p.apiUrl+"/%s", bookID)
 
-	response, err := p.getResponse(url)
-	if err != nil {
+	var bookResponse BookResponse
+	if err := getJSON(ctx, p.client, url, &bookResponse); err != nil {
 		return &models.Book{}, err
 	}
-	defer response.Body.Close()
 
-	var bookResponse BookResponse
-	if err := json.NewDecoder(response.Body).Decode(&bookResponse); err != nil {
-		return &models.Book{}, err
+	book := p.Convert(bookResponse)
+	// volumes only carry the series id, the name takes another request
+	if book.Series != nil {
+		book.Series.Name = p.seriesName(ctx, strings.TrimPrefix(book.Series.ProviderID, "google:"))
 	}
+	return book, nil
+}
+
+// seriesName returns the title of the series, empty when google does not know it
+func (p *googleProvider) seriesName(ctx context.Context, seriesID string) string {
+	url := strings.TrimSuffix(p.apiUrl, "/volumes") + "/series/get?series_id=" + url.QueryEscape(seriesID)
 
-	return p.Convert(bookResponse), nil
+	var seriesResponse SeriesResponse
+	if err := getJSON(ctx, p.client, url, &seriesResponse); err != nil || len(seriesResponse.Series) == 0 {
+		return ""
+	}
+	return seriesResponse.Series[0].Title
 }
 
 func (p *googleProvider) QueryTypeToString(queryType handlers.QueryType) string {
@@ -122,32 +149,35 @@ func (p *googleProvider) QueryTypeToString(queryType handlers.QueryType) string
 	case handlers.QueryTypeSubject:
 		return "subject:"
 	case handlers.QueryTypeISBN:
+		return "isbn:"
+	case handlers.QueryTypeText:
 		return ""
 	default:
 		return "intitle:"
 	}
 }
 
-func (p *googleProvider) GetBooksByQuery(query string, queryType handlers.QueryType, limit, page int) ([]*models.Book, error) {
+func (p *googleProvider) GetBooksByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
 	startIndex := (page - 1) * limit
 	params := url.Values{}
-	urlRequest := fmt.Sprintf("%s\"%s\"", p.QueryTypeToString(queryType), query)
 
-	params.Add("q", urlRequest)
+	params.Add("q", p.QueryString(query))
 	params.Add("maxResults", fmt.Sprintf("%d", limit))
 	params.Add("startIndex", fmt.Sprintf("%d", startIndex))
-	encodedUrl := p.apiUrl + "?" + params.Encode()
-
-	response, err := p.getResponse(encodedUrl)
-	if err != nil {
-		return nil, err
+	// google has no published date or page count filters, those are applied to the results
+	if filters.Language != "" {
+		params.Add("langRestrict", filters.Language)
 	}
-	if response != nil {
-		defer response.Body.Close()
+	if filters.PrintType != models.PrintTypeAll {
+		params.Add("printType", string(filters.PrintType))
 	}
+	if filters.OrderBy == models.SearchOrderNewest {
+		params.Add("orderBy", "newest")
+	}
+	encodedUrl := p.apiUrl + "?" + params.Encode()
 
 	var bookItemsResponse BookItemsResponse
-	if err := json.NewDecoder(response.Body).Decode(&bookItemsResponse); err != nil {
+	if err := getJSON(ctx, p.client, encodedUrl, &bookItemsResponse); err != nil {
 		return nil, err
 	}
 
@@ -159,19 +189,35 @@ func (p *googleProvider) GetBooksByQuery(query string, queryType handlers.QueryT
 	return books, nil
 }
 
-func (p *googleProvider) GetBooksByGenre(genre string) ([]*models.Book, error) {
+// QueryString translates the query to the volumes q parameter. The field keywords only apply to the term
+// right after them, so every word gets its own keyword, "dune messiah" by herbert becomes
+// intitle:dune intitle:messiah inauthor:herbert. Subjects are category names and are kept whole.
+func (p *googleProvider) QueryString(query handlers.SearchQuery) string {
+	var terms []string
+	for _, field := range query.Fields() {
+		keyword := p.QueryTypeToString(field.Type)
+		if field.Type == handlers.QueryTypeSubject || field.Type == handlers.QueryTypeISBN {
+			value := field.Value
+			if field.Type == handlers.QueryTypeSubject && !handlers.IsPhrase(value) && strings.Contains(value, " ") {
+				value = `"` + strings.ReplaceAll(value, `"`, "") + `"`
+			}
+			terms = append(terms, keyword+value)
+			continue
+		}
+		for _, term := range handlers.SplitTerms(field.Value) {
+			terms = append(terms, keyword+term)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func (p *googleProvider) GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error) {
 	query := url.QueryEscape(genre)
 	url := fmt.Sprintf(p.apiUrl+"?q=subject:%s&maxResults=%d&key=%s", query, p.maxResults, GoogleAPIKEY)
 	fmt.Printf("google genres url: %s\n", url)
 
-	response, err := p.getResponse(url)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
 	var bookItemsResponse BookItemsResponse
-	if err := json.NewDecoder(response.Body).Decode(&bookItemsResponse); err != nil {
+	if err := getJSON(ctx, p.client, url, &bookItemsResponse); err != nil {
 		return nil, err
 	}
 
@@ -188,15 +234,7 @@ func (p *googleProvider) Convert(br any) *models.Book {
 	if !ok {
 		return nil
 	}
-	var isbnString string
-
 	volumeInfo := bookResponse.VolumeInfo
-	for _, id := range volumeInfo.IndustryIdentifiers {
-		if id.Type == "ISBN_13" {
-			isbnString = id.Identifier
-			break
-		}
-	}
 
 	title := volumeInfo.Title
 	if volumeInfo.Subtitle != "" {
@@ -219,13 +257,32 @@ func (p *googleProvider) Convert(br any) *models.Book {
 		ImageLink:     volumeInfo.ImageLinks.SmallThumbnail,
 		PublishedDate: volumeInfo.PublishedDate,
 		Pages:         volumeInfo.Pages,
+		Language:      models.NormalizeLanguage(volumeInfo.Language),
 		Genres:        genres,
 	}
-	if isbn, err := strconv.ParseUint(isbnString, 10, 0); err != nil {
-		book.ISBN = uint(isbn)
+	book.SetISBN(industryIdentifiersISBN(volumeInfo.IndustryIdentifiers))
+	if volumeInfo.SeriesInfo != nil && len(volumeInfo.SeriesInfo.VolumeSeries) > 0 {
+		series := volumeInfo.SeriesInfo.VolumeSeries[0]
+		book.Series = &models.SeriesInfo{ProviderID: "google:" + series.SeriesID, Position: series.OrderNumber}
 	}
 
 	return book
 }
 
-
+// industryIdentifiersISBN returns the ISBN-13 of the volume, or the ISBN-10 converted when it has none
+func industryIdentifiersISBN(identifiers []IndustryIdentifier) isbn.ISBN {
+	var fallback isbn.ISBN
+	for _, id := range identifiers {
+		parsed, err := isbn.Parse(id.Identifier)
+		if err != nil {
+			continue
+		}
+		switch id.Type {
+		case "ISBN_13":
+			return parsed
+		case "ISBN_10":
+			fallback = parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// DefaultRequestTimeout bounds a whole provider request, including reading the body
+const DefaultRequestTimeout = 10 * time.Second
+
+// NewHTTPClient creates the client the providers use, every phase of the request has its own limit
+// so a provider that stops answering does not hold the handler until the server write timeout
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+var defaultHTTPClient = NewHTTPClient(DefaultRequestTimeout)
+
+// getJSON requests the url with the context, so a cancelled request aborts the call, and decodes the response
+func getJSON(ctx context.Context, client *http.Client, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the transport already retried these, the provider is over quota or down for now
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return fmt.Errorf("%s: %w", resp.Status, models.ErrProviderUnavailable)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,518 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	OpenLibraryAPI          = "https://openlibrary.org"
+	OpenLibraryCoversAPI    = "https://covers.openlibrary.org"
+	OpenLibraryAPIMaxResult = 40
+
+	// subjects on Open Library are user generated and there can be dozens of them per work,
+	// only the first few are kept so the genres table does not explode
+	openLibraryMaxGenres = 5
+	searchFields         = "key,title,subtitle,author_name,isbn,number_of_pages_median,subject,cover_i,first_publish_year,language"
+)
+
+var (
+	ErrOpenLibraryUnknownID = errors.New("unknown open library id, expected a work (OL...W) or an edition (OL...M) key")
+
+	openLibraryIDPattern = regexp.MustCompile(`^OL\d+[WM]$`)
+)
+
+// IsOpenLibraryID says if the id is an Open Library work or edition key
+func IsOpenLibraryID(id string) bool {
+	return openLibraryIDPattern.MatchString(id)
+}
+
+func NewOpenLibraryProvider() handlers.BookProvider {
+	return NewOpenLibraryProviderWithURL(OpenLibraryAPI)
+}
+
+// NewOpenLibraryProviderWithURL creates the provider against a custom api url,
+// used to point the provider at a local server in tests.
+func NewOpenLibraryProviderWithURL(apiUrl string) handlers.BookProvider {
+	return NewOpenLibraryProviderWithClient(apiUrl, defaultHTTPClient)
+}
+
+// NewOpenLibraryProviderWithClient creates the provider with a custom api url and http client, see NewHTTPClient
+func NewOpenLibraryProviderWithClient(apiUrl string, client *http.Client) handlers.BookProvider {
+	return &openLibraryProvider{
+		apiUrl:     strings.TrimSuffix(apiUrl, "/"),
+		coversUrl:  OpenLibraryCoversAPI,
+		maxResults: OpenLibraryAPIMaxResult,
+		client:     client,
+	}
+}
+
+type openLibraryProvider struct {
+	apiUrl     string
+	coversUrl  string
+	maxResults int
+	client     *http.Client
+}
+
+// Open Library response structs
+
+type (
+	OpenLibrarySearchResponse struct {
+		NumFound int              `json:"numFound"`
+		Docs     []OpenLibraryDoc `json:"docs"`
+	}
+
+	OpenLibraryDoc struct {
+		Key              string   `json:"key"`
+		Title            string   `json:"title"`
+		Subtitle         string   `json:"subtitle,omitempty"`
+		AuthorNames      []string `json:"author_name"`
+		ISBN             []string `json:"isbn"`
+		Pages            int      `json:"number_of_pages_median"`
+		Subjects         []string `json:"subject"`
+		CoverID          int      `json:"cover_i"`
+		FirstPublishYear int      `json:"first_publish_year"`
+		Languages        []string `json:"language"`
+	}
+
+	OpenLibraryKey struct {
+		Key string `json:"key"`
+	}
+
+	OpenLibraryWork struct {
+		Key              string          `json:"key"`
+		Title            string          `json:"title"`
+		Subtitle         string          `json:"subtitle,omitempty"`
+		Description      OpenLibraryText `json:"description"`
+		Subjects         []string        `json:"subjects"`
+		Covers           []int           `json:"covers"`
+		FirstPublishDate string          `json:"first_publish_date"`
+		Authors          []struct {
+			Author OpenLibraryKey `json:"author"`
+		} `json:"authors"`
+
+		// filled by the provider, works only reference authors and editions by key
+		AuthorNames []string            `json:"-"`
+		Edition     *OpenLibraryEdition `json:"-"`
+	}
+
+	OpenLibraryEdition struct {
+		Key         string           `json:"key"`
+		Title       string           `json:"title"`
+		Subtitle    string           `json:"subtitle,omitempty"`
+		Description OpenLibraryText  `json:"description"`
+		Authors     []OpenLibraryKey `json:"authors"`
+		Works       []OpenLibraryKey `json:"works"`
+		ISBN13      []string         `json:"isbn_13"`
+		ISBN10      []string         `json:"isbn_10"`
+		Pages       int              `json:"number_of_pages"`
+		PublishDate string           `json:"publish_date"`
+		Covers      []int            `json:"covers"`
+		Subjects    []string         `json:"subjects"`
+		Languages   []OpenLibraryKey `json:"languages"`
+		Series      []string         `json:"series"`
+
+		AuthorNames []string `json:"-"`
+	}
+
+	OpenLibraryEditionsResponse struct {
+		Entries []OpenLibraryEdition `json:"entries"`
+	}
+
+	OpenLibraryAuthor struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	}
+
+	OpenLibrarySubjectResponse struct {
+		Name  string                   `json:"name"`
+		Works []OpenLibrarySubjectWork `json:"works"`
+	}
+
+	OpenLibrarySubjectWork struct {
+		Key     string `json:"key"`
+		Title   string `json:"title"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		CoverID          int      `json:"cover_id"`
+		Subjects         []string `json:"subject"`
+		FirstPublishYear int      `json:"first_publish_year"`
+	}
+
+	// OpenLibraryText is returned either as a plain string or as {"type": "/type/text", "value": "..."}
+	OpenLibraryText string
+)
+
+func (t *OpenLibraryText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = OpenLibraryText(text)
+		return nil
+	}
+
+	var typed struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return err
+	}
+	*t = OpenLibraryText(typed.Value)
+	return nil
+}
+
+func (p *openLibraryProvider) getJSON(ctx context.Context, url string, target any) error {
+	return getJSON(ctx, p.client, url, target)
+}
+
+func (p *openLibraryProvider) OwnsID(bookID string) bool {
+	return IsOpenLibraryID(bookID)
+}
+
+func (p *openLibraryProvider) GetBook(ctx context.Context, bookID string) (*models.Book, error) {
+	if !IsOpenLibraryID(bookID) {
+		return &models.Book{}, ErrOpenLibraryUnknownID
+	}
+	if strings.HasSuffix(bookID, "W") {
+		return p.getWork(ctx, bookID)
+	}
+	return p.getEdition(ctx, bookID)
+}
+
+func (p *openLibraryProvider) getWork(ctx context.Context, workID string) (*models.Book, error) {
+	var work OpenLibraryWork
+	if err := p.getJSON(ctx, fmt.Sprintf("%s/works/%s.json", p.apiUrl, workID), &work); err != nil {
+		return &models.Book{}, err
+	}
+
+	authorKeys := make([]string, len(work.Authors))
+	for i, author := range work.Authors {
+		authorKeys[i] = author.Author.Key
+	}
+	work.AuthorNames = p.getAuthorNames(ctx, authorKeys)
+
+	// works carry no isbn or page count, those are taken from the first edition that has them
+	var editions OpenLibraryEditionsResponse
+	if err := p.getJSON(ctx, fmt.Sprintf("%s/works/%s/editions.json?limit=10", p.apiUrl, workID), &editions); err == nil {
+		for i := range editions.Entries {
+			edition := &editions.Entries[i]
+			if edition.Pages > 0 || len(edition.ISBN13) > 0 {
+				work.Edition = edition
+				break
+			}
+		}
+	}
+
+	return p.Convert(work), nil
+}
+
+func (p *openLibraryProvider) getEdition(ctx context.Context, editionID string) (*models.Book, error) {
+	var edition OpenLibraryEdition
+	if err := p.getJSON(ctx, fmt.Sprintf("%s/books/%s.json", p.apiUrl, editionID), &edition); err != nil {
+		return &models.Book{}, err
+	}
+
+	authorKeys := make([]string, len(edition.Authors))
+	for i, author := range edition.Authors {
+		authorKeys[i] = author.Key
+	}
+	edition.AuthorNames = p.getAuthorNames(ctx, authorKeys)
+
+	return p.Convert(edition), nil
+}
+
+func (p *openLibraryProvider) getAuthorNames(ctx context.Context, keys []string) []string {
+	names := []string{}
+	for _, key := range keys {
+		var author OpenLibraryAuthor
+		if err := p.getJSON(ctx, fmt.Sprintf("%s%s.json", p.apiUrl, key), &author); err != nil {
+			continue
+		}
+		names = append(names, author.Name)
+	}
+	return names
+}
+
+// QueryTypeToString returns the search.json parameter used for the given query type
+func (p *openLibraryProvider) QueryTypeToString(queryType handlers.QueryType) string {
+	switch queryType {
+	case handlers.QueryTypeTitle:
+		return "title"
+	case handlers.QueryTypeAuthor:
+		return "author"
+	case handlers.QueryTypeSubject:
+		return "subject"
+	case handlers.QueryTypeISBN:
+		return "isbn"
+	case handlers.QueryTypeText:
+		return "q"
+	default:
+		return "title"
+	}
+}
+
+// GetBooksByQuery sends every field as its own parameter, search.json matches them all and understands quoted phrases
+func (p *openLibraryProvider) GetBooksByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	// open library only catalogues books
+	if filters.PrintType == models.PrintTypeMagazines {
+		return nil, nil
+	}
+
+	params := url.Values{}
+	for _, field := range query.Fields() {
+		params.Add(p.QueryTypeToString(field.Type), field.Value)
+	}
+	params.Add("fields", searchFields)
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("page", strconv.Itoa(page))
+	language := models.MARCLanguage(filters.Language)
+	if language != "" {
+		params.Add("language", language)
+	}
+	if filters.OrderBy == models.SearchOrderNewest {
+		params.Add("sort", "new")
+	}
+	if ranges := openLibraryRanges(filters); ranges != "" {
+		if q := params.Get("q"); q != "" {
+			ranges = "(" + q + ") AND " + ranges
+		}
+		params.Set("q", ranges)
+	}
+
+	var searchResponse OpenLibrarySearchResponse
+	if err := p.getJSON(ctx, p.apiUrl+"/search.json?"+params.Encode(), &searchResponse); err != nil {
+		return nil, err
+	}
+
+	var books []*models.Book
+	for _, doc := range searchResponse.Docs {
+		book := p.Convert(doc)
+		// works list every language they were published in, the requested one is the edition we are after
+		if language != "" && slices.Contains(doc.Languages, language) {
+			book.Language = filters.Language
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// openLibraryRanges writes the published year and page filters as solr ranges of the q parameter,
+// they are checked against the same fields the books are converted from
+func openLibraryRanges(filters models.SearchFilters) string {
+	bound := func(n int) string {
+		if n <= 0 {
+			return "*"
+		}
+		return strconv.Itoa(n)
+	}
+	ranges := []string{}
+	if filters.PublishedFrom > 0 || filters.PublishedTo > 0 {
+		ranges = append(ranges, fmt.Sprintf("first_publish_year:[%s TO %s]", bound(filters.PublishedFrom), bound(filters.PublishedTo)))
+	}
+	if filters.MinPages > 0 {
+		ranges = append(ranges, fmt.Sprintf("number_of_pages_median:[%d TO *]", filters.MinPages))
+	}
+	return strings.Join(ranges, " AND ")
+}
+
+func (p *openLibraryProvider) GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error) {
+	subject := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(genre)), " ", "_")
+	subjectUrl := fmt.Sprintf("%s/subjects/%s.json?limit=%d", p.apiUrl, url.PathEscape(subject), p.maxResults)
+
+	var subjectResponse OpenLibrarySubjectResponse
+	if err := p.getJSON(ctx, subjectUrl, &subjectResponse); err != nil {
+		return nil, err
+	}
+
+	var books []*models.Book
+	for _, work := range subjectResponse.Works {
+		books = append(books, p.Convert(work))
+	}
+
+	return books, nil
+}
+
+func (p *openLibraryProvider) Convert(response any) *models.Book {
+	switch r := response.(type) {
+	case OpenLibraryDoc:
+		return p.convertDoc(r)
+	case OpenLibraryWork:
+		return p.convertWork(r)
+	case OpenLibraryEdition:
+		return p.convertEdition(r)
+	case OpenLibrarySubjectWork:
+		return p.convertSubjectWork(r)
+	}
+	return nil
+}
+
+func (p *openLibraryProvider) convertDoc(doc OpenLibraryDoc) *models.Book {
+	book := &models.Book{
+		ID:             keyToID(doc.Key),
+		ProviderWorkID: openLibraryWorkID(doc.Key),
+		Title:          joinTitle(doc.Title, doc.Subtitle),
+		Authors:        strings.Join(doc.AuthorNames, ", "),
+		Link:           p.apiUrl + doc.Key,
+		ImageLink:      p.coverLink(doc.CoverID),
+		Pages:          doc.Pages,
+		Genres:         subjectsToGenres(doc.Subjects),
+	}
+	if len(doc.Languages) == 1 {
+		book.Language = models.NormalizeLanguage(doc.Languages[0])
+	}
+	book.SetISBN(firstISBN(doc.ISBN))
+	if doc.FirstPublishYear != 0 {
+		book.PublishedDate = strconv.Itoa(doc.FirstPublishYear)
+	}
+	return book
+}
+
+func (p *openLibraryProvider) convertWork(work OpenLibraryWork) *models.Book {
+	book := &models.Book{
+		ID:             keyToID(work.Key),
+		ProviderWorkID: openLibraryWorkID(work.Key),
+		Title:          joinTitle(work.Title, work.Subtitle),
+		Authors:        strings.Join(work.AuthorNames, ", "),
+		Description:    string(work.Description),
+		Link:           p.apiUrl + work.Key,
+		PublishedDate:  work.FirstPublishDate,
+		Genres:         subjectsToGenres(work.Subjects),
+	}
+	if len(work.Covers) > 0 {
+		book.ImageLink = p.coverLink(work.Covers[0])
+	}
+
+	if edition := work.Edition; edition != nil {
+		book.Pages = edition.Pages
+		book.SetISBN(firstISBN(edition.ISBN13, edition.ISBN10))
+		if len(edition.Languages) > 0 {
+			book.Language = models.NormalizeLanguage(edition.Languages[0].Key)
+		}
+		if book.ImageLink == "" && len(edition.Covers) > 0 {
+			book.ImageLink = p.coverLink(edition.Covers[0])
+		}
+		book.Series = editionSeries(*edition)
+	}
+
+	return book
+}
+
+func (p *openLibraryProvider) convertEdition(edition OpenLibraryEdition) *models.Book {
+	book := &models.Book{
+		ID:            keyToID(edition.Key),
+		Title:         joinTitle(edition.Title, edition.Subtitle),
+		Authors:       strings.Join(edition.AuthorNames, ", "),
+		Description:   string(edition.Description),
+		Link:          p.apiUrl + edition.Key,
+		PublishedDate: edition.PublishDate,
+		Pages:         edition.Pages,
+		Genres:        subjectsToGenres(edition.Subjects),
+	}
+	book.SetISBN(firstISBN(edition.ISBN13, edition.ISBN10))
+	if len(edition.Languages) > 0 {
+		book.Language = models.NormalizeLanguage(edition.Languages[0].Key)
+	}
+	if len(edition.Works) > 0 {
+		book.ProviderWorkID = openLibraryWorkID(edition.Works[0].Key)
+	}
+	if len(edition.Covers) > 0 {
+		book.ImageLink = p.coverLink(edition.Covers[0])
+	}
+	book.Series = editionSeries(edition)
+	return book
+}
+
+// editionSeries returns the first series of the edition that has a volume number
+func editionSeries(edition OpenLibraryEdition) *models.SeriesInfo {
+	for _, series := range edition.Series {
+		if info := models.ParseSeries(series); info != nil {
+			return info
+		}
+	}
+	return nil
+}
+
+func (p *openLibraryProvider) convertSubjectWork(work OpenLibrarySubjectWork) *models.Book {
+	authors := make([]string, len(work.Authors))
+	for i, author := range work.Authors {
+		authors[i] = author.Name
+	}
+
+	book := &models.Book{
+		ID:             keyToID(work.Key),
+		ProviderWorkID: openLibraryWorkID(work.Key),
+		Title:          work.Title,
+		Authors:        strings.Join(authors, ", "),
+		Link:           p.apiUrl + work.Key,
+		ImageLink:      p.coverLink(work.CoverID),
+		Genres:         subjectsToGenres(work.Subjects),
+	}
+	if work.FirstPublishYear != 0 {
+		book.PublishedDate = strconv.Itoa(work.FirstPublishYear)
+	}
+	return book
+}
+
+func (p *openLibraryProvider) coverLink(coverID int) string {
+	if coverID <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/b/id/%d-M.jpg", p.coversUrl, coverID)
+}
+
+// keyToID strips the "/works/" or "/books/" prefix from an Open Library key
+func keyToID(key string) string {
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
+func openLibraryWorkID(key string) string {
+	return "openlibrary:" + keyToID(key)
+}
+
+func joinTitle(title, subtitle string) string {
+	if subtitle == "" {
+		return title
+	}
+	return fmt.Sprintf("%s: %s", title, subtitle)
+}
+
+func subjectsToGenres(subjects []string) []models.Genre {
+	genres := []models.Genre{}
+	for _, subject := range subjects {
+		if len(genres) == openLibraryMaxGenres {
+			break
+		}
+		genres = append(genres, models.Genre{Name: subject})
+	}
+	return genres
+}
+
+// firstISBN returns the first valid ISBN, ISBN-13s are preferred over ISBN-10s
+func firstISBN(lists ...[]string) isbn.ISBN {
+	for _, length := range []int{13, 10} {
+		for _, list := range lists {
+			for _, value := range list {
+				if len(isbn.Clean(value)) != length {
+					continue
+				}
+				if parsed, err := isbn.Parse(value); err == nil {
+					return parsed
+				}
+			}
+		}
+	}
+	return ""
+}
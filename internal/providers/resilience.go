@@ -0,0 +1,335 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	DefaultRateLimit        = 5.0
+	DefaultRateBurst        = 10
+	DefaultMaxRetries       = 3
+	DefaultRetryBaseDelay   = 200 * time.Millisecond
+	DefaultRetryMaxDelay    = 5 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// NewResilientTransport wraps the transport of the provider http client. All requests share one rate limiter,
+// retryable responses are retried with exponential backoff and every host gets a circuit breaker that fails
+// fast for a cool-down period after repeated failures.
+func NewResilientTransport(base http.RoundTripper) *resilientTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &resilientTransport{
+		base:       base,
+		maxRetries: DefaultMaxRetries,
+		baseDelay:  DefaultRetryBaseDelay,
+		maxDelay:   DefaultRetryMaxDelay,
+		threshold:  DefaultBreakerThreshold,
+		cooldown:   DefaultBreakerCooldown,
+		now:        time.Now,
+		breakers:   make(map[string]*circuitBreaker),
+	}
+	return t.WithRateLimit(DefaultRateLimit, DefaultRateBurst)
+}
+
+type resilientTransport struct {
+	base       http.RoundTripper
+	limiter    *rateLimiter
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	threshold  int
+	cooldown   time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// WithRateLimit allows perSecond requests on average with bursts of up to burst requests
+func (t *resilientTransport) WithRateLimit(perSecond float64, burst int) *resilientTransport {
+	t.limiter = newRateLimiter(perSecond, burst, t.now)
+	return t
+}
+
+func (t *resilientTransport) WithRetries(maxRetries int, baseDelay, maxDelay time.Duration) *resilientTransport {
+	t.maxRetries = maxRetries
+	t.baseDelay = baseDelay
+	t.maxDelay = maxDelay
+	return t
+}
+
+func (t *resilientTransport) WithBreaker(threshold int, cooldown time.Duration) *resilientTransport {
+	t.threshold = threshold
+	t.cooldown = cooldown
+	return t
+}
+
+func (t *resilientTransport) WithClock(now func() time.Time) *resilientTransport {
+	t.now = now
+	t.limiter.now = now
+	return t
+}
+
+// BreakerState returns the state of the circuit breaker of the host
+func (t *resilientTransport) BreakerState(host string) BreakerState {
+	return t.breaker(host).State()
+}
+
+// Health reports the breaker of every host the transport has talked to
+func (t *resilientTransport) Health() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := map[string]string{
+		"status":     "up",
+		"rate_limit": fmt.Sprintf("%g/s", t.limiter.perSecond),
+	}
+	for host, breaker := range t.breakers {
+		state := breaker.State()
+		stats["breaker "+host] = string(state)
+		if state != BreakerClosed {
+			stats["status"] = "degraded"
+		}
+	}
+	return stats
+}
+
+func (t *resilientTransport) breaker(host string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	breaker, ok := t.breakers[host]
+	if !ok {
+		breaker = &circuitBreaker{threshold: t.threshold, cooldown: t.cooldown, now: t.now, state: BreakerClosed}
+		t.breakers[host] = breaker
+	}
+	return breaker
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	breaker := t.breaker(req.URL.Host)
+	if !breaker.allow() {
+		return nil, fmt.Errorf("%s: %w", req.URL.Host, models.ErrProviderUnavailable)
+	}
+
+	// only requests without a body can be sent again
+	canRetry := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			breaker.release()
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if ctx.Err() != nil {
+			// the caller went away, that says nothing about the provider
+			breaker.release()
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			breaker.record(resp.StatusCode < http.StatusInternalServerError)
+			return resp, nil
+		}
+		if !canRetry || attempt >= t.maxRetries {
+			breaker.record(false)
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.now()); ok {
+				// waiting longer than the limit would hold the user's request for too long
+				if retryAfter > t.maxDelay {
+					breaker.record(false)
+					return resp, nil
+				}
+				delay = retryAfter
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			breaker.release()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff doubles the delay with every attempt and picks a random point in its upper half
+func (t *resilientTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+	if delay > t.maxDelay || delay <= 0 {
+		delay = t.maxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads the header in both of its forms, seconds or an http date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// circuitBreaker opens after threshold consecutive failures. Once the cool-down passes a single request
+// is let through, its result closes the breaker again or reopens it for another cool-down.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release ends a request that neither succeeded nor failed, like one cancelled by the caller
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// rateLimiter is a token bucket, a token is added every 1/perSecond and at most burst tokens are kept
+type rateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      now(),
+		now:       now,
+	}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.perSecond <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := l.now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.perSecond * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
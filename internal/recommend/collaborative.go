@@ -0,0 +1,153 @@
+package recommend
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	// pairs shelved together by a single reader say more about that reader than about the books
+	MinSharedReaders = 2
+	// only the closest books are kept for every book so the table grows linearly with the catalogue
+	MaxSimilarBooks = 20
+)
+
+// Similarities computes the item to item cosine similarity of the books from the shelves of all users,
+// people who shelved X also shelved Y. A finished book weighs more than one only put on the shelf.
+func Similarities(entries []models.ShelfEntry) []models.BookSimilarity {
+	shelves := map[string]map[string]float64{}
+	norms := map[string]float64{}
+	for _, entry := range entries {
+		weight := shelvedWeight
+		if entry.Completed {
+			weight = completedWeight
+		}
+		shelf, ok := shelves[entry.UserGoogleId]
+		if !ok {
+			shelf = map[string]float64{}
+			shelves[entry.UserGoogleId] = shelf
+		}
+		// the same book can be shelved twice, the finished copy counts
+		if weight > shelf[entry.BookID] {
+			norms[entry.BookID] += weight*weight - shelf[entry.BookID]*shelf[entry.BookID]
+			shelf[entry.BookID] = weight
+		}
+	}
+
+	type pair struct{ a, b string }
+	dots := map[pair]float64{}
+	readers := map[pair]int{}
+	for _, shelf := range shelves {
+		books := make([]string, 0, len(shelf))
+		for book := range shelf {
+			books = append(books, book)
+		}
+		slices.Sort(books)
+		for i, a := range books {
+			for _, b := range books[i+1:] {
+				dots[pair{a, b}] += shelf[a] * shelf[b]
+				readers[pair{a, b}]++
+			}
+		}
+	}
+
+	similar := map[string][]models.BookSimilarity{}
+	for p, dot := range dots {
+		if readers[p] < MinSharedReaders {
+			continue
+		}
+		score := dot / math.Sqrt(norms[p.a]*norms[p.b])
+		similar[p.a] = append(similar[p.a], models.BookSimilarity{BookID: p.a, SimilarBookID: p.b, Score: score, Readers: readers[p]})
+		similar[p.b] = append(similar[p.b], models.BookSimilarity{BookID: p.b, SimilarBookID: p.a, Score: score, Readers: readers[p]})
+	}
+
+	books := make([]string, 0, len(similar))
+	for book := range similar {
+		books = append(books, book)
+	}
+	slices.Sort(books)
+
+	similarities := []models.BookSimilarity{}
+	for _, book := range books {
+		closest := similar[book]
+		slices.SortFunc(closest, func(a, b models.BookSimilarity) int {
+			return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.SimilarBookID, b.SimilarBookID))
+		})
+		similarities = append(similarities, closest[:min(len(closest), MaxSimilarBooks)]...)
+	}
+	return similarities
+}
+
+// Collaborative is the collaborative score of a book, Because is the book of the library
+// that contributed the most to it
+type Collaborative struct {
+	Score   float64
+	Because *models.Book
+}
+
+// CollaborativeScores sums the similarities of every book to the user's library, weighted like the
+// library in Rank, and scales them so the best book scores 1. Books the user owns are left out.
+func CollaborativeScores(library []*models.UserBook, similarities []models.BookSimilarity) map[string]Collaborative {
+	weights := map[string]float64{}
+	books := map[string]*models.Book{}
+	for _, userBook := range library {
+		weight := shelvedWeight
+		if userBook.ReadingProgress != nil && userBook.ReadingProgress.Completed {
+			weight = completedWeight
+		}
+		weights[userBook.BookID] = max(weights[userBook.BookID], weight)
+		books[userBook.BookID] = &userBook.Book
+	}
+
+	scores := map[string]Collaborative{}
+	strongest := map[string]float64{}
+	best := 0.0
+	for _, similarity := range similarities {
+		weight, ok := weights[similarity.BookID]
+		if !ok {
+			continue
+		}
+		if _, owned := weights[similarity.SimilarBookID]; owned {
+			continue
+		}
+		score := scores[similarity.SimilarBookID]
+		contribution := similarity.Score * weight
+		score.Score += contribution
+		if contribution > strongest[similarity.SimilarBookID] {
+			strongest[similarity.SimilarBookID] = contribution
+			score.Because = books[similarity.BookID]
+		}
+		scores[similarity.SimilarBookID] = score
+		best = max(best, score.Score)
+	}
+	for book, score := range scores {
+		score.Score /= best
+		scores[book] = score
+	}
+	return scores
+}
+
+// Blend mixes the collaborative scores into the ranked recommendations, weight is the share of the
+// collaborative score in the final score, and orders them again. The reason becomes the readers of
+// the library book when the collaborative part outweighs the part the reason came from.
+func Blend(recommendations []*models.Recommendation, collaborative map[string]Collaborative, weight float64) []*models.Recommendation {
+	weight = min(max(weight, 0), 1)
+	for _, recommendation := range recommendations {
+		score := collaborative[recommendation.Book.ID]
+		strongest := (1 - weight) * max(
+			GenreWeight*recommendation.GenreScore,
+			AuthorWeight*recommendation.AuthorScore,
+			ContentWeight*recommendation.ContentScore,
+		)
+		recommendation.CollaborativeScore = score.Score
+		recommendation.Score = (1-weight)*recommendation.Score + weight*score.Score
+		if score.Because != nil && weight*score.Score > strongest {
+			recommendation.Reason = models.RecommendationReason{Kind: models.ReasonReaders, Subject: score.Because.Title}
+		}
+	}
+	sortRecommendations(recommendations)
+	return recommendations
+}
@@ -0,0 +1,70 @@
+package recommend
+
+import (
+	"slices"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// a book the user is not interested in says a little about the other books of its authors
+const dismissedAuthorPenalty = 0.5
+
+// WithFeedback adds the books the user has already read to the library as finished books,
+// so they are not recommended again and shape the taste like the rest of the library
+func WithFeedback(library []*models.UserBook, feedback []*models.RecommendationFeedback) []*models.UserBook {
+	owned := map[string]bool{}
+	for _, userBook := range library {
+		owned[userBook.BookID] = true
+	}
+	for _, item := range feedback {
+		if item.Kind != models.FeedbackAlreadyRead || owned[item.BookID] {
+			continue
+		}
+		owned[item.BookID] = true
+		library = append(library, &models.UserBook{
+			UserGoogleId:    item.UserGoogleId,
+			BookID:          item.BookID,
+			Book:            item.Book,
+			ReadingProgress: &models.ReadingProgress{Completed: true},
+			Status:          models.UserBookStatusRead,
+		})
+	}
+	return library
+}
+
+// ApplyFeedback leaves out the books the user turned down and their other editions, books of
+// the authors of a book the user is not interested in lose part of their score
+func ApplyFeedback(recommendations []*models.Recommendation, feedback []*models.RecommendationFeedback) []*models.Recommendation {
+	if len(feedback) == 0 {
+		return recommendations
+	}
+	books := map[string]bool{}
+	works := map[uint]bool{}
+	authors := map[string]bool{}
+	for _, item := range feedback {
+		books[item.BookID] = true
+		if item.Book.WorkID != nil {
+			works[*item.Book.WorkID] = true
+		}
+		if item.Kind == models.FeedbackNotInterested {
+			for _, author := range splitAuthors(item.Book.Authors) {
+				authors[author] = true
+			}
+		}
+	}
+
+	recommendations = slices.DeleteFunc(recommendations, func(recommendation *models.Recommendation) bool {
+		book := recommendation.Book
+		return books[book.ID] || (book.WorkID != nil && works[*book.WorkID])
+	})
+	for _, recommendation := range recommendations {
+		for _, author := range splitAuthors(recommendation.Book.Authors) {
+			if authors[author] {
+				recommendation.Score *= dismissedAuthorPenalty
+				break
+			}
+		}
+	}
+	sortRecommendations(recommendations)
+	return recommendations
+}
@@ -0,0 +1,348 @@
+package recommend
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// the parts of the score, they add up to 1 so the score stays between 0 and 1
+const (
+	GenreWeight   = 0.4
+	AuthorWeight  = 0.3
+	ContentWeight = 0.3
+)
+
+const (
+	shelvedWeight = 1.0
+	// a finished book says more about the user's taste than one only put on the shelf
+	completedWeight = 2.0
+	// the genres picked on the profile count like a finished book
+	preferredGenreWeight = 2.0
+	// every level up the genre hierarchy counts half as much, liking Fiction says less about space operas
+	ancestorGenreWeight = 0.5
+	minTokenLength      = 3
+)
+
+// Rank scores the candidates against the library and returns them best first. Books the user
+// already has, or other editions of them, are left out. The order only depends on the input,
+// equal scores are ordered by title and id.
+func Rank(library []*models.UserBook, preferredGenres []models.Genre, candidates []*models.Book) []*models.Recommendation {
+	profile := newProfile(library, preferredGenres)
+
+	candidates = slices.DeleteFunc(slices.Clone(candidates), func(book *models.Book) bool {
+		return book == nil || profile.owns(book)
+	})
+
+	documents := make([][]string, 0, len(profile.books)+len(candidates))
+	for _, item := range profile.books {
+		documents = append(documents, tokenize(item.book))
+	}
+	for _, book := range candidates {
+		documents = append(documents, tokenize(book))
+	}
+	idf := inverseDocumentFrequency(documents)
+
+	taste := vector{}
+	libraryVectors := make([]vector, len(profile.books))
+	for i, item := range profile.books {
+		libraryVectors[i] = tfidf(documents[i], idf)
+		taste.add(libraryVectors[i], item.weight)
+	}
+
+	recommendations := make([]*models.Recommendation, 0, len(candidates))
+	for i, book := range candidates {
+		content := tfidf(documents[len(profile.books)+i], idf)
+		recommendation := &models.Recommendation{
+			Book:         book,
+			GenreScore:   profile.genreScore(book),
+			AuthorScore:  profile.authorScore(book),
+			ContentScore: taste.cosine(content),
+		}
+		recommendation.Score = GenreWeight*recommendation.GenreScore +
+			AuthorWeight*recommendation.AuthorScore +
+			ContentWeight*recommendation.ContentScore
+		recommendation.Reason = profile.reason(recommendation, libraryVectors, content)
+		recommendations = append(recommendations, recommendation)
+	}
+
+	sortRecommendations(recommendations)
+	return recommendations
+}
+
+func sortRecommendations(recommendations []*models.Recommendation) {
+	slices.SortStableFunc(recommendations, func(a, b *models.Recommendation) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(a.Book.Title, b.Book.Title),
+			cmp.Compare(a.Book.ID, b.Book.ID),
+		)
+	})
+}
+
+// Page returns the recommendations of the page, pages start at 1
+func Page(recommendations []*models.Recommendation, page, size int) []*models.Recommendation {
+	start := (page - 1) * size
+	if page < 1 || start >= len(recommendations) {
+		return []*models.Recommendation{}
+	}
+	return recommendations[start:min(start+size, len(recommendations))]
+}
+
+type weightedBook struct {
+	book   *models.Book
+	weight float64
+}
+
+type profile struct {
+	books      []weightedBook
+	genres     vector
+	authors    map[string]float64
+	maxAuthor  float64
+	ownedBooks map[string]bool
+	ownedWorks map[uint]bool
+	// names keeps how genres and authors were first written, for the reasons
+	names map[string]string
+}
+
+func newProfile(library []*models.UserBook, preferredGenres []models.Genre) *profile {
+	p := &profile{
+		genres:     vector{},
+		authors:    map[string]float64{},
+		ownedBooks: map[string]bool{},
+		ownedWorks: map[uint]bool{},
+		names:      map[string]string{},
+	}
+	for _, genre := range preferredGenres {
+		p.addGenre(genre, preferredGenreWeight)
+	}
+	for _, userBook := range library {
+		book := &userBook.Book
+		p.ownedBooks[userBook.BookID] = true
+		p.ownedBooks[book.ID] = true
+		if book.WorkID != nil {
+			p.ownedWorks[*book.WorkID] = true
+		}
+
+		weight := shelvedWeight
+		if userBook.ReadingProgress != nil && userBook.ReadingProgress.Completed {
+			weight = completedWeight
+		}
+		p.books = append(p.books, weightedBook{book: book, weight: weight})
+		for _, genre := range book.Genres {
+			p.addGenre(genre, weight)
+		}
+		for _, author := range splitAuthors(book.Authors) {
+			p.authors[author] += weight
+			p.maxAuthor = max(p.maxAuthor, p.authors[author])
+		}
+		for _, name := range models.SplitAuthors(book.Authors) {
+			p.name("author:"+models.AuthorKey(name), name)
+		}
+	}
+	delete(p.genres, "")
+	return p
+}
+
+func (p *profile) addGenre(genre models.Genre, weight float64) {
+	p.genres.add(genreTerms(genre), weight)
+	for _, name := range genre.Ancestors() {
+		p.name("genre:"+genreKey(name), name)
+	}
+}
+
+func (p *profile) name(key, name string) {
+	if _, ok := p.names[key]; !ok {
+		p.names[key] = strings.TrimSpace(name)
+	}
+}
+
+// reason picks the part with the largest share of the score, ties go to genre, then author, then content
+func (p *profile) reason(recommendation *models.Recommendation, libraryVectors []vector, content vector) models.RecommendationReason {
+	genre := GenreWeight * recommendation.GenreScore
+	author := AuthorWeight * recommendation.AuthorScore
+	similar := ContentWeight * recommendation.ContentScore
+	switch {
+	case genre > 0 && genre >= author && genre >= similar:
+		return models.RecommendationReason{Kind: models.ReasonGenre, Subject: p.favourite("genre:", p.genres, genreKeys(recommendation.Book))}
+	case author > 0 && author >= similar:
+		return models.RecommendationReason{Kind: models.ReasonAuthor, Subject: p.favourite("author:", p.authors, splitAuthors(recommendation.Book.Authors))}
+	case similar > 0:
+		best, bestScore := 0, 0.0
+		for i, library := range libraryVectors {
+			if score := library.cosine(content); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		return models.RecommendationReason{Kind: models.ReasonSimilarBook, Subject: p.books[best].book.Title}
+	default:
+		return models.RecommendationReason{}
+	}
+}
+
+// favourite returns the name of the key the user weighs the most, the first one wins a tie
+func (p *profile) favourite(prefix string, weights map[string]float64, keys []string) string {
+	best, bestWeight := "", 0.0
+	for _, key := range keys {
+		if weights[key] > bestWeight {
+			best, bestWeight = key, weights[key]
+		}
+	}
+	return p.names[prefix+best]
+}
+
+// genreKeys lists the genres of the book, each followed by its ancestors
+func genreKeys(book *models.Book) []string {
+	var keys []string
+	for _, genre := range book.Genres {
+		ancestors := genre.Ancestors()
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			keys = append(keys, genreKey(ancestors[i]))
+		}
+	}
+	return keys
+}
+
+func (p *profile) owns(book *models.Book) bool {
+	return p.ownedBooks[book.ID] || (book.WorkID != nil && p.ownedWorks[*book.WorkID])
+}
+
+// genreScore is the cosine similarity of the book's genres and the genres of the library
+func (p *profile) genreScore(book *models.Book) float64 {
+	genres := vector{}
+	for _, genre := range book.Genres {
+		for key, weight := range genreTerms(genre) {
+			genres[key] = max(genres[key], weight)
+		}
+	}
+	delete(genres, "")
+	return p.genres.cosine(genres)
+}
+
+// genreTerms weighs the genre and its ancestors, see ancestorGenreWeight
+func genreTerms(genre models.Genre) vector {
+	terms := vector{}
+	ancestors := genre.Ancestors()
+	weight := 1.0
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		key := genreKey(ancestors[i])
+		terms[key] = max(terms[key], weight)
+		weight *= ancestorGenreWeight
+	}
+	return terms
+}
+
+// authorScore compares the best known author of the book with the user's favourite author
+func (p *profile) authorScore(book *models.Book) float64 {
+	if p.maxAuthor == 0 {
+		return 0
+	}
+	best := 0.0
+	for _, author := range splitAuthors(book.Authors) {
+		best = max(best, p.authors[author])
+	}
+	return best / p.maxAuthor
+}
+
+// vector is a sparse vector of term weights
+type vector map[string]float64
+
+func (v vector) add(other vector, weight float64) {
+	for term, value := range other {
+		v[term] += value * weight
+	}
+}
+
+func (v vector) norm() float64 {
+	sum := 0.0
+	for _, value := range v {
+		sum += value * value
+	}
+	return math.Sqrt(sum)
+}
+
+func (v vector) cosine(other vector) float64 {
+	norms := v.norm() * other.norm()
+	if norms == 0 {
+		return 0
+	}
+	dot := 0.0
+	for term, value := range other {
+		dot += v[term] * value
+	}
+	return min(1, dot/norms)
+}
+
+// inverseDocumentFrequency weights the terms by how rare they are among the documents,
+// words every description uses say nothing about a book
+func inverseDocumentFrequency(documents [][]string) map[string]float64 {
+	frequency := map[string]int{}
+	for _, document := range documents {
+		seen := map[string]bool{}
+		for _, term := range document {
+			if !seen[term] {
+				seen[term] = true
+				frequency[term]++
+			}
+		}
+	}
+	idf := make(map[string]float64, len(frequency))
+	for term, count := range frequency {
+		idf[term] = math.Log(float64(len(documents)+1)/float64(count+1)) + 1
+	}
+	return idf
+}
+
+func tfidf(document []string, idf map[string]float64) vector {
+	v := vector{}
+	if len(document) == 0 {
+		return v
+	}
+	for _, term := range document {
+		v[term]++
+	}
+	for term, count := range v {
+		v[term] = count / float64(len(document)) * idf[term]
+	}
+	return v
+}
+
+// tokenize splits the title and description into lowercase words, leaving out short and common words
+func tokenize(book *models.Book) []string {
+	words := strings.FieldsFunc(strings.ToLower(book.Title+" "+book.Description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return slices.DeleteFunc(words, func(word string) bool {
+		return len([]rune(word)) < minTokenLength || stopWords[word]
+	})
+}
+
+func genreKey(name string) string {
+	return models.GenreKey(name)
+}
+
+// splitAuthors returns the normalized names of the authors, see models.AuthorKey
+func splitAuthors(authors string) []string {
+	var keys []string
+	for _, name := range models.SplitAuthors(authors) {
+		if key := models.AuthorKey(name); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true,
+	"all": true, "any": true, "can": true, "her": true, "his": true, "was": true, "one": true,
+	"our": true, "out": true, "who": true, "has": true, "have": true, "had": true, "its": true,
+	"this": true, "that": true, "with": true, "from": true, "they": true, "will": true, "into": true,
+	"their": true, "them": true, "then": true, "than": true, "there": true, "what": true, "when": true,
+	"which": true, "while": true, "about": true, "after": true, "book": true, "novel": true, "story": true,
+	"new": true, "more": true, "most": true, "only": true, "over": true, "also": true, "been": true,
+	"she": true, "him": true, "how": true, "where": true, "why": true, "your": true, "were": true,
+}
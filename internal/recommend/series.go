@@ -0,0 +1,42 @@
+package recommend
+
+import (
+	"slices"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// ContinueSeries puts the next volumes of the series the user has started at the top of the
+// recommendations. Volumes already in the library are left out, the user has them on the library page.
+func ContinueSeries(recommendations []*models.Recommendation, library []*models.UserBook, next []*models.NextVolume) []*models.Recommendation {
+	volumes := []*models.Recommendation{}
+	books := map[string]bool{}
+	works := map[uint]bool{}
+	for _, volume := range next {
+		book := volume.Book
+		if models.UserBookFor(book, library) != nil || books[book.ID] || (book.WorkID != nil && works[*book.WorkID]) {
+			continue
+		}
+		books[book.ID] = true
+		if book.WorkID != nil {
+			works[*book.WorkID] = true
+		}
+		volumes = append(volumes, &models.Recommendation{
+			Book:  book,
+			Score: 1,
+			Reason: models.RecommendationReason{
+				Kind:    models.ReasonSeries,
+				Subject: volume.Series.Name + " (#" + models.FormatPosition(volume.Position) + ")",
+			},
+		})
+	}
+	if len(volumes) == 0 {
+		return recommendations
+	}
+
+	recommendations = slices.DeleteFunc(recommendations, func(recommendation *models.Recommendation) bool {
+		book := recommendation.Book
+		return books[book.ID] || (book.WorkID != nil && works[*book.WorkID])
+	})
+	return append(volumes, recommendations...)
+}
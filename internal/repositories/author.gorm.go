@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+type authorRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthorRepository(db *gorm.DB) *authorRepository {
+	return &authorRepository{
+		db: db,
+	}
+}
+
+// Get returns the author with their books, the editions of a work are next to each other
+func (r *authorRepository) Get(id string) (*models.Author, error) {
+	author := &models.Author{}
+	err := r.db.Preload("Books", func(db *gorm.DB) *gorm.DB {
+		return db.Where("linked_book_id = '' OR linked_book_id IS NULL").Order("work_id").Order("title")
+	}).First(author, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// GetByName finds the author by any spelling of their name, see models.AuthorKey
+func (r *authorRepository) GetByName(name string) (*models.Author, error) {
+	author := &models.Author{}
+	if err := r.db.First(author, "key = ?", models.AuthorKey(name)).Error; err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// resolveAuthors finds or creates the authors of the book's names, spellings of the same name
+// share one author and the first spelling saved becomes its name
+func resolveAuthors(tx *gorm.DB, book *models.Book) ([]models.Author, error) {
+	authors := []models.Author{}
+	seen := map[string]bool{}
+	for _, name := range models.SplitAuthors(book.Authors) {
+		key := models.AuthorKey(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		author := models.Author{}
+		if err := tx.Where(models.Author{Key: key}).Attrs(models.Author{Name: name}).FirstOrCreate(&author).Error; err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+	return authors, nil
+}
+
+// assignMissingAuthors links the books saved before authors existed to their authors
+func (r *bookRepository) assignMissingAuthors() error {
+	var books []*models.Book
+	err := r.db.Where("authors <> ''").
+		Where("NOT EXISTS (SELECT 1 FROM book_authors WHERE book_authors.book_id = books.id)").
+		Find(&books).Error
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, book := range books {
+			authors, err := resolveAuthors(tx, book)
+			if err != nil {
+				return err
+			}
+			if len(authors) == 0 {
+				continue
+			}
+			if err := tx.Model(book).Omit("AuthorList.*").Association("AuthorList").Append(authors); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,64 +1,394 @@
 package repositories
 
 import (
+	"errors"
+	"log"
+	"strings"
+	"unicode"
+
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"gorm.io/gorm"
 )
 
 type bookRepository struct {
 	db *gorm.DB
+	// fullTextSearch is false when sqlite was built without FTS5 (the sqlite_fts5 build tag),
+	// searches then fall back to LIKE queries
+	fullTextSearch bool
 }
 
 func NewBookRepository(db *gorm.DB) *bookRepository {
-	return &bookRepository{
-		db: db,
+	r := &bookRepository{
+		db:             db,
+		fullTextSearch: hasSearchIndex(db, "books_fts"),
+	}
+	if !r.fullTextSearch {
+		log.Printf("full-text search unavailable, falling back to LIKE queries")
+	}
+	return r
+}
+
+// createSearchIndex creates the books_fts virtual table and indexes the books saved before it existed
+func (r *bookRepository) createSearchIndex() error {
+	var exists int64
+	if err := r.db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'books_fts'").Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
 	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE VIRTUAL TABLE books_fts USING fts5(
+			book_id UNINDEXED,
+			title,
+			authors,
+			description,
+			tokenize = 'unicode61 remove_diacritics 2'
+		)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO books_fts (book_id, title, authors, description)
+			SELECT id, title, authors, description FROM books WHERE deleted_at IS NULL`).Error
+	})
 }
 
 func (r *bookRepository) Create(book *models.Book) error {
-	var genres []models.Genre
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var genres []models.Genre
+		seen := map[uint]bool{}
 
-	for _, genreName := range book.Genres {
-		var genre models.Genre
-		err := r.db.Where("name = ?", genreName.Name).First(&genre).Error
+		for _, category := range book.Genres {
+			genre, err := resolveGenre(tx, category.Name)
+			if errors.Is(err, models.ErrGenreEmpty) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			// several categories can map onto the same genre
+			if !seen[genre.ID] {
+				seen[genre.ID] = true
+				genres = append(genres, *genre)
+			}
+		}
+
+		book.Genres = genres
+		authors, err := resolveAuthors(tx, book)
 		if err != nil {
-			if err.Error() == "record not found" {
-				genre = models.Genre{Name: genreName.Name}
-				if err := r.db.Create(&genre).Error; err != nil {
-					return err
-				}
-			} else {
+			return err
+		}
+		book.AuthorList = authors
+		book.MatchKey = models.WorkMatchKey(book.Title, book.Authors)
+		if err := r.resolveWork(tx, book); err != nil {
+			return err
+		}
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		if err := saveSeriesEntry(tx, book); err != nil {
+			return err
+		}
+		return r.index(tx, book)
+	})
+}
+
+// resolveWork links the book to the work of its other editions, matched by provider work id,
+// then by isbn and finally by normalized title and author. A new work is created when nothing matches.
+func (r *bookRepository) resolveWork(tx *gorm.DB, book *models.Book) error {
+	if book.WorkID != nil {
+		return nil
+	}
+
+	work, err := r.findWork(tx, book)
+	if err != nil {
+		return err
+	}
+
+	if work == nil {
+		work = &models.Work{
+			Title:          book.Title,
+			Authors:        book.Authors,
+			MatchKey:       models.WorkMatchKey(book.Title, book.Authors),
+			ProviderWorkID: book.ProviderWorkID,
+		}
+		if err := tx.Create(work).Error; err != nil {
+			return err
+		}
+	} else if work.ProviderWorkID == "" && book.ProviderWorkID != "" {
+		if err := tx.Model(work).Update("provider_work_id", book.ProviderWorkID).Error; err != nil {
+			return err
+		}
+	}
+
+	book.WorkID = &work.ID
+	return nil
+}
+
+func (r *bookRepository) findWork(tx *gorm.DB, book *models.Book) (*models.Work, error) {
+	var works []*models.Work
+
+	if book.ProviderWorkID != "" {
+		if err := tx.Where("provider_work_id = ?", book.ProviderWorkID).Limit(1).Find(&works).Error; err != nil {
+			return nil, err
+		}
+		if len(works) > 0 {
+			return works[0], nil
+		}
+	}
+
+	if book.ISBN13 != "" {
+		var editions []*models.Book
+		if err := tx.Where("isbn13 = ? AND work_id IS NOT NULL", book.ISBN13).Limit(1).Find(&editions).Error; err != nil {
+			return nil, err
+		}
+		if len(editions) > 0 {
+			if err := tx.Where("id = ?", *editions[0].WorkID).Limit(1).Find(&works).Error; err != nil {
+				return nil, err
+			}
+			if len(works) > 0 {
+				return works[0], nil
+			}
+		}
+	}
+
+	key := models.WorkMatchKey(book.Title, book.Authors)
+	if key == "" {
+		return nil, nil
+	}
+	if err := tx.Where("match_key = ?", key).Limit(1).Find(&works).Error; err != nil {
+		return nil, err
+	}
+	if len(works) > 0 {
+		return works[0], nil
+	}
+	return nil, nil
+}
+
+// assignMissingWorks groups the books saved before works existed
+func (r *bookRepository) assignMissingWorks() error {
+	var books []*models.Book
+	if err := r.db.Where("work_id IS NULL").Find(&books).Error; err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, book := range books {
+			if err := r.resolveWork(tx, book); err != nil {
+				return err
+			}
+			if err := tx.Model(book).Update("work_id", book.WorkID).Error; err != nil {
 				return err
 			}
 		}
+		return nil
+	})
+}
 
-		genres = append(genres, genre)
+// assignMissingMatchKeys stores the match key of the books saved before it was kept on the book
+func (r *bookRepository) assignMissingMatchKeys() error {
+	var books []*models.Book
+	if err := r.db.Where("match_key IS NULL OR match_key = ''").Find(&books).Error; err != nil {
+		return err
 	}
 
-	book.Genres = genres
-	return r.db.Debug().Create(book).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, book := range books {
+			key := models.WorkMatchKey(book.Title, book.Authors)
+			if err := tx.Model(book).Update("match_key", key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// index keeps the full-text index in sync with the books table
+func (r *bookRepository) index(tx *gorm.DB, book *models.Book) error {
+	if !r.fullTextSearch {
+		return nil
+	}
+	if err := tx.Exec("DELETE FROM books_fts WHERE book_id = ?", book.ID).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO books_fts (book_id, title, authors, description) VALUES (?, ?, ?, ?)",
+		book.ID, book.Title, book.Authors, book.Description).Error
 }
 
 func (r *bookRepository) Get(id string) (*models.Book, error) {
 	book := &models.Book{}
-	if err := r.db.First(&book, "id = ?", id).Error; err != nil {
+	if err := r.db.Preload("Genres").First(&book, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return book, nil
 }
 
+func (r *bookRepository) GetByISBN(isbn13 string) (*models.Book, error) {
+	book := &models.Book{}
+	if err := r.db.First(book, "isbn13 = ?", isbn13).Error; err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// GetByWork returns every saved edition of the work
+func (r *bookRepository) GetByWork(workID uint) ([]*models.Book, error) {
+	var books []*models.Book
+	if err := r.db.Where("work_id = ?", workID).Order("published_date").Find(&books).Error; err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
 func (r *bookRepository) Delete(bookID string) error {
-	return r.db.Delete(&models.Book{}, bookID).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", bookID).Delete(&models.Book{}).Error; err != nil {
+			return err
+		}
+		if !r.fullTextSearch {
+			return nil
+		}
+		return tx.Exec("DELETE FROM books_fts WHERE book_id = ?", bookID).Error
+	})
+}
+
+// ReplaceBookReferences moves the shelves and exchange requests from one book to another
+// and marks the old book as linked to the new one. Users who already shelved the new book keep both entries.
+func (r *bookRepository) ReplaceBookReferences(oldID, newID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := replaceReferences(tx, oldID, newID, ""); err != nil {
+			return err
+		}
+		return tx.Model(&models.Book{}).Where("id = ?", oldID).Update("linked_book_id", newID).Error
+	})
+}
+
+// ReplaceUserBookReferences moves only the user's shelves and exchange requests, the old book stays unlinked for the others
+func (r *bookRepository) ReplaceUserBookReferences(oldID, newID, userID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceReferences(tx, oldID, newID, userID)
+	})
+}
+
+// replaceReferences moves the references of the user, or of every user when userID is empty
+func replaceReferences(tx *gorm.DB, oldID, newID, userID string) error {
+	owned := func(db *gorm.DB, column string) *gorm.DB {
+		if userID == "" {
+			return db
+		}
+		return db.Where(column+" = ?", userID)
+	}
+
+	err := owned(tx.Model(&models.UserBook{}), "user_google_id").
+		Where("book_id = ?", oldID).
+		Where("user_google_id NOT IN (?)", tx.Model(&models.UserBook{}).Select("user_google_id").Where("book_id = ?", newID)).
+		Update("book_id", newID).Error
+	if err != nil {
+		return err
+	}
+	err = owned(tx.Model(&models.Review{}), "user_google_id").
+		Where("book_id = ?", oldID).
+		Where("user_google_id NOT IN (?)", tx.Model(&models.Review{}).Select("user_google_id").Where("book_id = ?", newID)).
+		Update("book_id", newID).Error
+	if err != nil {
+		return err
+	}
+	err = owned(tx.Model(&models.ExchangeRequest{}), "user_google_id").
+		Where("desired_book_id = ?", oldID).
+		Update("desired_book_id", newID).Error
+	if err != nil {
+		return err
+	}
+	offered := tx.Model(&models.OfferedBook{}).Where("book_id = ?", oldID)
+	if userID != "" {
+		offered = offered.Where("exchange_request_id IN (?)",
+			tx.Model(&models.ExchangeRequest{}).Select("id").Where("user_google_id = ?", userID))
+	}
+	return offered.Update("book_id", newID).Error
 }
 
+// GetByGenre returns the books of the genre and of its descendants, "Fiction" also finds space operas
 func (r *bookRepository) GetByGenre(genre string) ([]*models.Book, error) {
-	var books []*models.Book
-	err := r.db.Debug().
-		Joins("JOIN book_genres ON book_genres.book_id = books.id").
-		Joins("JOIN genres ON genres.id = book_genres.genre_id").
-		Where("genres.name = ?", genre).Find(&books).Error
+	books := []*models.Book{}
+	canonical, err := findGenreByAlias(r.db, genre)
+	if err != nil || canonical == nil {
+		return books, err
+	}
+
+	genres := r.db.Model(&models.Genre{}).Select("id").
+		Where("path = ? OR path LIKE ?", canonical.Path, canonical.Path+models.GenrePathSeparator+"%")
+	bookIDs := r.db.Table("book_genres").Select("book_id").Where("genre_id IN (?)", genres)
+	err = r.db.Where("id IN (?)", bookIDs).Find(&books).Error
 	if err != nil {
 		return nil, err
 	}
 	return books, nil
 }
+
+// Search looks up saved books by title, authors and description.
+// Every term is matched as a prefix and results are ranked with bm25, title matches weigh the most.
+// The filters are applied in the query, so every page but the last one is full.
+func (r *bookRepository) Search(query string, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*models.Book{}, nil
+	}
+
+	var search *gorm.DB
+	if r.fullTextSearch {
+		match := make([]string, len(terms))
+		for i, term := range terms {
+			match[i] = `"` + term + `"*`
+		}
+		search = r.db.Table("books_fts").
+			Select("books.*").
+			Joins("JOIN books ON books.id = books_fts.book_id").
+			Where("books_fts MATCH ? AND books.deleted_at IS NULL", strings.Join(match, " "))
+	} else {
+		search = r.db.Model(&models.Book{})
+		for _, term := range terms {
+			like := "%" + term + "%"
+			search = search.Where("books.title LIKE ? OR books.authors LIKE ? OR books.description LIKE ?", like, like, like)
+		}
+	}
+	search = filterBooks(search.Where("books.linked_book_id IS NULL OR books.linked_book_id = ''"), filters)
+
+	if filters.OrderBy == models.SearchOrderNewest {
+		search = search.Order("books.published_date DESC")
+	}
+	if r.fullTextSearch {
+		search = search.Order("bm25(books_fts, 0.0, 10.0, 5.0, 1.0)")
+	} else {
+		search = search.
+			Order(gorm.Expr("CASE WHEN books.title LIKE ? THEN 0 ELSE 1 END", "%"+terms[0]+"%")).
+			Order("books.title")
+	}
+
+	books := []*models.Book{}
+	return books, search.Limit(limit).Offset((page - 1) * limit).Scan(&books).Error
+}
+
+// filterBooks narrows the query down the same way SearchFilters.Matches does, the print type is not saved
+func filterBooks(db *gorm.DB, filters models.SearchFilters) *gorm.DB {
+	if filters.Language != "" {
+		db = db.Where("books.language = ?", filters.Language)
+	}
+	if filters.MinPages > 0 {
+		db = db.Where("books.pages >= ?", filters.MinPages)
+	}
+	year := "CAST(substr(books.published_date, 1, 4) AS INTEGER)"
+	if filters.PublishedFrom > 0 {
+		db = db.Where(year+" >= ?", filters.PublishedFrom)
+	}
+	if filters.PublishedTo > 0 {
+		db = db.Where(year+" BETWEEN 1 AND ?", filters.PublishedTo)
+	}
+	return db
+}
+
+// searchTerms splits the query into words, dropping the characters that have a meaning in the FTS5 syntax
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
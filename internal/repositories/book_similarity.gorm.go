@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+const similarityBatchSize = 500
+
+type bookSimilarityRepository struct {
+	db *gorm.DB
+}
+
+func NewBookSimilarityRepository(db *gorm.DB) *bookSimilarityRepository {
+	return &bookSimilarityRepository{
+		db: db,
+	}
+}
+
+// ShelfEntries returns the book of every user book with whether its reading was finished
+func (r *bookSimilarityRepository) ShelfEntries() ([]models.ShelfEntry, error) {
+	entries := []models.ShelfEntry{}
+	err := r.db.Model(&models.UserBook{}).
+		Select("user_books.user_google_id, user_books.book_id, COALESCE(reading_progresses.completed, false) AS completed").
+		Joins("LEFT JOIN reading_progresses ON reading_progresses.user_book_id = user_books.id AND reading_progresses.deleted_at IS NULL").
+		Scan(&entries).Error
+	return entries, err
+}
+
+// Replace swaps the whole table in one transaction, readers never see a half written table
+func (r *bookSimilarityRepository) Replace(similarities []models.BookSimilarity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.BookSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(similarities) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(similarities, similarityBatchSize).Error
+	})
+}
+
+func (r *bookSimilarityRepository) GetForBooks(bookIDs []string) ([]models.BookSimilarity, error) {
+	similarities := []models.BookSimilarity{}
+	if len(bookIDs) == 0 {
+		return similarities, nil
+	}
+	err := r.db.Where("book_id IN ?", bookIDs).
+		Order("book_id, score DESC, similar_book_id").
+		Find(&similarities).Error
+	return similarities, err
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+type ebookFileRepository struct {
+	db *gorm.DB
+}
+
+func NewEbookFileRepository(db *gorm.DB) *ebookFileRepository {
+	return &ebookFileRepository{
+		db: db,
+	}
+}
+
+func (r *ebookFileRepository) Save(file *models.EbookFile) error {
+	return r.db.Save(file).Error
+}
+
+func (r *ebookFileRepository) GetByUserAndBook(userId, bookId string) (*models.EbookFile, error) {
+	file := &models.EbookFile{}
+	return file, r.db.Where("user_google_id = ? AND book_id = ?", userId, bookId).First(file).Error
+}
@@ -94,21 +94,53 @@ func (r *ExchangeRequestRepository) Update(exchange *models.ExchangeRequest) err
 	return r.db.Session(&gorm.Session{FullSaveAssociations: true}).Updates(exchange).Error
 }
 
-func (r *ExchangeRequestRepository) FindMatchingRequests(requestId, userId, desiredBookId string, offeredBooks []string) ([]*models.ExchangeRequest, error) {
-	matches := []*models.ExchangeRequest{}
+// FindMatchCandidates returns the active requests of other users that want one of the request's offered books
+// and offer its desired book, counting the editions models.EditionEquivalence accepts. Whether the editions
+// are accepted is decided by the service.
+func (r *ExchangeRequestRepository) FindMatchCandidates(request *models.ExchangeRequest) ([]*models.ExchangeRequest, error) {
+	offered := make([]*models.Book, len(request.OfferedBooks))
+	for i := range request.OfferedBooks {
+		offered[i] = &request.OfferedBooks[i].Book
+	}
+	offeringDesired := r.db.Model(&models.OfferedBook{}).
+		Select("exchange_request_id").
+		Where("book_id IN (?)", r.editionsOf([]*models.Book{&request.DesiredBook}))
+
+	candidates := []*models.ExchangeRequest{}
 	err := r.db.Preload("DesiredBook").
 		Preload("OfferedBooks.Book").
-		Not("id = ?", requestId).                                                                        // Exclude user's own request
-		Not("user_google_id = ?", userId).                                                               // Exclude user's own requests
-		Where("desired_book_id IN ?", offeredBooks).                                                     // Their desired book is in your offered books
-		Where("id IN (SELECT exchange_request_id FROM offered_books WHERE book_id = ?)", desiredBookId). // Your desired book is in their offered books
+		Not("id = ?", request.ID).                       // Exclude user's own request
+		Not("user_google_id = ?", request.UserGoogleId). // Exclude user's own requests
 		Not("status = ?", models.ExchangeRequestStatusCompleted).
-		Find(&matches).Error
+		Where("desired_book_id IN (?)", r.editionsOf(offered)).
+		Where("id IN (?)", offeringDesired).
+		Find(&candidates).Error
 	if err != nil {
 		return nil, err
 	}
 
-	return matches, nil
+	return candidates, nil
+}
+
+// editionsOf selects the ids of the books and of the books sharing their isbn or their normalized title and author,
+// the same rules as models.EditionEquivalence
+func (r *ExchangeRequestRepository) editionsOf(books []*models.Book) *gorm.DB {
+	ids, isbns13, isbns10, keys := []string{}, []string{}, []string{}, []string{}
+	for _, book := range books {
+		ids = append(ids, book.ID)
+		if book.ISBN13 != "" {
+			isbns13 = append(isbns13, book.ISBN13)
+		}
+		if book.ISBN10 != "" {
+			isbns10 = append(isbns10, book.ISBN10)
+		}
+		if key := models.WorkMatchKey(book.Title, book.Authors); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return r.db.Model(&models.Book{}).
+		Select("id").
+		Where("id IN ? OR isbn13 IN ? OR isbn10 IN ? OR match_key IN ?", ids, isbns13, isbns10, keys)
 }
 
 func (r *ExchangeRequestRepository) CreateMatch(match *models.ExchangeMatch) error {
@@ -165,3 +197,20 @@ func (r *ExchangeRequestRepository) GetActiveExchangeRequestsByBookID(id string,
 
 	return exchanges, err
 }
+
+// GetActiveOffering returns the active requests of other users offering any of the books
+func (r *ExchangeRequestRepository) GetActiveOffering(bookIDs []string, excludeUserID string) ([]*models.ExchangeRequest, error) {
+	exchanges := []*models.ExchangeRequest{}
+	if len(bookIDs) == 0 {
+		return exchanges, nil
+	}
+
+	err := r.db.Preload("User").
+		Preload("OfferedBooks.Book").
+		Not("user_google_id = ?", excludeUserID).
+		Where("status = ?", models.ExchangeRequestStatusActive).
+		Where("EXISTS (SELECT 1 FROM offered_books ob WHERE ob.exchange_request_id = exchange_requests.id AND ob.book_id IN ? AND ob.deleted_at IS NULL)", bookIDs).
+		Find(&exchanges).Error
+
+	return exchanges, err
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// resolveGenre maps a provider category onto its canonical genre. The first level of the category is looked up
+// by its aliases, so a level already known elsewhere in the hierarchy keeps its place, the next levels are
+// looked up among the children of the level above them and created there when missing.
+// The category itself becomes an alias of the genre.
+func resolveGenre(tx *gorm.DB, category string) (*models.Genre, error) {
+	path := models.GenrePath(category)
+	if len(path) == 0 {
+		return nil, models.ErrGenreEmpty
+	}
+	if genre, err := findGenreByAlias(tx, category); err != nil || genre != nil {
+		return genre, err
+	}
+
+	var parent *models.Genre
+	for _, name := range path {
+		var genre *models.Genre
+		var err error
+		if parent == nil {
+			genre, err = findGenreByAlias(tx, name)
+		} else {
+			genre, err = findChildGenre(tx, parent, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if genre == nil {
+			if genre, err = createGenre(tx, name, parent); err != nil {
+				return nil, err
+			}
+		}
+		parent = genre
+	}
+	return parent, saveGenreAlias(tx, category, parent.ID)
+}
+
+func findGenreByAlias(tx *gorm.DB, name string) (*models.Genre, error) {
+	aliases := []models.GenreAlias{}
+	err := tx.Preload("Genre").Where("alias = ?", models.GenreKey(name)).Limit(1).Find(&aliases).Error
+	if err != nil || len(aliases) == 0 {
+		return nil, err
+	}
+	return &aliases[0].Genre, nil
+}
+
+// findChildGenre looks up the level under the parent, by an alias pointing to one of its children
+// or by the name of a child
+func findChildGenre(tx *gorm.DB, parent *models.Genre, name string) (*models.Genre, error) {
+	genre, err := findGenreByAlias(tx, name)
+	if err != nil {
+		return nil, err
+	}
+	if genre != nil && genre.ParentID != nil && *genre.ParentID == parent.ID {
+		return genre, nil
+	}
+
+	children := []*models.Genre{}
+	if err := tx.Where("parent_id = ?", parent.ID).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if models.GenreKey(child.Name) == models.GenreKey(name) {
+			return child, nil
+		}
+	}
+	return nil, nil
+}
+
+// createGenre adds the genre under the parent. A genre saved under the same name before the hierarchy
+// existed is moved under the parent instead.
+func createGenre(tx *gorm.DB, name string, parent *models.Genre) (*models.Genre, error) {
+	genre := &models.Genre{Name: name, Path: name}
+	if parent != nil {
+		genre.ParentID = &parent.ID
+		genre.Path = parent.Path + models.GenrePathSeparator + name
+	}
+
+	existing := []*models.Genre{}
+	if err := tx.Where("name = ? AND (path = '' OR path IS NULL)", name).Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	var err error
+	if len(existing) > 0 {
+		existing[0].ParentID, existing[0].Path = genre.ParentID, genre.Path
+		err = tx.Model(existing[0]).Select("parent_id", "path").Updates(existing[0]).Error
+		genre = existing[0]
+	} else {
+		err = tx.Create(genre).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return genre, saveGenreAlias(tx, name, genre.ID)
+}
+
+func saveGenreAlias(tx *gorm.DB, name string, genreID uint) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.GenreAlias{Alias: models.GenreKey(name), GenreID: genreID}).Error
+}
+
+// normalizeGenres seeds models.GenreTaxonomy and merges the genres saved verbatim from provider
+// categories into the hierarchy, their books and readers move to the canonical genre
+func (r *bookRepository) normalizeGenres() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		paths := make([]string, 0, len(models.GenreTaxonomy))
+		for path := range models.GenreTaxonomy {
+			paths = append(paths, path)
+		}
+		slices.Sort(paths)
+		for _, path := range paths {
+			genre, err := resolveGenre(tx, path)
+			if err != nil {
+				return err
+			}
+			for _, alias := range models.GenreTaxonomy[path] {
+				if err := saveGenreAlias(tx, alias, genre.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		var legacy []*models.Genre
+		if err := tx.Where("path = '' OR path IS NULL").Order("id").Find(&legacy).Error; err != nil {
+			return err
+		}
+		for _, genre := range legacy {
+			if err := mergeGenre(tx, genre); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func mergeGenre(tx *gorm.DB, genre *models.Genre) error {
+	if strings.TrimSpace(genre.Name) == "" {
+		return nil
+	}
+	canonical, err := resolveGenre(tx, genre.Name)
+	if err != nil || canonical.ID == genre.ID {
+		return err
+	}
+
+	// a book or reader may already have the canonical genre, the primary keys drop those rows
+	for _, table := range []string{"book_genres", "user_genres"} {
+		if err := tx.Exec("UPDATE OR IGNORE "+table+" SET genre_id = ? WHERE genre_id = ?", canonical.ID, genre.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM "+table+" WHERE genre_id = ?", genre.ID).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.GenreAlias{}).Where("genre_id = ?", genre.ID).Update("genre_id", canonical.ID).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(genre).Error
+}
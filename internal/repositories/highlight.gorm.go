@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"log"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+type highlightRepository struct {
+	db *gorm.DB
+	// fullTextSearch is false when sqlite was built without FTS5, see bookRepository
+	fullTextSearch bool
+}
+
+func NewHighlightRepository(db *gorm.DB) *highlightRepository {
+	r := &highlightRepository{
+		db:             db,
+		fullTextSearch: hasSearchIndex(db, "highlights_fts"),
+	}
+	if !r.fullTextSearch {
+		log.Printf("full-text search of highlights unavailable, falling back to LIKE queries")
+	}
+	return r
+}
+
+// createSearchIndex creates the highlights_fts virtual table and indexes the highlights saved before it existed
+func (r *highlightRepository) createSearchIndex() error {
+	var exists int64
+	if err := r.db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'highlights_fts'").Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE VIRTUAL TABLE highlights_fts USING fts5(
+			highlight_id UNINDEXED,
+			text,
+			note,
+			tags,
+			tokenize = 'unicode61 remove_diacritics 2'
+		)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO highlights_fts (highlight_id, text, note, tags)
+			SELECT id, text, note, tags FROM highlights WHERE deleted_at IS NULL`).Error
+	})
+}
+
+func (r *highlightRepository) Create(highlight *models.Highlight) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("UserBook", "DailyProgressLog").Create(highlight).Error; err != nil {
+			return err
+		}
+		return r.index(tx, highlight)
+	})
+}
+
+func (r *highlightRepository) Get(id string) (*models.Highlight, error) {
+	highlight := &models.Highlight{}
+	return highlight, r.db.Preload("UserBook.Book").Preload("DailyProgressLog").First(highlight, "id = ?", id).Error
+}
+
+func (r *highlightRepository) Update(highlight *models.Highlight) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(highlight).
+			Select("page", "daily_progress_log_id", "text", "note", "tags", "updated_at").
+			Updates(highlight).Error
+		if err != nil {
+			return err
+		}
+		return r.index(tx, highlight)
+	})
+}
+
+func (r *highlightRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		highlight := &models.Highlight{}
+		if err := tx.First(highlight, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(highlight).Error; err != nil {
+			return err
+		}
+		if !r.fullTextSearch {
+			return nil
+		}
+		return tx.Exec("DELETE FROM highlights_fts WHERE highlight_id = ?", highlight.ID).Error
+	})
+}
+
+// GetByUserBook returns the highlights of the book in reading order, the ones without a page last
+func (r *highlightRepository) GetByUserBook(userBookID string) ([]*models.Highlight, error) {
+	highlights := []*models.Highlight{}
+	return highlights, r.db.Preload("DailyProgressLog").
+		Where("user_book_id = ?", userBookID).
+		Order("page IS NULL, page, created_at").
+		Find(&highlights).Error
+}
+
+// GetImported returns the book's highlights that came from an import, the ones with a source key
+func (r *highlightRepository) GetImported(userBookID uint) ([]*models.Highlight, error) {
+	highlights := []*models.Highlight{}
+	return highlights, r.db.Where("user_book_id = ? AND source_key <> ''", userBookID).
+		Find(&highlights).Error
+}
+
+// Search finds the user's highlights by their text, note and tags, best matches first.
+// An empty query returns every highlight, the latest first.
+func (r *highlightRepository) Search(userID, query string) ([]*models.Highlight, error) {
+	highlights := []*models.Highlight{}
+	search := r.db.Preload("UserBook.Book").Preload("DailyProgressLog").
+		Joins("JOIN user_books ON user_books.id = highlights.user_book_id AND user_books.deleted_at IS NULL").
+		Where("user_books.user_google_id = ?", userID)
+
+	terms := searchTerms(query)
+	switch {
+	case len(terms) == 0:
+		search = search.Order("highlights.created_at DESC")
+	case r.fullTextSearch:
+		match := make([]string, len(terms))
+		for i, term := range terms {
+			match[i] = `"` + term + `"*`
+		}
+		search = search.Joins("JOIN highlights_fts ON highlights_fts.highlight_id = highlights.id").
+			Where("highlights_fts MATCH ?", strings.Join(match, " ")).
+			Order("bm25(highlights_fts, 0.0, 5.0, 2.0, 3.0)")
+	default:
+		for _, term := range terms {
+			like := "%" + term + "%"
+			search = search.Where("highlights.text LIKE ? OR highlights.note LIKE ? OR highlights.tags LIKE ?", like, like, like)
+		}
+		search = search.Order("highlights.created_at DESC")
+	}
+	return highlights, search.Find(&highlights).Error
+}
+
+// index keeps the full-text index in sync with the highlights table
+func (r *highlightRepository) index(tx *gorm.DB, highlight *models.Highlight) error {
+	if !r.fullTextSearch {
+		return nil
+	}
+	if err := tx.Exec("DELETE FROM highlights_fts WHERE highlight_id = ?", highlight.ID).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO highlights_fts (highlight_id, text, note, tags) VALUES (?, ?, ?, ?)",
+		highlight.ID, highlight.Text, highlight.Note, highlight.Tags).Error
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) *importRepository {
+	return &importRepository{
+		db: db,
+	}
+}
+
+func (r *importRepository) Create(job *models.ImportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *importRepository) Update(job *models.ImportJob) error {
+	return r.db.Omit("Rows").Save(job).Error
+}
+
+func (r *importRepository) SaveRow(row *models.ImportRow) error {
+	return r.db.Save(row).Error
+}
+
+func (r *importRepository) Get(id, userId string) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	return job, r.db.Preload("Rows", func(db *gorm.DB) *gorm.DB {
+		return db.Order("line")
+	}).
+		Where("user_google_id = ?", userId).
+		First(job, "id = ?", id).Error
+}
+
+func (r *importRepository) GetAll(userId string) ([]*models.ImportJob, error) {
+	jobs := []*models.ImportJob{}
+	return jobs, r.db.Where("user_google_id = ?", userId).
+		Order("created_at DESC").
+		Find(&jobs).Error
+}
+
+// FailUnfinished fails the jobs that were pending or running when the server stopped
+func (r *importRepository) FailUnfinished(reason string) error {
+	return r.db.Model(&models.ImportJob{}).
+		Where("status IN ?", []models.ImportJobStatus{models.ImportJobStatusPending, models.ImportJobStatusRunning}).
+		Updates(map[string]any{"status": models.ImportJobStatusFailed, "error": reason}).Error
+}
+
+// FindByHash returns the latest job of the user that imported a file with the same content
+func (r *importRepository) FindByHash(userId, hash string) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	return job, r.db.Where("user_google_id = ? AND file_hash = ?", userId, hash).
+		Order("created_at DESC").
+		First(job).Error
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+// Migrate fills in the data of rows written before the columns existed,
+// it runs once the schema is migrated
+func Migrate(db *gorm.DB) error {
+	if err := convertNumericISBNs(db); err != nil {
+		return fmt.Errorf("converting numeric isbns: %w", err)
+	}
+	books := &bookRepository{db: db}
+	fullTextSearch, err := fullTextSearchAvailable(db)
+	if err != nil {
+		return err
+	}
+	if fullTextSearch {
+		if err := books.createSearchIndex(); err != nil {
+			return fmt.Errorf("creating the book search index: %w", err)
+		}
+		if err := (&highlightRepository{db: db}).createSearchIndex(); err != nil {
+			return fmt.Errorf("creating the highlight search index: %w", err)
+		}
+	}
+	if err := books.assignMissingMatchKeys(); err != nil {
+		return fmt.Errorf("storing the match keys of saved books: %w", err)
+	}
+	if err := books.assignMissingWorks(); err != nil {
+		return fmt.Errorf("grouping saved books into works: %w", err)
+	}
+	if err := books.normalizeGenres(); err != nil {
+		return fmt.Errorf("merging saved genres into the genre hierarchy: %w", err)
+	}
+	if err := books.assignMissingAuthors(); err != nil {
+		return fmt.Errorf("linking saved books to their authors: %w", err)
+	}
+	if err := assignMissingStatuses(db); err != nil {
+		return fmt.Errorf("assigning reading statuses: %w", err)
+	}
+	return nil
+}
+
+// fullTextSearchAvailable says if sqlite was built with FTS5, see the sqlite_fts5 build tag
+func fullTextSearchAvailable(db *gorm.DB) (bool, error) {
+	var used int
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error
+	return used == 1, err
+}
+
+// hasSearchIndex says if the migration created the full-text table and this sqlite can read it
+func hasSearchIndex(db *gorm.DB, table string) bool {
+	var exists int64
+	err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists).Error
+	if err != nil || exists == 0 {
+		return false
+	}
+	// fails when the table was created by a binary with FTS5 and this one has none
+	return db.Exec("SELECT 1 FROM "+table+" LIMIT 1").Error == nil
+}
+
+// assignMissingStatuses puts books added before the shelves existed on the shelf their progress points to
+func assignMissingStatuses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE user_books SET status = ?,
+				started_at = (SELECT start_date FROM reading_progresses WHERE user_book_id = user_books.id AND deleted_at IS NULL),
+				finished_at = (SELECT MIN(end_date, updated_at) FROM reading_progresses WHERE user_book_id = user_books.id AND deleted_at IS NULL),
+				status_changed_at = (SELECT updated_at FROM reading_progresses WHERE user_book_id = user_books.id AND deleted_at IS NULL)
+			WHERE (status IS NULL OR status = '') AND id IN
+				(SELECT user_book_id FROM reading_progresses WHERE completed AND deleted_at IS NULL)`,
+			models.UserBookStatusRead).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE user_books SET status = ?,
+				started_at = (SELECT start_date FROM reading_progresses WHERE user_book_id = user_books.id AND deleted_at IS NULL),
+				status_changed_at = (SELECT created_at FROM reading_progresses WHERE user_book_id = user_books.id AND deleted_at IS NULL)
+			WHERE (status IS NULL OR status = '') AND id IN
+				(SELECT user_book_id FROM reading_progresses WHERE deleted_at IS NULL)`,
+			models.UserBookStatusReading).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE user_books SET status = ?, status_changed_at = created_at
+			WHERE status IS NULL OR status = ''`, models.UserBookStatusWantToRead).Error
+	})
+}
+
+// convertNumericISBNs moves the numeric isbn column of the books saved before ISBN-10 and
+// ISBN-13 were kept apart into isbn13 and isbn10, then drops it. Numbers that are not a valid
+// ISBN are left out, the leading zeros an ISBN-10 lost as a number are put back first.
+func convertNumericISBNs(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Book{}, "isbn") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		rows := []struct {
+			ID   string
+			ISBN uint64
+		}{}
+		err := tx.Table("books").Select("id, isbn").
+			Where("isbn IS NOT NULL AND isbn != 0").
+			Where("isbn13 IS NULL OR isbn13 = ''").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			parsed, err := isbn.Parse(fmt.Sprintf("%010d", row.ISBN))
+			if err != nil {
+				continue
+			}
+			book := &models.Book{}
+			book.SetISBN(parsed)
+			err = tx.Model(&models.Book{}).Where("id = ?", row.ID).
+				Updates(map[string]any{"isbn13": book.ISBN13, "isbn10": book.ISBN10}).Error
+			if err != nil {
+				return err
+			}
+		}
+		// the migrator drops a column by copying the table, which would fire the ON DELETE
+		// of every row pointing at a book
+		return tx.Exec("ALTER TABLE books DROP COLUMN isbn").Error
+	})
+}
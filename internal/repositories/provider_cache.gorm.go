@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type providerCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewProviderCacheRepository(db *gorm.DB) *providerCacheRepository {
+	return &providerCacheRepository{
+		db: db,
+	}
+}
+
+func (r *providerCacheRepository) Get(key string) (*models.ProviderCacheEntry, error) {
+	entry := &models.ProviderCacheEntry{}
+	if err := r.db.First(entry, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *providerCacheRepository) Save(entry *models.ProviderCacheEntry) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payload", "fetched_at"}),
+	}).Create(entry).Error
+}
+
+func (r *providerCacheRepository) DeleteOlderThan(t time.Time) error {
+	return r.db.Where("fetched_at < ?", t).Delete(&models.ProviderCacheEntry{}).Error
+}
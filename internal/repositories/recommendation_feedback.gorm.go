@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type recommendationFeedbackRepository struct {
+	db *gorm.DB
+}
+
+func NewRecommendationFeedbackRepository(db *gorm.DB) *recommendationFeedbackRepository {
+	return &recommendationFeedbackRepository{
+		db: db,
+	}
+}
+
+// Save stores the feedback, new feedback on the same book replaces the old one
+func (r *recommendationFeedbackRepository) Save(feedback *models.RecommendationFeedback) error {
+	return r.db.Omit("Book").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_google_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "updated_at"}),
+	}).Create(feedback).Error
+}
+
+func (r *recommendationFeedbackRepository) GetAll(userID string) ([]*models.RecommendationFeedback, error) {
+	feedback := []*models.RecommendationFeedback{}
+	return feedback, r.db.Preload("Book.Genres").
+		Where("user_google_id = ?", userID).
+		Order("created_at").
+		Find(&feedback).Error
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+)
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) *reviewRepository {
+	return &reviewRepository{
+		db: db,
+	}
+}
+
+func (r *reviewRepository) Create(review *models.Review) error {
+	return r.db.Omit("User", "Book").Create(review).Error
+}
+
+func (r *reviewRepository) Get(id string) (*models.Review, error) {
+	review := &models.Review{}
+	return review, r.db.Preload("User").Preload("Book").First(review, "id = ?", id).Error
+}
+
+func (r *reviewRepository) Update(review *models.Review) error {
+	return r.db.Model(review).Select("rating", "text", "spoiler", "updated_at").Updates(review).Error
+}
+
+func (r *reviewRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&models.Review{}).Error
+}
+
+// GetForBook returns the reviews of the book and of its other editions, the latest first
+func (r *reviewRepository) GetForBook(book *models.Book) ([]*models.Review, error) {
+	reviews := []*models.Review{}
+	query := r.db.Preload("User").Preload("Book")
+	if book.WorkID != nil {
+		editions := r.db.Model(&models.Book{}).Select("id").Where("work_id = ?", *book.WorkID)
+		query = query.Where("book_id = ? OR book_id IN (?)", book.ID, editions)
+	} else {
+		query = query.Where("book_id = ?", book.ID)
+	}
+	return reviews, query.Order("updated_at DESC").Find(&reviews).Error
+}
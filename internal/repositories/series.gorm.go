@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type seriesRepository struct {
+	db *gorm.DB
+}
+
+func NewSeriesRepository(db *gorm.DB) *seriesRepository {
+	return &seriesRepository{
+		db: db,
+	}
+}
+
+// GetForBooks returns the series with any of the books or another edition of them, with all their volumes
+func (r *seriesRepository) GetForBooks(bookIDs []string) ([]*models.Series, error) {
+	series := []*models.Series{}
+	if len(bookIDs) == 0 {
+		return series, nil
+	}
+
+	works := r.db.Model(&models.Book{}).Select("work_id").Where("id IN ? AND work_id IS NOT NULL", bookIDs)
+	seriesIDs := r.db.Model(&models.SeriesEntry{}).Select("series_entries.series_id").
+		Joins("JOIN books ON books.id = series_entries.book_id").
+		Where("books.id IN ? OR books.work_id IN (?)", bookIDs, works)
+	err := r.db.Preload("Entries.Book").
+		Where("id IN (?)", seriesIDs).
+		Order("name").
+		Find(&series).Error
+	return series, err
+}
+
+// AddBook puts the book in the series with the name, the series is created when there is none.
+// A book already in the series moves to the new position.
+func (r *seriesRepository) AddBook(name, bookID string, position float64, userID string) (*models.Series, error) {
+	series := &models.Series{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(models.Series{Key: models.SeriesKey(name)}).Attrs(models.Series{Name: name}).FirstOrCreate(series).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "series_id"}, {Name: "book_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"position", "created_by"}),
+		}).Create(&models.SeriesEntry{SeriesID: series.ID, BookID: bookID, Position: position, CreatedBy: userID}).Error
+	})
+	return series, err
+}
+
+// RemoveBook takes the book out of the series, only the user who put it there can
+func (r *seriesRepository) RemoveBook(seriesID, bookID, userID string) error {
+	result := r.db.Where("series_id = ? AND book_id = ? AND created_by = ?", seriesID, bookID, userID).
+		Delete(&models.SeriesEntry{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrSeriesEntryNotFound
+	}
+	return nil
+}
+
+// saveSeriesEntry puts a book in the series its provider reports, matched by the provider's series id
+// and then by name. A series is only created when the provider names it.
+func saveSeriesEntry(tx *gorm.DB, book *models.Book) error {
+	info := book.Series
+	if info == nil || info.Position <= 0 {
+		return nil
+	}
+
+	found := []*models.Series{}
+	if info.ProviderID != "" {
+		if err := tx.Where("provider_series_id = ?", info.ProviderID).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+	}
+	if len(found) == 0 && models.SeriesKey(info.Name) != "" {
+		if err := tx.Where("key = ?", models.SeriesKey(info.Name)).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+	}
+
+	var series *models.Series
+	switch {
+	case len(found) > 0:
+		series = found[0]
+		if series.ProviderSeriesID == "" && info.ProviderID != "" {
+			if err := tx.Model(series).Update("provider_series_id", info.ProviderID).Error; err != nil {
+				return err
+			}
+		}
+	case models.SeriesKey(info.Name) != "":
+		series = &models.Series{Name: info.Name, Key: models.SeriesKey(info.Name), ProviderSeriesID: info.ProviderID}
+		if err := tx.Create(series).Error; err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SeriesEntry{SeriesID: series.ID, BookID: book.ID, Position: info.Position}).Error
+}
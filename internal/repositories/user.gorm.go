@@ -60,8 +60,7 @@ func (r *userRepository) RemoveGenre(user *models.User, genre *models.Genre) err
 }
 
 func (r *userRepository) FindOrCreateGenre(genreName string) (*models.Genre, error) {
-	genre := &models.Genre{}
-	return genre, r.db.FirstOrCreate(genre, models.Genre{Name: genreName}).Error
+	return resolveGenre(r.db, genreName)
 }
 
 func (r *userRepository) FirstGenre(genreID string) (*models.Genre, error) {
@@ -69,7 +68,11 @@ func (r *userRepository) FirstGenre(genreID string) (*models.Genre, error) {
 	return genre, r.db.First(genre, "id = ?", genreID).Error
 }
 
+// GetAllGenres returns the genres the profile offers, the top two levels of the hierarchy
+// with every genre followed by its children
 func (r *userRepository) GetAllGenres() ([]*models.Genre, error) {
 	var genres []*models.Genre
-	return genres, r.db.Find(&genres).Error
+	return genres, r.db.Where("path NOT LIKE ?", "%"+models.GenrePathSeparator+"%"+models.GenrePathSeparator+"%").
+		Order("path").
+		Find(&genres).Error
 }
@@ -26,7 +26,7 @@ func (r *userBookRepository) Get(id string) (*models.UserBook, error) {
 
 func (r *userBookRepository) GetAllUserBooks(userId string) ([]*models.UserBook, error) {
 	userBooks := []*models.UserBook{}
-	return userBooks, r.db.Preload("Book").Preload("ReadingProgress").
+	return userBooks, r.db.Preload("Book.Genres").Preload("ReadingProgress").
 		Where("user_google_id = ?", userId).
 		Where("deleted_at IS NULL").
 		Find(&userBooks).Error
@@ -40,16 +40,24 @@ func (r *userBookRepository) DeleteWhereBookId(bookId string) error {
 	return r.db.Where("book_id = ?", bookId).Delete(&models.UserBook{}).Error
 }
 
-func (r *userBookRepository) Search(userId, query string) ([]*models.UserBook, error) {
+// SetStatus moves the user book to the shelf and records the move
+func (r *userBookRepository) SetStatus(id uint, status models.UserBookStatus) (*models.UserBook, error) {
+	return models.MoveUserBook(r.db, id, status)
+}
+
+func (r *userBookRepository) Search(userId, query string, status models.UserBookStatus) ([]*models.UserBook, error) {
 	var userBooks []*models.UserBook
 
-	err := r.db.Preload("Book").
+	db := r.db.Preload("Book").
 		Preload("ReadingProgress").
 		Joins("JOIN books ON books.id = user_books.book_id"). // Join the books table
 		Where("user_books.user_google_id = ?", userId).       // Filter by current user
 		Where("books.title LIKE ?", "%"+query+"%").           // Use the books table for the title
-		Where("user_books.deleted_at IS NULL").
-		Order("user_books.created_at DESC").
+		Where("user_books.deleted_at IS NULL")
+	if status != "" {
+		db = db.Where("user_books.status = ?", status)
+	}
+	err := db.Order("user_books.created_at DESC").
 		Find(&userBooks).Error
 	if err != nil {
 		return nil, err
@@ -57,3 +65,65 @@ func (r *userBookRepository) Search(userId, query string) ([]*models.UserBook, e
 
 	return userBooks, nil
 }
+
+func (r *userBookRepository) GetByUserAndBook(userId, bookId string) (*models.UserBook, error) {
+	userBook := &models.UserBook{}
+	return userBook, r.db.Preload("Book").Preload("ReadingProgress").
+		Where("user_google_id = ? AND book_id = ?", userId, bookId).
+		First(userBook).Error
+}
+
+// FindInBatches loads the user's books with their series, shelf history, progress and logs batchSize rows at a time
+func (r *userBookRepository) FindInBatches(userId string, batchSize int, fn func(userBooks []*models.UserBook) error) error {
+	userBooks := []*models.UserBook{}
+	return r.db.Preload("Book").
+		Preload("Book.Genres").
+		Preload("StatusChanges", func(db *gorm.DB) *gorm.DB {
+			return db.Order("changed_at")
+		}).
+		Preload("ReadingProgress").
+		Preload("ReadingProgress.DailyProgress", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date")
+		}).
+		Where("user_google_id = ?", userId).
+		FindInBatches(&userBooks, batchSize, func(tx *gorm.DB, batch int) error {
+			if err := r.loadSeries(userBooks); err != nil {
+				return err
+			}
+			return fn(userBooks)
+		}).Error
+}
+
+// loadSeries fills in the series the books are in, a book in several series gets the first one
+func (r *userBookRepository) loadSeries(userBooks []*models.UserBook) error {
+	bookIDs := make([]string, len(userBooks))
+	for i, userBook := range userBooks {
+		bookIDs[i] = userBook.BookID
+	}
+
+	var entries []struct {
+		BookID           string
+		Name             string
+		ProviderSeriesID string
+		Position         float64
+	}
+	err := r.db.Model(&models.SeriesEntry{}).
+		Select("series_entries.book_id, series.name, series.provider_series_id, series_entries.position").
+		Joins("JOIN series ON series.id = series_entries.series_id").
+		Where("series_entries.book_id IN ?", bookIDs).
+		Order("series.name").
+		Scan(&entries).Error
+	if err != nil {
+		return err
+	}
+
+	for _, userBook := range userBooks {
+		for _, entry := range entries {
+			if entry.BookID == userBook.BookID {
+				userBook.Book.Series = &models.SeriesInfo{Name: entry.Name, ProviderID: entry.ProviderSeriesID, Position: entry.Position}
+				break
+			}
+		}
+	}
+	return nil
+}
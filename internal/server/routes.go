@@ -1,9 +1,14 @@
 package server
 
 import (
+	"cmp"
+	"context"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/FilipBudzynski/book_it/cmd/web"
+	"github.com/FilipBudzynski/book_it/internal/database"
 	"github.com/FilipBudzynski/book_it/internal/handlers"
 	"github.com/FilipBudzynski/book_it/internal/providers"
 	"github.com/FilipBudzynski/book_it/internal/repositories"
@@ -39,21 +44,71 @@ func (s *Server) WithRegisterRoutes(e *echo.Echo) *Server {
 	exchangeRequestRepo := repositories.NewExchangeRequestRepository(db)
 	bookRepo := repositories.NewBookRepository(db)
 
+	httpClient := providers.NewHTTPClient(durationFromEnv("PROVIDER_TIMEOUT", providers.DefaultRequestTimeout))
+	providerTransport := providers.NewResilientTransport(httpClient.Transport).
+		WithRateLimit(floatFromEnv("PROVIDER_RATE_LIMIT", providers.DefaultRateLimit), providers.DefaultRateBurst).
+		WithBreaker(providers.DefaultBreakerThreshold, durationFromEnv("PROVIDER_BREAKER_COOLDOWN", providers.DefaultBreakerCooldown))
+	httpClient.Transport = providerTransport
+	bookProvider := providers.NewCachedProvider(
+		providers.NewCompositeProvider(
+			providers.NewGoogleProviderWithClient(httpClient),
+			providers.NewOpenLibraryProviderWithClient(providers.OpenLibraryAPI, httpClient),
+		),
+		repositories.NewProviderCacheRepository(db),
+	).
+		WithTTL(durationFromEnv("PROVIDER_CACHE_TTL", providers.DefaultCacheTTL)).
+		WithStaleWindow(durationFromEnv("PROVIDER_CACHE_STALE_WINDOW", providers.DefaultCacheStaleWindow))
+	if err := bookProvider.Prune(); err != nil {
+		log.Printf("failed to prune provider cache: %v", err)
+	}
+
 	userService := services.NewUserService(userRepo)
 	userBookService := services.NewUserBookService(userBookRepo, exchangeRequestRepo)
 	progressService := services.NewProgressService(progressRepo)
-	bookService := services.NewBookService(bookRepo).WithProvider(providers.NewGoogleProvider())
+	similarityRepo := repositories.NewBookSimilarityRepository(db)
+	services.NewSimilarityService(similarityRepo).
+		WithInterval(durationFromEnv("RECOMMENDATION_CF_INTERVAL", services.DefaultSimilarityInterval)).
+		Start(context.Background())
+	reviewService := services.NewReviewService(repositories.NewReviewRepository(db))
+	seriesService := services.NewSeriesService(repositories.NewSeriesRepository(db))
+	coverDir := cmp.Or(os.Getenv("COVER_STORAGE_DIR"), services.DefaultCoverDir)
+	bookService := services.NewBookService(bookRepo).
+		WithProvider(bookProvider).
+		WithSimilarBooks(similarityRepo, floatFromEnv("RECOMMENDATION_CF_WEIGHT", services.DefaultCollaborativeWeight)).
+		WithSeries(seriesService).
+		WithCoverDir(coverDir)
 	exchangeService := services.NewExchangeService(exchangeRequestRepo)
+	highlightRepo := repositories.NewHighlightRepository(db)
+	importService := services.NewImportService(repositories.NewImportRepository(db), bookService, bookRepo, userBookRepo, progressRepo).
+		WithHighlights(highlightRepo)
+	if err := importService.FailInterrupted(); err != nil {
+		log.Printf("failed to fail interrupted imports: %v", err)
+	}
 
 	notifyManager = handlers.NewConnectionManager()
 
 	routeRegistrars := []RouteRegistrar{
+		handlers.NewHealthHandler(&database.Repository{Db: db}, providerTransport, bookProvider),
 		handlers.NewAuthHandler(userService),
 		handlers.NewUserHandler(userService),
-		handlers.NewBookHandler(bookService, userBookService, userService),
-		handlers.NewUserBookHandler(userBookService),
-		handlers.NewProgressHandler(progressService, userBookService),
+		handlers.NewBookHandler(bookService, userBookService, userService).
+			WithFeedback(services.NewRecommendationFeedbackService(repositories.NewRecommendationFeedbackRepository(db))),
+		handlers.NewAuthorHandler(services.NewAuthorService(repositories.NewAuthorRepository(db), exchangeRequestRepo), userBookService, userService),
+		handlers.NewUserBookHandler(userBookService).WithSeries(seriesService),
+		handlers.NewSeriesHandler(seriesService, bookService),
+		handlers.NewReviewHandler(reviewService, bookService),
+		handlers.NewHighlightHandler(
+			services.NewHighlightService(highlightRepo, userBookRepo, progressRepo),
+			progressService,
+		),
+		handlers.NewProgressHandler(progressService, userBookService).WithReviews(reviewService),
 		handlers.NewExchangeHandler(exchangeService, bookService, userService).WithNotifier(notifyManager),
+		handlers.NewImportHandler(importService),
+		handlers.NewExportHandler(services.NewExportService(userBookRepo)),
+		handlers.NewEbookHandler(
+			services.NewEbookService(repositories.NewEbookFileRepository(db), bookService, userBookRepo).
+				WithStorageDir(os.Getenv("EBOOK_STORAGE_DIR")),
+		),
 	}
 
 	for _, routeRegistrar := range routeRegistrars {
@@ -63,6 +118,7 @@ func (s *Server) WithRegisterRoutes(e *echo.Echo) *Server {
 	fileServer := http.FileServer(http.FS(web.Files))
 	e.GET("/assets/*", echo.WrapHandler(fileServer))
 	e.Static("/static", "cmd/web")
+	e.Static(services.CoverURLPrefix, coverDir)
 
 	e.GET("/sse", notifyManager.SseHandler)
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -38,6 +39,33 @@ func NewServer() *http.Server {
 	return server
 }
 
+// durationFromEnv parses a time.Duration (e.g. "12h") from the environment, falling back to the default
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid duration in %s: %v, using %s", key, err, fallback)
+		return fallback
+	}
+	return duration
+}
+
+func floatFromEnv(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid number in %s: %v, using %g", key, err, fallback)
+		return fallback
+	}
+	return number
+}
+
 func (s *Server) ToEchoHttpHandler(e *echo.Echo) http.Handler {
 	return e
 }
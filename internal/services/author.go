@@ -0,0 +1,84 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/FilipBudzynski/book_it/internal/geo"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// NearbyOfferDistance is how far in kilometers an offer on the author page can be
+const NearbyOfferDistance = 50.0
+
+type AuthorRepository interface {
+	Get(id string) (*models.Author, error)
+	GetByName(name string) (*models.Author, error)
+}
+
+type authorService struct {
+	repo         AuthorRepository
+	exchangeRepo ExchangeRequestRepository
+}
+
+func NewAuthorService(repo AuthorRepository, exchangeRepo ExchangeRequestRepository) *authorService {
+	return &authorService{
+		repo:         repo,
+		exchangeRepo: exchangeRepo,
+	}
+}
+
+func (s *authorService) Get(id string) (*models.Author, error) {
+	return s.repo.Get(id)
+}
+
+func (s *authorService) GetByName(name string) (*models.Author, error) {
+	if models.AuthorKey(name) == "" {
+		return nil, models.ErrAuthorNameEmpty
+	}
+	return s.repo.GetByName(name)
+}
+
+// NearbyOffers returns the author's books other users offer for exchange, closest first. Offers further
+// than NearbyOfferDistance from the user are left out, without a location the user sees every offer.
+func (s *authorService) NearbyOffers(author *models.Author, user *models.User) ([]*models.BookOffer, error) {
+	bookIDs := make([]string, len(author.Books))
+	authorBooks := make(map[string]bool, len(author.Books))
+	for i, book := range author.Books {
+		bookIDs[i] = book.ID
+		authorBooks[book.ID] = true
+	}
+
+	requests, err := s.exchangeRepo.GetActiveOffering(bookIDs, user.GoogleId)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := []*models.BookOffer{}
+	for _, request := range requests {
+		offer := &models.BookOffer{ExchangeRequest: request}
+		if user.Location != nil {
+			offer.Distance = geo.HaversineDistance(
+				geo.Cord{Lat: user.Location.Latitude, Lon: user.Location.Longitude},
+				geo.Cord{Lat: request.Latitude, Lon: request.Longitude},
+				geo.Km,
+			)
+			offer.DistanceKnown = true
+			if offer.Distance > NearbyOfferDistance {
+				continue
+			}
+		}
+		for _, offered := range request.OfferedBooks {
+			if authorBooks[offered.BookID] {
+				bookOffer := *offer
+				bookOffer.Book = &offered.Book
+				offers = append(offers, &bookOffer)
+			}
+		}
+	}
+
+	slices.SortStableFunc(offers, func(a, b *models.BookOffer) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.Book.Title, b.Book.Title))
+	})
+	return offers, nil
+}
@@ -1,25 +1,46 @@
 package services
 
 import (
-	"math/rand/v2"
+	"cmp"
+	"context"
 	"slices"
+	"strings"
 
 	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/isbn"
 	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
 )
 
-const MaxRecommendationsResults = 30
+const (
+	SearchPageSize = 40
+	// how many provider pages one search goes through when filtering leaves the page short
+	maxProviderPagesPerSearch = 5
+	// how many of the genres the user reads most are searched for recommendations
+	maxLibraryRecommendationGenres = 3
+	// how many of the books similar readers have are added to the candidates
+	maxCollaborativeCandidates = 50
+)
 
 type BookRepository interface {
 	Create(book *models.Book) error
 	Get(id string) (*models.Book, error)
+	GetByISBN(isbn13 string) (*models.Book, error)
+	GetByWork(workID uint) ([]*models.Book, error)
 	Delete(id string) error
 	GetByGenre(genre string) ([]*models.Book, error)
+	Search(query string, filters models.SearchFilters, limit, page int) ([]*models.Book, error)
+	ReplaceBookReferences(oldID, newID string) error
+	ReplaceUserBookReferences(oldID, newID, userID string) error
 }
 
 type bookService struct {
-	provider handlers.BookProvider
-	repo     BookRepository
+	provider            handlers.BookProvider
+	repo                BookRepository
+	similarBooks        handlers.SimilarBooks
+	collaborativeWeight float64
+	series              handlers.SeriesVolumes
+	coverDir            string
 }
 
 func NewBookService(repo BookRepository) handlers.BookService {
@@ -33,6 +54,25 @@ func (s *bookService) WithProvider(provider handlers.BookProvider) handlers.Book
 	return s
 }
 
+// WithSimilarBooks blends the collaborative filter into recommendations, weight is its share of the score
+func (s *bookService) WithSimilarBooks(similarBooks handlers.SimilarBooks, weight float64) handlers.BookService {
+	s.similarBooks = similarBooks
+	s.collaborativeWeight = weight
+	return s
+}
+
+// WithSeries recommends the next volume of the series the user has started
+func (s *bookService) WithSeries(series handlers.SeriesVolumes) handlers.BookService {
+	s.series = series
+	return s
+}
+
+// WithCoverDir keeps the covers uploaded for manual books in dir, without it uploaded covers are dropped
+func (s *bookService) WithCoverDir(dir string) handlers.BookService {
+	s.coverDir = dir
+	return s
+}
+
 func (s *bookService) Provider() handlers.BookProvider {
 	return s.provider
 }
@@ -45,13 +85,13 @@ func (s *bookService) Create(book *models.Book) error {
 	return s.repo.Create(book)
 }
 
-func (s *bookService) GetByID(bookId string) (*models.Book, error) {
+func (s *bookService) GetByID(ctx context.Context, bookId string) (*models.Book, error) {
 	book, err := s.repo.Get(bookId)
 	if err == nil && book != nil {
 		return book, nil
 	}
 
-	book, err = s.provider.GetBook(bookId)
+	book, err = s.provider.GetBook(ctx, bookId)
 	if err != nil {
 		return nil, err
 	}
@@ -63,60 +103,317 @@ func (s *bookService) GetByID(bookId string) (*models.Book, error) {
 	return book, nil
 }
 
-func (s *bookService) GetByQuery(query string, queryType handlers.QueryType, page int) ([]*models.Book, error) {
-	books, err := s.provider.GetBooksByQuery(query, queryType, 40, page)
+// CreateManual saves a book added by the user because no provider has it
+func (s *bookService) CreateManual(userID string, input *models.ManualBook) (*models.Book, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	book := input.Book(userID)
+	if book.ISBN13 != "" {
+		if existing, err := s.repo.GetByISBN(book.ISBN13); err == nil && existing != nil {
+			return nil, models.ErrManualBookISBNExists
+		}
+	}
+
+	if strings.HasPrefix(book.ImageLink, "data:") {
+		link, err := s.storeCover(book.ID, book.ImageLink)
+		if err != nil {
+			return nil, err
+		}
+		book.ImageLink = link
+	}
+
+	if err := s.Create(book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// LinkManualBook replaces a manual book with a provider book, identified by its id or isbn,
+// once the provider has it. Shelves and exchange requests are moved to the provider book,
+// the ones of other users only when the provider book has the isbn or the title and author of the manual book.
+func (s *bookService) LinkManualBook(ctx context.Context, userID, manualID, target string) (*models.Book, error) {
+	manual, err := s.repo.Get(manualID)
+	if err != nil {
+		return nil, err
+	}
+	if !manual.IsManual() {
+		return nil, models.ErrManualBookNotManual
+	}
+	if manual.CreatedBy != userID {
+		return nil, models.ErrManualBookNotCreator
+	}
+
+	var book *models.Book
+	if isbn.Valid(target) {
+		book, err = s.GetByISBN(ctx, target)
+	} else {
+		book, err = s.GetByID(ctx, strings.TrimSpace(target))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if book.IsManual() {
+		return nil, models.ErrManualBookLinkToManual
+	}
+
+	if sameBook(manual, book) {
+		err = s.repo.ReplaceBookReferences(manual.ID, book.ID)
+	} else {
+		err = s.repo.ReplaceUserBookReferences(manual.ID, book.ID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// sameBook says if the provider book is the one the manual book was added for
+func sameBook(manual, book *models.Book) bool {
+	if manual.ISBN13 != "" && manual.ISBN13 == book.ISBN13 {
+		return true
+	}
+	if manual.ISBN10 != "" && manual.ISBN10 == book.ISBN10 {
+		return true
+	}
+	return models.WorkMatchKey(manual.Title, manual.Authors) == models.WorkMatchKey(book.Title, book.Authors)
+}
+
+// GetByISBN accepts an ISBN-10 or ISBN-13 in any notation, the saved books are checked before the provider
+func (s *bookService) GetByISBN(ctx context.Context, value string) (*models.Book, error) {
+	parsed, err := isbn.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := s.repo.GetByISBN(parsed.ISBN13())
+	if err == nil && book != nil {
+		return book, nil
+	}
+
+	books, err := s.provider.GetBooksByQuery(ctx, handlers.NewSearchQuery(parsed.ISBN13(), handlers.QueryTypeISBN), models.SearchFilters{}, 1, 1)
 	if err != nil {
 		return nil, err
 	}
 	for _, book := range books {
-		if dbBook, _ := s.repo.Get(book.ID); dbBook != nil {
+		if book.ISBN13 != parsed.ISBN13() {
 			continue
 		}
+		if dbBook, _ := s.repo.Get(book.ID); dbBook != nil {
+			return dbBook, nil
+		}
 		if err := s.Create(book); err != nil {
 			return nil, err
 		}
+		return book, nil
 	}
-	return books, nil
+	return nil, models.ErrBookISBNNotFound
 }
 
-func (s *bookService) FetchReccomendations(genres []models.Genre, userBooks []*models.UserBook) ([]*models.Book, error) {
-	userBookIDs := []string{}
-	for _, userBook := range userBooks {
-		userBookIDs = append(userBookIDs, userBook.Book.ID)
+// GetByQuery searches the provider from the page on, the filters it cannot apply itself are applied to
+// the results. When filtering leaves the page short the next provider pages are searched, until the page
+// is full or the results run out. It returns the provider page the next search starts from, zero when
+// there are no more results.
+func (s *bookService) GetByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, page int) ([]*models.Book, int, error) {
+	if query.IsZero() {
+		return []*models.Book{}, 0, nil
+	}
+	if query.ISBN != "" {
+		query.ISBN = normalizeISBNQuery(query.ISBN)
 	}
 
-	providerBooks := []*models.Book{}
-	for _, genre := range genres {
-		genreBooks, err := s.provider.GetBooksByGenre(genre.Name)
+	found := []*models.Book{}
+	for fetched := 0; fetched < maxProviderPagesPerSearch; fetched++ {
+		books, err := s.provider.GetBooksByQuery(ctx, query, filters, SearchPageSize, page)
+		if err != nil && fetched > 0 {
+			// the pages fetched so far are shown, the next search tries the failed page again
+			return models.DedupeByWork(found), page, nil
+		}
 		if err != nil {
-			return nil, err
+			// the books we already know about can still be found while the provider is unreachable
+			if localBooks, next, localErr := s.SearchLocal(query.Words(), filters, page); localErr == nil && len(localBooks) > 0 {
+				return localBooks, next, nil
+			}
+			return nil, 0, err
+		}
+		if err := s.saveNew(books); err != nil {
+			return nil, 0, err
+		}
+
+		found = append(found, filters.Apply(books)...)
+		page++
+		if len(books) < SearchPageSize {
+			return models.DedupeByWork(found), 0, nil
+		}
+		if len(found) >= SearchPageSize {
+			break
 		}
-		providerBooks = append(providerBooks, genreBooks...)
 	}
+	return models.DedupeByWork(found), page, nil
+}
 
-	for _, book := range providerBooks {
+// saveNew creates the books that are not saved yet and links the others to their saved work
+func (s *bookService) saveNew(books []*models.Book) error {
+	for _, book := range books {
 		if dbBook, _ := s.repo.Get(book.ID); dbBook != nil {
+			book.WorkID = dbBook.WorkID
 			continue
 		}
 		if err := s.Create(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEditions returns the saved editions of the work
+func (s *bookService) GetEditions(workID uint) ([]*models.Book, error) {
+	return s.repo.GetByWork(workID)
+}
+
+// normalizeISBNQuery turns hyphenated and 10 digit ISBNs into the ISBN-13 the providers index
+func normalizeISBNQuery(query string) string {
+	if parsed, err := isbn.Parse(query); err == nil {
+		return parsed.ISBN13()
+	}
+	return isbn.Clean(query)
+}
+
+// SearchLocal searches the books saved in the database without calling the provider,
+// it returns the page the next search starts from, zero when there are no more results
+func (s *bookService) SearchLocal(query string, filters models.SearchFilters, page int) ([]*models.Book, int, error) {
+	books, err := s.repo.Search(query, filters, SearchPageSize, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := 0
+	if len(books) == SearchPageSize {
+		next = page + 1
+	}
+	return models.DedupeByWork(books), next, nil
+}
+
+// FetchReccomendations ranks the books of the preferred genres and the genres the user reads most
+// against their library, see recommend.Rank. The next volumes of the series the user has started
+// come first, see recommend.ContinueSeries. The books the user turned down are left out, see
+// recommend.ApplyFeedback. The ranking is the same on every call, so pages stay stable.
+func (s *bookService) FetchReccomendations(ctx context.Context, genres []models.Genre, userBooks []*models.UserBook, feedback []*models.RecommendationFeedback, page int) ([]*models.Recommendation, error) {
+	userBooks = recommend.WithFeedback(userBooks, feedback)
+	seen := make(map[string]bool)
+	candidates := []*models.Book{}
+	var providerErr error
+	for _, genre := range recommendationGenres(genres, userBooks) {
+		genreBooks, err := s.provider.GetBooksByGenre(ctx, genre)
+		if err != nil {
+			providerErr = err
+		} else if err := s.saveNew(genreBooks); err != nil {
 			return nil, err
 		}
+		// the saved books keep recommendations coming while the provider is unreachable
+		if savedBooks, err := s.repo.GetByGenre(genre); err == nil {
+			genreBooks = append(genreBooks, savedBooks...)
+		}
+
+		for _, book := range genreBooks {
+			if !seen[book.ID] && book.LinkedBookID == "" {
+				seen[book.ID] = true
+				candidates = append(candidates, book)
+			}
+		}
 	}
 
-	resultBooks := []*models.Book{}
-	for _, book := range providerBooks {
-		if slices.Contains(userBookIDs, book.ID) {
+	collaborative, err := s.collaborativeScores(userBooks)
+	if err != nil {
+		return nil, err
+	}
+	// readers with the same books also point at books outside the searched genres
+	for _, bookID := range topScored(collaborative, maxCollaborativeCandidates) {
+		if seen[bookID] {
 			continue
 		}
-		resultBooks = append(resultBooks, book)
+		if book, err := s.repo.Get(bookID); err == nil && book.LinkedBookID == "" {
+			seen[bookID] = true
+			candidates = append(candidates, book)
+		}
+	}
+
+	if len(candidates) == 0 && providerErr != nil {
+		return nil, providerErr
+	}
+
+	ranked := recommend.Rank(userBooks, genres, models.DedupeByWork(candidates))
+	if len(collaborative) > 0 {
+		ranked = recommend.Blend(ranked, collaborative, s.collaborativeWeight)
+	}
+	if s.series != nil {
+		next, err := s.series.NextVolumes(userBooks)
+		if err != nil {
+			return nil, err
+		}
+		ranked = recommend.ContinueSeries(ranked, userBooks, next)
+	}
+	ranked = recommend.ApplyFeedback(ranked, feedback)
+	return recommend.Page(ranked, page, handlers.RecommendationsPageSize), nil
+}
+
+func (s *bookService) collaborativeScores(userBooks []*models.UserBook) (map[string]recommend.Collaborative, error) {
+	if s.similarBooks == nil || s.collaborativeWeight <= 0 || len(userBooks) == 0 {
+		return nil, nil
+	}
+	bookIDs := make([]string, len(userBooks))
+	for i, userBook := range userBooks {
+		bookIDs[i] = userBook.BookID
 	}
+	similarities, err := s.similarBooks.GetForBooks(bookIDs)
+	if err != nil {
+		return nil, err
+	}
+	return recommend.CollaborativeScores(userBooks, similarities), nil
+}
 
-	rand.Shuffle(len(resultBooks), func(i, j int) {
-		resultBooks[i], resultBooks[j] = resultBooks[j], resultBooks[i]
+// topScored returns the ids with the highest scores, best first
+func topScored(scores map[string]recommend.Collaborative, limit int) []string {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(scores[b].Score, scores[a].Score), cmp.Compare(a, b))
 	})
+	return ids[:min(len(ids), limit)]
+}
 
-	if len(resultBooks) < MaxRecommendationsResults {
-		return resultBooks, nil
+// recommendationGenres returns the preferred genres followed by the genres most common in the library
+func recommendationGenres(genres []models.Genre, userBooks []*models.UserBook) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	addGenre := func(name string) {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key != "" && !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	for _, genre := range genres {
+		addGenre(genre.Name)
+	}
+
+	counts := make(map[string]int)
+	libraryGenres := []string{}
+	for _, userBook := range userBooks {
+		for _, genre := range userBook.Book.Genres {
+			if counts[genre.Name] == 0 {
+				libraryGenres = append(libraryGenres, genre.Name)
+			}
+			counts[genre.Name]++
+		}
+	}
+	slices.SortStableFunc(libraryGenres, func(a, b string) int {
+		return cmp.Compare(counts[b], counts[a])
+	})
+	for _, name := range libraryGenres[:min(len(libraryGenres), maxLibraryRecommendationGenres)] {
+		addGenre(name)
 	}
-	return resultBooks[:MaxRecommendationsResults], nil
+	return names
 }
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	// CoverURLPrefix is where the stored covers are served from
+	CoverURLPrefix  = "/covers/"
+	DefaultCoverDir = "./covers"
+)
+
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// storeCover writes the image of a data uri to the cover dir and returns the link it is served under
+func (s *bookService) storeCover(bookID, dataURI string) (string, error) {
+	if s.coverDir == "" {
+		return "", nil
+	}
+	_, encoded, found := strings.Cut(dataURI, ";base64,")
+	if !found {
+		return "", models.ErrManualBookInvalidCover
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", models.ErrManualBookInvalidCover
+	}
+	if len(data) > models.ManualBookMaxCoverSize {
+		return "", models.ErrManualBookCoverTooLarge
+	}
+	extension, ok := coverExtensions[http.DetectContentType(data)]
+	if !ok {
+		return "", models.ErrManualBookInvalidCover
+	}
+
+	if err := os.MkdirAll(s.coverDir, 0o750); err != nil {
+		return "", err
+	}
+	name := filepath.Base(bookID) + extension
+	if err := os.WriteFile(filepath.Join(s.coverDir, name), data, 0o640); err != nil {
+		return "", err
+	}
+	return CoverURLPrefix + name, nil
+}
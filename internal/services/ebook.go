@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/FilipBudzynski/book_it/internal/epub"
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+type EbookFileRepository interface {
+	Save(file *models.EbookFile) error
+	GetByUserAndBook(userId, bookId string) (*models.EbookFile, error)
+}
+
+type ebookService struct {
+	repo         EbookFileRepository
+	bookService  handlers.BookService
+	userBookRepo UserBookRepository
+	storageDir   string
+}
+
+func NewEbookService(repo EbookFileRepository, bookService handlers.BookService, userBookRepo UserBookRepository) *ebookService {
+	return &ebookService{
+		repo:         repo,
+		bookService:  bookService,
+		userBookRepo: userBookRepo,
+	}
+}
+
+// WithStorageDir enables keeping the uploaded files, without it only the metadata is used
+func (s *ebookService) WithStorageDir(dir string) *ebookService {
+	s.storageDir = dir
+	return s
+}
+
+// Upload reads the epub metadata, finds the book in the catalogue or adds it as a manual book
+// and puts it on the user's shelf. The file is only written to disk when keep is set.
+func (s *ebookService) Upload(ctx context.Context, userID, fileName string, data []byte, keep bool) (*models.Book, error) {
+	if len(data) > models.EbookMaxFileSize {
+		return nil, models.ErrEbookFileTooLarge
+	}
+	if keep && s.storageDir == "" {
+		return nil, models.ErrEbookStorageMissing
+	}
+
+	metadata, err := epub.Parse(bytes.NewReader(data), int64(len(data)), models.ManualBookMaxCoverSize)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := s.findOrCreate(ctx, userID, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userBookRepo.GetByUserAndBook(userID, book.ID); err != nil {
+		if err := s.userBookRepo.Create(&models.UserBook{UserGoogleId: userID, BookID: book.ID}); err != nil {
+			return nil, err
+		}
+	}
+
+	if keep {
+		if err := s.store(userID, book.ID, fileName, data); err != nil {
+			return nil, err
+		}
+	}
+	return book, nil
+}
+
+// findOrCreate matches the epub by isbn, then by title and author among the saved books,
+// and adds it as a manual book when neither finds it
+func (s *ebookService) findOrCreate(ctx context.Context, userID string, metadata *epub.Metadata) (*models.Book, error) {
+	if metadata.ISBN != "" {
+		book, err := s.bookService.GetByISBN(ctx, metadata.ISBN.String())
+		if err == nil {
+			return book, nil
+		}
+		if !errors.Is(err, models.ErrBookISBNNotFound) {
+			return nil, err
+		}
+	}
+
+	key := models.WorkMatchKey(metadata.Title, metadata.Authors())
+	if saved, _, err := s.bookService.SearchLocal(metadata.Title, models.SearchFilters{}, 1); err == nil {
+		for _, book := range saved {
+			if models.WorkMatchKey(book.Title, book.Authors) == key {
+				return book, nil
+			}
+		}
+	}
+
+	genres := metadata.Subjects
+	if len(genres) > models.ManualBookMaxGenres {
+		genres = genres[:models.ManualBookMaxGenres]
+	}
+	return s.bookService.CreateManual(userID, &models.ManualBook{
+		Title:       metadata.Title,
+		Authors:     metadata.Authors(),
+		ISBN:        metadata.ISBN.String(),
+		Genres:      genres,
+		Cover:       metadata.CoverDataURI(),
+		Description: metadata.Description,
+		Language:    metadata.Language,
+	})
+}
+
+// store writes the file named after its content hash, uploading the same file again overwrites it
+func (s *ebookService) store(userID, bookID, fileName string, data []byte) error {
+	sum := sha256.Sum256(data)
+	dir := filepath.Join(s.storageDir, filepath.Base(userID))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])+".epub")
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return err
+	}
+
+	file, err := s.repo.GetByUserAndBook(userID, bookID)
+	if err != nil {
+		file = &models.EbookFile{UserGoogleId: userID, BookID: bookID}
+	} else if file.Path != path {
+		_ = os.Remove(file.Path)
+	}
+	file.FileName = filepath.Base(fileName)
+	file.Path = path
+	file.Size = int64(len(data))
+	return s.repo.Save(file)
+}
+
+// File returns the kept epub of the book
+func (s *ebookService) File(userID, bookID string) (*models.EbookFile, error) {
+	return s.repo.GetByUserAndBook(userID, bookID)
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/FilipBudzynski/book_it/utils"
 )
 
-func (s *exchangeService) CreateMatch(request, otherRequest *models.ExchangeRequest) (*models.ExchangeMatch, error) {
+func (s *exchangeService) CreateMatch(request, otherRequest *models.ExchangeRequest, rule models.MatchRule) (*models.ExchangeMatch, error) {
 	distance := geo.HaversineDistance(
 		geo.Cord{
 			Lat: request.Latitude,
@@ -25,6 +25,7 @@ func (s *exchangeService) CreateMatch(request, otherRequest *models.ExchangeRequ
 		Request1Decision:         models.MatchDecisionPending,
 		Request2Decision:         models.MatchDecisionPending,
 		Distance:                 distance,
+		MatchRule:                rule,
 	}
 	return match, s.repo.CreateMatch(match)
 }
@@ -16,13 +16,14 @@ type ExchangeRequestRepository interface {
 	Delete(id string) error
 	DeleteMatchesForRequest(requestId string) error
 	Update(exchange *models.ExchangeRequest) error
-	FindMatchingRequests(userId, requestId, desiredBookId string, offeredBooks []string) ([]*models.ExchangeRequest, error)
+	FindMatchCandidates(request *models.ExchangeRequest) ([]*models.ExchangeRequest, error)
 	CreateMatch(match *models.ExchangeMatch) error
 	GetMatch(requestId, otherRequestId string) (*models.ExchangeMatch, error)
 	UpdateMatch(match *models.ExchangeMatch) error
 	GetAllMatches(requestId string) ([]*models.ExchangeMatch, error)
 	GetMatchByID(id string) (*models.ExchangeMatch, error)
 	GetActiveExchangeRequestsByBookID(id string, userID string) ([]*models.ExchangeRequest, error)
+	GetActiveOffering(bookIDs []string, excludeUserID string) ([]*models.ExchangeRequest, error)
 }
 
 type exchangeService struct {
@@ -42,6 +43,7 @@ func (s *exchangeService) Create(
 	userBookIDs []string,
 	latitude float64,
 	longitude float64,
+	anyEdition bool,
 ) (*models.ExchangeRequest, error) {
 	offeredBooks := make([]models.OfferedBook, len(userBookIDs))
 	for i, id := range userBookIDs {
@@ -56,6 +58,7 @@ func (s *exchangeService) Create(
 		Status:        models.ExchangeRequestStatusActive,
 		Latitude:      latitude,
 		Longitude:     longitude,
+		AnyEdition:    anyEdition,
 	}
 
 	if err := exchange.Validate(); err != nil {
@@ -101,16 +104,20 @@ func (s *exchangeService) FindMatchingRequests(requestId, userId string) ([]*mod
 		return nil, err
 	}
 
-	matchingRequests, err := s.repo.FindMatchingRequests(
-		requestId,
-		userId,
-		r.DesiredBookID,
-		getOfferedBookIDs(r.OfferedBooks),
-	)
+	candidates, err := s.repo.FindMatchCandidates(r)
 	if err != nil {
 		return nil, err
 	}
 
+	matchingRequests := []*models.ExchangeRequest{}
+	matchRules := make(map[uint]models.MatchRule)
+	for _, candidate := range candidates {
+		if rule, ok := r.MatchWith(candidate); ok {
+			matchingRequests = append(matchingRequests, candidate)
+			matchRules[candidate.ID] = rule
+		}
+	}
+
 	if r.Status == models.ExchangeRequestStatusCompleted {
 		return matchingRequests, nil
 	}
@@ -120,7 +127,7 @@ func (s *exchangeService) FindMatchingRequests(requestId, userId string) ([]*mod
 	}
 
 	for _, matchingReq := range matchingRequests {
-		_, err := s.CreateMatch(r, matchingReq)
+		_, err := s.CreateMatch(r, matchingReq, matchRules[matchingReq.ID])
 		if err != nil {
 			return nil, err
 		}
@@ -129,14 +136,6 @@ func (s *exchangeService) FindMatchingRequests(requestId, userId string) ([]*mod
 	return matchingRequests, nil
 }
 
-func getOfferedBookIDs(offeredBooks []models.OfferedBook) []string {
-	ids := make([]string, len(offeredBooks))
-	for i, book := range offeredBooks {
-		ids[i] = book.BookID
-	}
-	return ids
-}
-
 func (s *exchangeService) GetLocalizationAutocomplete(query string) ([]geo.Result, error) {
 	return geo.GetLocalizationAutocomplete(query)
 }
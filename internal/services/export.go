@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/goodreads"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	ExportBatchSize   = 100
+	ExportFormatCSV   = "csv"
+	ExportFormatJSON  = "json"
+	exportJSONVersion = 2
+)
+
+type exportService struct {
+	userBookRepo UserBookRepository
+}
+
+func NewExportService(userBookRepo UserBookRepository) *exportService {
+	return &exportService{
+		userBookRepo: userBookRepo,
+	}
+}
+
+// flusher is implemented by http response writers, flushing after every batch streams the export
+type flusher interface {
+	Flush()
+}
+
+func flush(w io.Writer) {
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+}
+
+// WriteCSV writes the user's library as a Goodreads compatible csv
+func (s *exportService) WriteCSV(userID string, w io.Writer) error {
+	writer := goodreads.NewWriter(w)
+	err := s.userBookRepo.FindInBatches(userID, ExportBatchSize, func(userBooks []*models.UserBook) error {
+		for _, userBook := range userBooks {
+			if err := writer.Write(goodreadsRow(userBook)); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		flush(w)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func goodreadsRow(userBook *models.UserBook) goodreads.Row {
+	book := userBook.Book
+	authors := strings.Split(book.Authors, ", ")
+	added := userBook.CreatedAt
+	row := goodreads.Row{
+		Title:             book.Title,
+		Author:            authors[0],
+		AdditionalAuthors: strings.Join(authors[1:], ", "),
+		ISBN:              book.ISBN10,
+		ISBN13:            book.ISBN13,
+		Pages:             book.Pages,
+		DateAdded:         &added,
+		ExclusiveShelf:    goodreads.ShelfToRead,
+	}
+	if len(book.PublishedDate) >= 4 {
+		row.YearPublished = book.PublishedDate[:4]
+	}
+
+	switch userBook.Status {
+	case models.UserBookStatusReading:
+		row.ExclusiveShelf = goodreads.ShelfCurrentlyReading
+	case models.UserBookStatusRead:
+		row.ExclusiveShelf = goodreads.ShelfRead
+		row.DateRead = userBook.FinishedAt
+	case models.UserBookStatusDidNotFinish:
+		row.ExclusiveShelf = goodreads.ShelfDidNotFinish
+	case models.UserBookStatusOnHold:
+		row.ExclusiveShelf = goodreads.ShelfOnHold
+	}
+
+	if progress := userBook.ReadingProgress; progress != nil {
+		if progress.Completed {
+			endDate := progress.EndDate
+			row.ExclusiveShelf = goodreads.ShelfRead
+			row.DateRead = &endDate
+		}
+		if progress.TotalPages > 0 {
+			row.Pages = progress.TotalPages
+		}
+	}
+	return row
+}
+
+type exportedUserBook struct {
+	ID            uint                   `json:"id"`
+	AddedAt       time.Time              `json:"added_at"`
+	Status        models.UserBookStatus  `json:"status"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"`
+	FinishedAt    *time.Time             `json:"finished_at,omitempty"`
+	StatusChanges []exportedStatusChange `json:"status_changes"`
+	Book          exportedBook           `json:"book"`
+	Progress      *exportedProgress      `json:"progress"`
+}
+
+type exportedStatusChange struct {
+	From      models.UserBookStatus `json:"from,omitempty"`
+	To        models.UserBookStatus `json:"to"`
+	ChangedAt time.Time             `json:"changed_at"`
+}
+
+// exportedBook holds every field of the edition, the editions of the same work share a work_id
+type exportedBook struct {
+	ID             string             `json:"id"`
+	ISBN13         string             `json:"isbn13,omitempty"`
+	ISBN10         string             `json:"isbn10,omitempty"`
+	WorkID         *uint              `json:"work_id,omitempty"`
+	ProviderWorkID string             `json:"provider_work_id,omitempty"`
+	Title          string             `json:"title"`
+	Authors        string             `json:"authors"`
+	Description    string             `json:"description,omitempty"`
+	ImageLink      string             `json:"image_link,omitempty"`
+	Link           string             `json:"link,omitempty"`
+	PublishedDate  string             `json:"published_date,omitempty"`
+	Pages          int                `json:"pages,omitempty"`
+	Language       string             `json:"language,omitempty"`
+	Series         *models.SeriesInfo `json:"series,omitempty"`
+	// Genres are the paths of the genres, "Fiction / Science Fiction"
+	Genres       []string `json:"genres"`
+	CreatedBy    string   `json:"created_by,omitempty"`
+	LinkedBookID string   `json:"linked_book_id,omitempty"`
+}
+
+type exportedProgress struct {
+	StartDate        time.Time     `json:"start_date"`
+	EndDate          time.Time     `json:"end_date"`
+	TotalPages       int           `json:"total_pages"`
+	CurrentPage      int           `json:"current_page"`
+	DailyTargetPages int           `json:"daily_target_pages"`
+	Completed        bool          `json:"completed"`
+	Logs             []exportedLog `json:"logs"`
+}
+
+type exportedLog struct {
+	Date        time.Time `json:"date"`
+	PagesRead   int       `json:"pages_read"`
+	TotalPages  int       `json:"total_pages"`
+	TargetPages int       `json:"target_pages"`
+	Completed   bool      `json:"completed"`
+	Comment     string    `json:"comment,omitempty"`
+}
+
+// WriteJSON writes the whole library including every daily log. The books are encoded one at a time
+// so the library is never held in memory, the output is a single object with a "books" array.
+func (s *exportService) WriteJSON(userID string, w io.Writer) error {
+	exportedAt, err := json.Marshal(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, `{"version":%d,"exported_at":%s,"books":[`, exportJSONVersion, exportedAt); err != nil {
+		return err
+	}
+
+	first := true
+	err = s.userBookRepo.FindInBatches(userID, ExportBatchSize, func(userBooks []*models.UserBook) error {
+		for _, userBook := range userBooks {
+			data, err := json.Marshal(exportUserBook(userBook))
+			if err != nil {
+				return err
+			}
+			if !first {
+				data = append([]byte(","), data...)
+			}
+			first = false
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		flush(w)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func exportUserBook(userBook *models.UserBook) exportedUserBook {
+	book := userBook.Book
+	exported := exportedUserBook{
+		ID:            userBook.ID,
+		AddedAt:       userBook.CreatedAt,
+		Status:        userBook.Status,
+		StartedAt:     userBook.StartedAt,
+		FinishedAt:    userBook.FinishedAt,
+		StatusChanges: make([]exportedStatusChange, len(userBook.StatusChanges)),
+		Book: exportedBook{
+			ID:             book.ID,
+			ISBN13:         book.ISBN13,
+			ISBN10:         book.ISBN10,
+			WorkID:         book.WorkID,
+			ProviderWorkID: book.ProviderWorkID,
+			Title:          book.Title,
+			Authors:        book.Authors,
+			Description:    book.Description,
+			ImageLink:      book.ImageLink,
+			Link:           book.Link,
+			PublishedDate:  book.PublishedDate,
+			Pages:          book.Pages,
+			Language:       book.Language,
+			Series:         book.Series,
+			Genres:         make([]string, len(book.Genres)),
+			CreatedBy:      book.CreatedBy,
+			LinkedBookID:   book.LinkedBookID,
+		},
+	}
+	for i, change := range userBook.StatusChanges {
+		exported.StatusChanges[i] = exportedStatusChange{From: change.From, To: change.To, ChangedAt: change.ChangedAt}
+	}
+	for i, genre := range book.Genres {
+		exported.Book.Genres[i] = strings.Join(genre.Ancestors(), models.GenrePathSeparator)
+	}
+
+	if progress := userBook.ReadingProgress; progress != nil {
+		exported.Progress = &exportedProgress{
+			StartDate:        progress.StartDate,
+			EndDate:          progress.EndDate,
+			TotalPages:       progress.TotalPages,
+			CurrentPage:      progress.CurrentPage,
+			DailyTargetPages: progress.DailyTargetPages,
+			Completed:        progress.Completed,
+			Logs:             make([]exportedLog, len(progress.DailyProgress)),
+		}
+		for i, log := range progress.DailyProgress {
+			exported.Progress.Logs[i] = exportedLog{
+				Date:        log.Date,
+				PagesRead:   log.PagesRead,
+				TotalPages:  log.TotalPages,
+				TargetPages: log.TargetPages,
+				Completed:   log.Completed,
+				Comment:     log.Comment,
+			}
+		}
+	}
+	return exported
+}
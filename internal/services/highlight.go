@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+type HighlightRepository interface {
+	Create(highlight *models.Highlight) error
+	Get(id string) (*models.Highlight, error)
+	Update(highlight *models.Highlight) error
+	Delete(id string) error
+	GetByUserBook(userBookID string) ([]*models.Highlight, error)
+	Search(userID, query string) ([]*models.Highlight, error)
+	GetImported(userBookID uint) ([]*models.Highlight, error)
+}
+
+type highlightService struct {
+	repo         HighlightRepository
+	userBookRepo UserBookRepository
+	progressRepo ProgressRepository
+}
+
+func NewHighlightService(repo HighlightRepository, userBookRepo UserBookRepository, progressRepo ProgressRepository) *highlightService {
+	return &highlightService{
+		repo:         repo,
+		userBookRepo: userBookRepo,
+		progressRepo: progressRepo,
+	}
+}
+
+// Create keeps the highlight on the user's book, input carries the page, the log, the text, the note and the tags
+func (s *highlightService) Create(userID, userBookID string, input *models.Highlight) (*models.Highlight, error) {
+	userBook, err := s.ownedUserBook(userID, userBookID)
+	if err != nil {
+		return nil, err
+	}
+	highlight := &models.Highlight{UserBookID: userBook.ID}
+	if err := s.apply(highlight, input); err != nil {
+		return nil, err
+	}
+	return highlight, s.repo.Create(highlight)
+}
+
+func (s *highlightService) Update(userID, id string, input *models.Highlight) (*models.Highlight, error) {
+	highlight, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(highlight, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(highlight); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(id)
+}
+
+func (s *highlightService) Delete(userID, id string) error {
+	if _, err := s.Get(userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+func (s *highlightService) Get(userID, id string) (*models.Highlight, error) {
+	highlight, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if highlight.UserBook.UserGoogleId != userID {
+		return nil, models.ErrHighlightNotOwner
+	}
+	return highlight, nil
+}
+
+func (s *highlightService) GetByUserBook(userID, userBookID string) ([]*models.Highlight, error) {
+	if _, err := s.ownedUserBook(userID, userBookID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByUserBook(userBookID)
+}
+
+func (s *highlightService) Search(userID, query string) ([]*models.Highlight, error) {
+	return s.repo.Search(userID, query)
+}
+
+func (s *highlightService) ownedUserBook(userID, userBookID string) (*models.UserBook, error) {
+	userBook, err := s.userBookRepo.Get(userBookID)
+	if err != nil {
+		return nil, err
+	}
+	if userBook.UserGoogleId != userID {
+		return nil, models.ErrHighlightNotOwner
+	}
+	return userBook, nil
+}
+
+// apply copies the input onto the highlight, the log must come from the journal of the same book
+func (s *highlightService) apply(highlight, input *models.Highlight) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if input.DailyProgressLogID != nil {
+		log, err := s.progressRepo.GetLogById(fmt.Sprint(*input.DailyProgressLogID))
+		if err != nil || log.UserBookID != highlight.UserBookID {
+			return models.ErrHighlightLogNotOfBook
+		}
+	}
+
+	highlight.Page = input.Page
+	highlight.DailyProgressLogID = input.DailyProgressLogID
+	highlight.Text = strings.TrimSpace(input.Text)
+	highlight.Note = strings.TrimSpace(input.Note)
+	highlight.Tags = strings.Join(models.ParseTags(input.Tags), ", ")
+	return nil
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/goodreads"
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+const (
+	importCandidatesLimit = 10
+)
+
+type ImportRepository interface {
+	Create(job *models.ImportJob) error
+	Update(job *models.ImportJob) error
+	SaveRow(row *models.ImportRow) error
+	Get(id, userId string) (*models.ImportJob, error)
+	GetAll(userId string) ([]*models.ImportJob, error)
+	FindByHash(userId, hash string) (*models.ImportJob, error)
+	FailUnfinished(reason string) error
+}
+
+type importService struct {
+	repo          ImportRepository
+	bookService   handlers.BookService
+	bookRepo      BookRepository
+	userBookRepo  UserBookRepository
+	progressRepo  ProgressRepository
+	highlightRepo HighlightRepository
+}
+
+func NewImportService(
+	repo ImportRepository,
+	bookService handlers.BookService,
+	bookRepo BookRepository,
+	userBookRepo UserBookRepository,
+	progressRepo ProgressRepository,
+) *importService {
+	return &importService{
+		repo:         repo,
+		bookService:  bookService,
+		bookRepo:     bookRepo,
+		userBookRepo: userBookRepo,
+		progressRepo: progressRepo,
+	}
+}
+
+// StartGoodreads parses the Goodreads export and resolves its rows in the background.
+// Uploading a file that was already imported returns the earlier job with ErrImportAlreadyImported.
+// The job outlives the upload request, only the values of ctx are passed on, not its cancellation.
+func (s *importService) StartGoodreads(ctx context.Context, userID, fileName string, data []byte) (*models.ImportJob, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if job, err := s.repo.FindByHash(userID, hash); err == nil && job.Status == models.ImportJobStatusCompleted {
+		return job, models.ErrImportAlreadyImported
+	}
+
+	rows, err := goodreads.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, models.ErrImportEmptyFile
+	}
+
+	job := &models.ImportJob{
+		UserGoogleId: userID,
+		Source:       models.ImportSourceGoodreads,
+		FileName:     fileName,
+		FileHash:     hash,
+		Status:       models.ImportJobStatusPending,
+		Total:        len(rows),
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	importRows := make([]func(ctx context.Context) *models.ImportRow, len(rows))
+	for i, row := range rows {
+		importRows[i] = func(ctx context.Context) *models.ImportRow {
+			return s.importRow(ctx, userID, row)
+		}
+	}
+	go s.run(context.WithoutCancel(ctx), job, importRows)
+
+	return job, nil
+}
+
+// FailInterrupted fails the jobs a previous run of the server left unfinished, so they can be uploaded again
+func (s *importService) FailInterrupted() error {
+	return s.repo.FailUnfinished(models.ErrImportInterrupted.Error())
+}
+
+func (s *importService) Get(id, userID string) (*models.ImportJob, error) {
+	return s.repo.Get(id, userID)
+}
+
+func (s *importService) GetAll(userID string) ([]*models.ImportJob, error) {
+	return s.repo.GetAll(userID)
+}
+
+// run saves the report of every row as it is imported, so the progress can be followed
+func (s *importService) run(ctx context.Context, job *models.ImportJob, importRows []func(ctx context.Context) *models.ImportRow) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.fail(job, fmt.Errorf("%w: %v", models.ErrImportPanicked, r))
+		}
+	}()
+
+	job.Status = models.ImportJobStatusRunning
+	if err := s.repo.Update(job); err != nil {
+		log.Printf("import %d: %v", job.ID, err)
+	}
+
+	for _, importRow := range importRows {
+		report := importRow(ctx)
+		report.ImportJobID = job.ID
+
+		switch report.Status {
+		case models.ImportRowStatusMatched:
+			job.Matched++
+		case models.ImportRowStatusAmbiguous:
+			job.Ambiguous++
+		default:
+			job.Failed++
+		}
+
+		if err := s.repo.SaveRow(report); err != nil {
+			s.fail(job, err)
+			return
+		}
+		if err := s.repo.Update(job); err != nil {
+			s.fail(job, err)
+			return
+		}
+	}
+
+	job.Status = models.ImportJobStatusCompleted
+	if err := s.repo.Update(job); err != nil {
+		log.Printf("import %d: %v", job.ID, err)
+	}
+}
+
+func (s *importService) fail(job *models.ImportJob, err error) {
+	log.Printf("import %d failed: %v", job.ID, err)
+	job.Status = models.ImportJobStatusFailed
+	job.Error = err.Error()
+	if err := s.repo.Update(job); err != nil {
+		log.Printf("import %d: %v", job.ID, err)
+	}
+}
+
+func (s *importService) importRow(ctx context.Context, userID string, row goodreads.Row) *models.ImportRow {
+	report := &models.ImportRow{
+		Line:   row.Line,
+		Title:  row.Title,
+		Author: row.Author,
+		ISBN:   row.ISBN13,
+	}
+	if report.ISBN == "" {
+		report.ISBN = row.ISBN
+	}
+
+	book, candidates, err := s.resolve(ctx, row)
+	if !resolved(report, book, candidates, err) {
+		return report
+	}
+
+	report.BookID = book.ID
+	message, err := s.shelve(userID, book, row)
+	if err != nil {
+		report.Status = models.ImportRowStatusFailed
+		report.Message = err.Error()
+		return report
+	}
+	report.Status = models.ImportRowStatusMatched
+	report.Message = message
+	return report
+}
+
+// resolved fills the report of a book that was not found, an ambiguous match lists the candidates
+func resolved(report *models.ImportRow, book *models.Book, candidates []*models.Book, err error) bool {
+	switch {
+	case err != nil:
+		report.Status = models.ImportRowStatusFailed
+		report.Message = err.Error()
+	case book == nil && len(candidates) > 0:
+		ids := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			ids[i] = candidate.ID
+		}
+		report.Status = models.ImportRowStatusAmbiguous
+		report.Message = "several books match the title: " + strings.Join(ids, ", ")
+	case book == nil:
+		report.Status = models.ImportRowStatusFailed
+		report.Message = "no matching book found"
+	default:
+		return true
+	}
+	return false
+}
+
+// resolve looks the row up by its isbns first and falls back to a title search
+func (s *importService) resolve(ctx context.Context, row goodreads.Row) (*models.Book, []*models.Book, error) {
+	for _, value := range []string{row.ISBN13, row.ISBN} {
+		if value == "" {
+			continue
+		}
+		if book, err := s.bookService.GetByISBN(ctx, value); err == nil {
+			return book, nil, nil
+		}
+	}
+	return s.search(ctx, row.SearchTitle(), row.Title, row.Author)
+}
+
+// search looks the title up with the provider, a result is only accepted when both the title
+// and the author match
+func (s *importService) search(ctx context.Context, query, title, author string) (*models.Book, []*models.Book, error) {
+	provider := s.bookService.Provider()
+	if provider == nil {
+		return nil, nil, nil
+	}
+	results, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery(query, handlers.QueryTypeTitle), models.SearchFilters{}, importCandidatesLimit, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	key := models.WorkMatchKey(title, author)
+	titleKey, _, _ := strings.Cut(key, "|")
+	candidates := []*models.Book{}
+	for _, result := range results {
+		resultKey := models.WorkMatchKey(result.Title, result.Authors)
+		if resultKey == key {
+			book, err := s.save(result)
+			return book, nil, err
+		}
+		if resultTitle, _, _ := strings.Cut(resultKey, "|"); resultTitle == titleKey {
+			candidates = append(candidates, result)
+		}
+	}
+	return nil, candidates, nil
+}
+
+func (s *importService) save(book *models.Book) (*models.Book, error) {
+	if dbBook, err := s.bookRepo.Get(book.ID); err == nil && dbBook != nil {
+		return dbBook, nil
+	}
+	if err := s.bookRepo.Create(book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// shelve adds the book to the user's shelf, books on the read shelf get a completed reading progress
+func (s *importService) shelve(userID string, book *models.Book, row goodreads.Row) (string, error) {
+	status, at := shelfStatus(row)
+	userBook, message, err := s.addToShelf(userID, book, status, at)
+	if err != nil {
+		return "", err
+	}
+
+	if row.ExclusiveShelf != goodreads.ShelfRead || row.DateRead == nil || userBook.ReadingProgress != nil {
+		return message, nil
+	}
+
+	totalPages := row.Pages
+	if totalPages <= 0 {
+		totalPages = book.Pages
+	}
+	if totalPages <= 0 {
+		return message + ", read date skipped because the page count is unknown", nil
+	}
+
+	startDate := *row.DateRead
+	if row.DateAdded != nil && row.DateAdded.Before(startDate) {
+		startDate = *row.DateAdded
+	}
+	progress := models.ReadingProgress{
+		UserBookID:  userBook.ID,
+		BookTitle:   book.Title,
+		StartDate:   startDate,
+		EndDate:     *row.DateRead,
+		TotalPages:  totalPages,
+		CurrentPage: totalPages,
+		Completed:   true,
+	}
+	if err := progress.Validate(); err != nil {
+		return "", err
+	}
+	if err := s.progressRepo.Create(progress); err != nil {
+		return "", err
+	}
+	return message + ", read on " + row.DateRead.Format(time.DateOnly), nil
+}
+
+// shelfStatus maps the Goodreads exclusive shelf to a reading status, custom shelves go to want to read
+func shelfStatus(row goodreads.Row) (models.UserBookStatus, time.Time) {
+	at := time.Now()
+	if row.DateAdded != nil {
+		at = *row.DateAdded
+	}
+	switch row.ExclusiveShelf {
+	case goodreads.ShelfRead:
+		if row.DateRead != nil {
+			at = *row.DateRead
+		}
+		return models.UserBookStatusRead, at
+	case goodreads.ShelfCurrentlyReading:
+		return models.UserBookStatusReading, at
+	case goodreads.ShelfDidNotFinish:
+		return models.UserBookStatusDidNotFinish, at
+	case goodreads.ShelfOnHold:
+		return models.UserBookStatusOnHold, at
+	}
+	return models.UserBookStatusWantToRead, at
+}
+
+// addToShelf adds the book to the user's books on the status shelf, an empty status lets the book pick its shelf
+func (s *importService) addToShelf(userID string, book *models.Book, status models.UserBookStatus, at time.Time) (*models.UserBook, string, error) {
+	if userBook, err := s.userBookRepo.GetByUserAndBook(userID, book.ID); err == nil {
+		return userBook, "already in your books", nil
+	}
+	userBook := &models.UserBook{
+		UserGoogleId: userID,
+		BookID:       book.ID,
+	}
+	if status != "" {
+		userBook.SetStatus(status, at)
+	}
+	if err := s.userBookRepo.Create(userBook); err != nil {
+		return nil, "", err
+	}
+	return userBook, "added to your books", nil
+}
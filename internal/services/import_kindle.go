@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/kindle"
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+// kindleHighlightTag marks the highlights of a Kindle import, notes without a highlight also get kindleNoteTag
+const (
+	kindleHighlightTag = "kindle"
+	kindleNoteTag      = "note"
+)
+
+// kindleBook is a book of the clippings file with its clippings in file order
+type kindleBook struct {
+	Title     string
+	Author    string
+	Clippings []kindle.Clipping
+}
+
+// WithHighlights enables the Kindle clippings import, the clippings are saved as highlights
+func (s *importService) WithHighlights(highlightRepo HighlightRepository) *importService {
+	s.highlightRepo = highlightRepo
+	return s
+}
+
+// StartKindle saves the clippings of a Kindle "My Clippings.txt" as highlights of the books of the user's
+// library in the background, a book is matched by its normalized title and author. With createMissing
+// the books that are not in the library are looked up like the rows of a Goodreads import and shelved.
+// The clippings file keeps growing on the Kindle, so it can be imported again, the clippings imported
+// before are skipped and the ones extended or annotated since are updated.
+func (s *importService) StartKindle(ctx context.Context, userID, fileName string, data []byte, createMissing bool) (*models.ImportJob, error) {
+	clippings, err := kindle.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	books := groupClippings(kindle.Attach(clippings))
+	if len(books) == 0 {
+		return nil, models.ErrImportEmptyFile
+	}
+	userBooks, err := s.userBookRepo.GetAllUserBooks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	job := &models.ImportJob{
+		UserGoogleId: userID,
+		Source:       models.ImportSourceKindle,
+		FileName:     fileName,
+		FileHash:     hex.EncodeToString(sum[:]),
+		Status:       models.ImportJobStatusPending,
+		Total:        len(books),
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	importRows := make([]func(ctx context.Context) *models.ImportRow, len(books))
+	for i, book := range books {
+		importRows[i] = func(ctx context.Context) *models.ImportRow {
+			return s.importClippings(ctx, userID, userBooks, book, createMissing)
+		}
+	}
+	go s.run(context.WithoutCancel(ctx), job, importRows)
+
+	return job, nil
+}
+
+func groupClippings(clippings []kindle.Clipping) []*kindleBook {
+	books := []*kindleBook{}
+	byKey := map[string]*kindleBook{}
+	for _, clipping := range clippings {
+		key := models.WorkMatchKey(clipping.Title, clipping.FirstAuthor())
+		book, ok := byKey[key]
+		if !ok {
+			book = &kindleBook{Title: clipping.Title, Author: clipping.Author}
+			byKey[key] = book
+			books = append(books, book)
+		}
+		book.Clippings = append(book.Clippings, clipping)
+	}
+	return books
+}
+
+// MatchUserBook finds the book of the library with the same normalized title and author,
+// a title without an author matches on the title alone
+func MatchUserBook(userBooks []*models.UserBook, title, author string) *models.UserBook {
+	key := models.WorkMatchKey(title, author)
+	titleKey, authorKey, _ := strings.Cut(key, "|")
+	if titleKey == "" {
+		return nil
+	}
+	for _, userBook := range userBooks {
+		bookKey := models.WorkMatchKey(userBook.Book.Title, userBook.Book.Authors)
+		bookTitle, _, _ := strings.Cut(bookKey, "|")
+		if bookKey == key || (authorKey == "" && bookTitle == titleKey) {
+			return userBook
+		}
+	}
+	return nil
+}
+
+func (s *importService) importClippings(ctx context.Context, userID string, userBooks []*models.UserBook, book *kindleBook, createMissing bool) *models.ImportRow {
+	report := &models.ImportRow{
+		Line:   book.Clippings[0].Entry,
+		Title:  book.Title,
+		Author: book.Author,
+	}
+	author := book.Clippings[0].FirstAuthor()
+
+	userBook := MatchUserBook(userBooks, book.Title, author)
+	if userBook == nil && !createMissing {
+		report.Status = models.ImportRowStatusFailed
+		report.Message = "not in your books, add it or import again with the missing books added"
+		return report
+	}
+	if userBook == nil {
+		query := book.Title
+		if i := strings.IndexAny(query, ":(["); i > 0 {
+			query = strings.TrimSpace(query[:i])
+		}
+		found, candidates, err := s.search(ctx, query, book.Title, author)
+		if !resolved(report, found, candidates, err) {
+			return report
+		}
+		if userBook, _, err = s.addToShelf(userID, found, "", time.Now()); err != nil {
+			report.Status = models.ImportRowStatusFailed
+			report.Message = err.Error()
+			return report
+		}
+	}
+	report.BookID = userBook.BookID
+
+	imported, updated, skipped, err := s.saveClippings(userBook.ID, book.Clippings)
+	if err != nil {
+		report.Status = models.ImportRowStatusFailed
+		report.Message = err.Error()
+		return report
+	}
+	report.Status = models.ImportRowStatusMatched
+	report.Message = highlightCount(imported) + " imported"
+	if updated > 0 {
+		report.Message += fmt.Sprintf(", %d updated", updated)
+	}
+	if skipped > 0 {
+		report.Message += fmt.Sprintf(", %d imported before", skipped)
+	}
+	return report
+}
+
+func highlightCount(n int) string {
+	if n == 1 {
+		return "1 highlight"
+	}
+	return fmt.Sprintf("%d highlights", n)
+}
+
+// saveClippings saves the clippings the book does not have yet and updates the imported highlights
+// whose clipping was extended or got a new note since
+func (s *importService) saveClippings(userBookID uint, clippings []kindle.Clipping) (int, int, int, error) {
+	saved, err := s.highlightRepo.GetImported(userBookID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	existing := map[string]*models.Highlight{}
+	for _, highlight := range saved {
+		existing[highlight.SourceKey] = highlight
+	}
+
+	imported, updated, skipped := 0, 0, 0
+	for _, clipping := range clippings {
+		key := clipping.Key()
+		text, note := strings.TrimSpace(clipping.Text), strings.TrimSpace(clipping.Note)
+
+		if highlight, ok := existing[key]; ok {
+			if highlight.Text == text && highlight.Note == note {
+				skipped++
+				continue
+			}
+			highlight.Text, highlight.Note = text, note
+			if err := highlight.Validate(); err != nil {
+				continue
+			}
+			if err := s.highlightRepo.Update(highlight); err != nil {
+				return imported, updated, skipped, err
+			}
+			updated++
+			continue
+		}
+
+		highlight := &models.Highlight{
+			UserBookID: userBookID,
+			Text:       text,
+			Note:       note,
+			Tags:       kindleHighlightTag,
+			SourceKey:  key,
+		}
+		if clipping.Kind == kindle.KindNote {
+			highlight.Tags += ", " + kindleNoteTag
+		}
+		if clipping.Page > 0 {
+			page := clipping.Page
+			highlight.Page = &page
+		}
+		if clipping.AddedAt != nil {
+			highlight.CreatedAt = *clipping.AddedAt
+		}
+		if err := highlight.Validate(); err != nil {
+			continue
+		}
+		if err := s.highlightRepo.Create(highlight); err != nil {
+			return imported, updated, skipped, err
+		}
+		existing[key] = highlight
+		imported++
+	}
+	return imported, updated, skipped, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+type RecommendationFeedbackRepository interface {
+	Save(feedback *models.RecommendationFeedback) error
+	GetAll(userID string) ([]*models.RecommendationFeedback, error)
+}
+
+type recommendationFeedbackService struct {
+	repo RecommendationFeedbackRepository
+}
+
+func NewRecommendationFeedbackService(repo RecommendationFeedbackRepository) *recommendationFeedbackService {
+	return &recommendationFeedbackService{
+		repo: repo,
+	}
+}
+
+func (s *recommendationFeedbackService) Save(userID, bookID string, kind models.FeedbackKind) error {
+	if _, err := models.ParseFeedbackKind(string(kind)); err != nil {
+		return err
+	}
+	return s.repo.Save(&models.RecommendationFeedback{
+		UserGoogleId: userID,
+		BookID:       bookID,
+		Kind:         kind,
+	})
+}
+
+func (s *recommendationFeedbackService) GetAll(userID string) ([]*models.RecommendationFeedback, error) {
+	return s.repo.GetAll(userID)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+type ReviewRepository interface {
+	Create(review *models.Review) error
+	Get(id string) (*models.Review, error)
+	Update(review *models.Review) error
+	Delete(id string) error
+	GetForBook(book *models.Book) ([]*models.Review, error)
+}
+
+type reviewService struct {
+	repo ReviewRepository
+}
+
+func NewReviewService(repo ReviewRepository) *reviewService {
+	return &reviewService{
+		repo: repo,
+	}
+}
+
+// Create reviews the book, a review of another edition of the book counts as the user's review
+func (s *reviewService) Create(userID string, book *models.Book, rating float64, text string, spoiler bool) (*models.Review, error) {
+	if err := models.ValidateRating(rating); err != nil {
+		return nil, err
+	}
+	own, err := s.GetOwn(userID, book)
+	if err != nil {
+		return nil, err
+	}
+	if own != nil {
+		return nil, models.ErrReviewExists
+	}
+
+	review := &models.Review{
+		UserGoogleId: userID,
+		BookID:       book.ID,
+		Rating:       rating,
+		Text:         strings.TrimSpace(text),
+		Spoiler:      spoiler,
+	}
+	return review, s.repo.Create(review)
+}
+
+func (s *reviewService) Update(userID, id string, rating float64, text string, spoiler bool) (*models.Review, error) {
+	if err := models.ValidateRating(rating); err != nil {
+		return nil, err
+	}
+	review, err := s.getOwned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rating = rating
+	review.Text = strings.TrimSpace(text)
+	review.Spoiler = spoiler
+	review.UpdatedAt = time.Now()
+	return review, s.repo.Update(review)
+}
+
+func (s *reviewService) Delete(userID, id string) (*models.Review, error) {
+	review, err := s.getOwned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return review, s.repo.Delete(id)
+}
+
+// GetForBook returns the reviews of the book and of its other editions with their stats
+func (s *reviewService) GetForBook(book *models.Book) ([]*models.Review, *models.ReviewStats, error) {
+	reviews, err := s.repo.GetForBook(book)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reviews, models.NewReviewStats(reviews), nil
+}
+
+// GetOwn returns the user's review of the book or of another edition, nil when they have not reviewed it
+func (s *reviewService) GetOwn(userID string, book *models.Book) (*models.Review, error) {
+	reviews, err := s.repo.GetForBook(book)
+	if err != nil {
+		return nil, err
+	}
+	for _, review := range reviews {
+		if review.UserGoogleId == userID {
+			return review, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *reviewService) getOwned(userID, id string) (*models.Review, error) {
+	review, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserGoogleId != userID {
+		return nil, models.ErrReviewNotOwner
+	}
+	return review, nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+)
+
+type SeriesRepository interface {
+	GetForBooks(bookIDs []string) ([]*models.Series, error)
+	AddBook(name, bookID string, position float64, userID string) (*models.Series, error)
+	RemoveBook(seriesID, bookID, userID string) error
+}
+
+type seriesService struct {
+	repo SeriesRepository
+}
+
+func NewSeriesService(repo SeriesRepository) *seriesService {
+	return &seriesService{
+		repo: repo,
+	}
+}
+
+// AddBook puts the book in the series for a book the provider did not place in one
+func (s *seriesService) AddBook(userID, bookID, name string, position float64) (*models.Series, error) {
+	name = strings.TrimSpace(name)
+	if models.SeriesKey(name) == "" {
+		return nil, models.ErrSeriesNameEmpty
+	}
+	if position <= 0 {
+		return nil, models.ErrSeriesInvalidPosition
+	}
+	return s.repo.AddBook(name, bookID, position, userID)
+}
+
+func (s *seriesService) RemoveBook(userID, seriesID, bookID string) error {
+	return s.repo.RemoveBook(seriesID, bookID, userID)
+}
+
+// GetForBooks returns the series of the books with their volumes in order
+func (s *seriesService) GetForBooks(bookIDs []string) ([]*models.Series, error) {
+	series, err := s.repo.GetForBooks(bookIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range series {
+		item.SortEntries()
+	}
+	return series, nil
+}
+
+// NextVolumes returns the volume to read next in every series of the library the user has started
+func (s *seriesService) NextVolumes(userBooks []*models.UserBook) ([]*models.NextVolume, error) {
+	bookIDs := make([]string, len(userBooks))
+	for i, userBook := range userBooks {
+		bookIDs[i] = userBook.BookID
+	}
+	series, err := s.repo.GetForBooks(bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	next := []*models.NextVolume{}
+	for _, item := range series {
+		if volume := item.NextVolume(userBooks); volume != nil {
+			next = append(next, volume)
+		}
+	}
+	return next, nil
+}
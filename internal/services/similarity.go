@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
+)
+
+const (
+	DefaultSimilarityInterval = time.Hour
+	// DefaultCollaborativeWeight is the share of the collaborative filter in the recommendation score
+	DefaultCollaborativeWeight = 0.3
+)
+
+type BookSimilarityRepository interface {
+	ShelfEntries() ([]models.ShelfEntry, error)
+	Replace(similarities []models.BookSimilarity) error
+	GetForBooks(bookIDs []string) ([]models.BookSimilarity, error)
+}
+
+// similarityService rebuilds the collaborative filter table from the shelves of all users
+type similarityService struct {
+	repo     BookSimilarityRepository
+	interval time.Duration
+}
+
+func NewSimilarityService(repo BookSimilarityRepository) *similarityService {
+	return &similarityService{
+		repo:     repo,
+		interval: DefaultSimilarityInterval,
+	}
+}
+
+func (s *similarityService) WithInterval(interval time.Duration) *similarityService {
+	s.interval = interval
+	return s
+}
+
+// Rebuild computes the similarities from scratch and replaces the stored ones
+func (s *similarityService) Rebuild() error {
+	entries, err := s.repo.ShelfEntries()
+	if err != nil {
+		return err
+	}
+	return s.repo.Replace(recommend.Similarities(entries))
+}
+
+// Start rebuilds the table right away and then every interval until the context is cancelled
+func (s *similarityService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			start := time.Now()
+			if err := s.Rebuild(); err != nil {
+				log.Printf("failed to rebuild book similarities: %v", err)
+			} else {
+				log.Printf("rebuilt book similarities in %s", time.Since(start))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
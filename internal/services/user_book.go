@@ -8,9 +8,12 @@ type UserBookRepository interface {
 	Create(userBook *models.UserBook) error
 	GetAllUserBooks(userId string) ([]*models.UserBook, error)
 	Get(id string) (*models.UserBook, error)
+	GetByUserAndBook(userId, bookId string) (*models.UserBook, error)
 	Delete(id string) error
 	DeleteWhereBookId(bookId string) error
-	Search(userId, search string) ([]*models.UserBook, error)
+	Search(userId, search string, status models.UserBookStatus) ([]*models.UserBook, error)
+	SetStatus(id uint, status models.UserBookStatus) (*models.UserBook, error)
+	FindInBatches(userId string, batchSize int, fn func(userBooks []*models.UserBook) error) error
 }
 
 type userBookService struct {
@@ -62,6 +65,26 @@ func (s *userBookService) DeleteByBookId(bookId string) error {
 	return s.repo.DeleteWhereBookId(bookId)
 }
 
-func (s *userBookService) Search(userId, query string) ([]*models.UserBook, error) {
-	return s.repo.Search(userId, query)
+func (s *userBookService) Search(userId, query string, status models.UserBookStatus) ([]*models.UserBook, error) {
+	return s.repo.Search(userId, query, status)
+}
+
+// SetStatus moves the user's book to another shelf
+func (s *userBookService) SetStatus(userID, id string, status models.UserBookStatus) (*models.UserBook, error) {
+	if status == "" {
+		return nil, models.ErrUserBookUnknownStatus
+	}
+	userBook, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if userBook.UserGoogleId != userID {
+		return nil, models.ErrUserBookNotOwner
+	}
+	moved, err := s.repo.SetStatus(userBook.ID, status)
+	if err != nil {
+		return nil, err
+	}
+	moved.Book = userBook.Book
+	return moved, nil
 }
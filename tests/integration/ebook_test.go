@@ -0,0 +1,57 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/epub"
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingEbookService struct {
+	err error
+}
+
+func (s failingEbookService) Upload(ctx context.Context, userID, fileName string, data []byte, keep bool) (*models.Book, error) {
+	return nil, s.err
+}
+
+func (s failingEbookService) File(userID, bookID string) (*models.EbookFile, error) {
+	return nil, s.err
+}
+
+func TestEbookHandlerErrors(t *testing.T) {
+	setupSession(t)
+
+	upload := func(t *testing.T, err error) int {
+		e := echo.New()
+		handlers.NewEbookHandler(failingEbookService{err: err}).RegisterRoutes(e)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "book.epub")
+		_, _ = part.Write([]byte("not a zip"))
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/ebooks", body)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		setSession(t, req, utils.UserSession{UserID: "reader", UserEmail: "reader@example.com"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, upload(t, epub.ErrNotEPUB), "a file that is not an epub")
+	assert.Equal(t, http.StatusBadRequest, upload(t, models.ErrManualBookAuthorsRequired), "an epub without authors")
+	assert.Equal(t, http.StatusInternalServerError, upload(t, errors.New("disk full")))
+}
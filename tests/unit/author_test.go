@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorKey(t *testing.T) {
+	assert.Equal(t, models.AuthorKey("J.R.R. Tolkien"), models.AuthorKey("Tolkien, J. R. R."))
+	assert.Equal(t, models.AuthorKey("Stanisław Lem"), models.AuthorKey("stanislaw  LEM"))
+	assert.NotEqual(t, models.AuthorKey("Frank Herbert"), models.AuthorKey("Brian Herbert"))
+	assert.Empty(t, models.AuthorKey(" . "))
+	assert.Equal(t, []string{"Terry Pratchett", "Neil Gaiman"}, models.SplitAuthors(" Terry  Pratchett, Neil Gaiman,"))
+	assert.Equal(t, []string{"Homer", "Virgil"}, models.SplitAuthors("Homer, Virgil"), "mononymous co-authors stay apart")
+	assert.Equal(t, []string{"Tolkien", "Christopher Tolkien"}, models.SplitAuthors("Tolkien, Christopher Tolkien"))
+	assert.Len(t, models.SplitAuthors("Terry Pratchett, Neil Gaiman, Terry Pratchett"), 3)
+}
+
+func TestUserBookFor(t *testing.T) {
+	workID := uint(4)
+	read := &models.UserBook{BookID: "dune-1965", Book: models.Book{ID: "dune-1965", WorkID: &workID}, ReadingProgress: &models.ReadingProgress{Completed: true}}
+	shelved := &models.UserBook{BookID: "emma", Book: models.Book{ID: "emma"}}
+	userBooks := []*models.UserBook{shelved, read}
+
+	assert.Same(t, read, models.UserBookFor(&models.Book{ID: "dune-2005", WorkID: &workID}, userBooks), "another edition counts")
+	assert.True(t, models.UserBookFor(&models.Book{ID: "dune-2005", WorkID: &workID}, userBooks).IsRead())
+	assert.False(t, models.UserBookFor(&models.Book{ID: "emma"}, userBooks).IsRead())
+	assert.Nil(t, models.UserBookFor(&models.Book{ID: "solaris"}, userBooks))
+	assert.False(t, models.UserBookFor(&models.Book{ID: "solaris"}, userBooks).IsRead())
+}
+
+func TestAuthorRepository(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// a book saved before authors existed
+	require.NoError(t, db.Create(&models.Book{ID: "old", Title: "Solaris", Authors: "Stanisław Lem"}).Error)
+
+	require.NoError(t, repositories.Migrate(db))
+	bookRepo := repositories.NewBookRepository(db)
+	repo := repositories.NewAuthorRepository(db)
+
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "a", Title: "The Invincible", Authors: "Stanislaw Lem"}))
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "b", Title: "Good Omens", Authors: "Terry Pratchett, Neil Gaiman, Terry Pratchett"}))
+
+	t.Run("Spellings of the same name share an author", func(t *testing.T) {
+		author, err := repo.GetByName("LEM, Stanisław")
+		require.NoError(t, err)
+		assert.Equal(t, "Stanisław Lem", author.Name, "the first spelling saved is kept")
+
+		author, err = repo.Get(fmt.Sprint(author.ID))
+		require.NoError(t, err)
+		titles := []string{}
+		for _, book := range author.Books {
+			titles = append(titles, book.Title)
+		}
+		assert.ElementsMatch(t, []string{"Solaris", "The Invincible"}, titles)
+	})
+
+	t.Run("Every author of a book is linked once", func(t *testing.T) {
+		var count int64
+		db.Table("book_authors").Where("book_id = ?", "b").Count(&count)
+		assert.Equal(t, int64(2), count)
+
+		_, err := repo.GetByName("Neil Gaiman")
+		assert.NoError(t, err)
+		_, err = repo.GetByName("Brian Herbert")
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthorServiceNearbyOffers(t *testing.T) {
+	exchangeRepo := new(MockExchangeRequestRepository)
+	svc := services.NewAuthorService(nil, exchangeRepo)
+	author := &models.Author{Name: "Stanisław Lem", Books: []models.Book{{ID: "solaris", Title: "Solaris"}, {ID: "invincible", Title: "The Invincible"}}}
+
+	// Warsaw, Łódź is about 120 km away
+	near := &models.ExchangeRequest{Latitude: 52.23, Longitude: 21.02, OfferedBooks: []models.OfferedBook{
+		{BookID: "invincible", Book: models.Book{ID: "invincible", Title: "The Invincible"}},
+		{BookID: "emma", Book: models.Book{ID: "emma", Title: "Emma"}},
+	}}
+	far := &models.ExchangeRequest{Latitude: 51.76, Longitude: 19.46, OfferedBooks: []models.OfferedBook{
+		{BookID: "solaris", Book: models.Book{ID: "solaris", Title: "Solaris"}},
+	}}
+	exchangeRepo.On("GetActiveOffering", []string{"solaris", "invincible"}, "anna").Return([]*models.ExchangeRequest{far, near}, nil)
+
+	t.Run("Offers too far away are left out", func(t *testing.T) {
+		user := &models.User{GoogleId: "anna", Location: &models.Location{Latitude: 52.25, Longitude: 21.0}}
+
+		offers, err := svc.NearbyOffers(author, user)
+		require.NoError(t, err)
+		require.Len(t, offers, 1)
+		assert.Equal(t, "The Invincible", offers[0].Book.Title)
+		assert.True(t, offers[0].DistanceKnown)
+		assert.Less(t, offers[0].Distance, 5.0)
+	})
+
+	t.Run("Without a location every offer is shown", func(t *testing.T) {
+		offers, err := svc.NearbyOffers(author, &models.User{GoogleId: "anna"})
+		require.NoError(t, err)
+		require.Len(t, offers, 2)
+		assert.Equal(t, "Solaris", offers[0].Book.Title)
+		assert.False(t, offers[0].DistanceKnown)
+	})
+
+	exchangeRepo.AssertCalled(t, "GetActiveOffering", mock.Anything, "anna")
+}
@@ -1,6 +1,8 @@
 package unit
 
 import (
+	"context"
+
 	"github.com/FilipBudzynski/book_it/internal/handlers"
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -10,17 +12,17 @@ type MockBookProvider struct {
 	mock.Mock
 }
 
-func (m *MockBookProvider) GetBook(id string) (*models.Book, error) {
+func (m *MockBookProvider) GetBook(ctx context.Context, id string) (*models.Book, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Book), args.Error(1)
 }
 
-func (m *MockBookProvider) GetBooksByQuery(query string, queryType handlers.QueryType, limit, page int) ([]*models.Book, error) {
-	args := m.Called(query, queryType, limit, page)
+func (m *MockBookProvider) GetBooksByQuery(ctx context.Context, query handlers.SearchQuery, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	args := m.Called(query, filters, limit, page)
 	return args.Get(0).([]*models.Book), args.Error(1)
 }
 
-func (m *MockBookProvider) GetBooksByGenre(genre string) ([]*models.Book, error) {
+func (m *MockBookProvider) GetBooksByGenre(ctx context.Context, genre string) ([]*models.Book, error) {
 	args := m.Called(genre)
 	return args.Get(0).([]*models.Book), args.Error(1)
 }
@@ -35,3 +35,36 @@ func (m *MockBookRepository) GetByGenre(genre string) ([]*models.Book, error) {
 	return nil, args.Error(1)
 }
 
+func (m *MockBookRepository) Search(query string, filters models.SearchFilters, limit, page int) ([]*models.Book, error) {
+	args := m.Called(query, filters, limit, page)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*models.Book), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBookRepository) GetByISBN(isbn13 string) (*models.Book, error) {
+	args := m.Called(isbn13)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Book), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBookRepository) GetByWork(workID uint) ([]*models.Book, error) {
+	args := m.Called(workID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*models.Book), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBookRepository) ReplaceBookReferences(oldID, newID string) error {
+	args := m.Called(oldID, newID)
+	return args.Error(0)
+}
+
+func (m *MockBookRepository) ReplaceUserBookReferences(oldID, newID, userID string) error {
+	args := m.Called(oldID, newID, userID)
+	return args.Error(0)
+}
@@ -6,11 +6,13 @@ import (
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"github.com/FilipBudzynski/book_it/internal/repositories"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBookRepository(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
+	require.NoError(t, repositories.Migrate(db))
 	repo := repositories.NewBookRepository(db)
 
 	t.Run("Create Book", func(t *testing.T) {
@@ -51,4 +53,174 @@ func TestBookRepository(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Len(t, books, 2)
 	})
+
+	t.Run("Search Books", func(t *testing.T) {
+		repo.Create(&models.Book{ID: "4", Title: "Dune", Authors: "Frank Herbert", Description: "Spice and sandworms on Arrakis", Pages: 658, PublishedDate: "1965-08-01"})
+		repo.Create(&models.Book{ID: "5", Title: "The Road", Authors: "Cormac McCarthy", Description: "A father and son walk through a burned world"})
+		repo.Create(&models.Book{ID: "6", Title: "Children of Dune", Authors: "Frank Herbert", Pages: 444, PublishedDate: "1976"})
+
+		books, err := repo.Search("dun", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Len(t, books, 2)
+
+		books, err = repo.Search("herbert arrakis", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Len(t, books, 1)
+		assert.Equal(t, "Dune", books[0].Title)
+
+		books, err = repo.Search("mccarthy", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Len(t, books, 1)
+		assert.Equal(t, "5", books[0].ID)
+
+		books, err = repo.Search("\"*", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("Search Applies The Filters", func(t *testing.T) {
+		books, err := repo.Search("dune", models.SearchFilters{MinPages: 500}, 40, 1)
+		assert.NoError(t, err)
+		require.Len(t, books, 1)
+		assert.Equal(t, "4", books[0].ID)
+
+		books, err = repo.Search("dune", models.SearchFilters{PublishedFrom: 1970, PublishedTo: 1980}, 40, 1)
+		assert.NoError(t, err)
+		require.Len(t, books, 1)
+		assert.Equal(t, "6", books[0].ID)
+
+		books, err = repo.Search("dune", models.SearchFilters{OrderBy: models.SearchOrderNewest}, 40, 1)
+		assert.NoError(t, err)
+		require.Len(t, books, 2)
+		assert.Equal(t, "6", books[0].ID)
+	})
+
+	t.Run("Search Skips Deleted Books", func(t *testing.T) {
+		assert.NoError(t, repo.Delete("6"))
+
+		books, err := repo.Search("children", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("Get Book By ISBN", func(t *testing.T) {
+		assert.NoError(t, repo.Create(&models.Book{ID: "7", Title: "Dune", ISBN13: "9780441013593", ISBN10: "0441013597"}))
+
+		book, err := repo.GetByISBN("9780441013593")
+		assert.NoError(t, err)
+		assert.Equal(t, "7", book.ID)
+
+		_, err = repo.GetByISBN("9780593098233")
+		assert.Error(t, err)
+	})
+
+	t.Run("Editions Are Grouped Into Works", func(t *testing.T) {
+		hardcover := &models.Book{ID: "10", Title: "Solaris", Authors: "Stanisław Lem", ISBN13: "9780156027601"}
+		paperback := &models.Book{ID: "11", Title: "Solaris: A Novel", Authors: "Lem, Stanislaw"}
+		byISBN := &models.Book{ID: "12", Title: "Solaris (Polish edition)", Authors: "S. Lem", ISBN13: "9780156027601"}
+		olWork := &models.Book{ID: "13", Title: "Solyaris", Authors: "Stanislaw Lem", ProviderWorkID: "openlibrary:OL1W"}
+		olEdition := &models.Book{ID: "14", Title: "Solaris", Authors: "Unknown", ProviderWorkID: "openlibrary:OL1W"}
+		other := &models.Book{ID: "15", Title: "The Cyberiad", Authors: "Stanisław Lem"}
+		for _, book := range []*models.Book{hardcover, paperback, byISBN, olWork, olEdition, other} {
+			assert.NoError(t, repo.Create(book))
+			assert.NotNil(t, book.WorkID)
+		}
+
+		assert.Equal(t, *hardcover.WorkID, *paperback.WorkID, "matched by title and author")
+		assert.Equal(t, *hardcover.WorkID, *byISBN.WorkID, "matched by isbn")
+		assert.Equal(t, *olWork.WorkID, *olEdition.WorkID, "matched by provider work id")
+		assert.NotEqual(t, *hardcover.WorkID, *olWork.WorkID)
+		assert.NotEqual(t, *hardcover.WorkID, *other.WorkID)
+
+		editions, err := repo.GetByWork(*hardcover.WorkID)
+		assert.NoError(t, err)
+		assert.Len(t, editions, 3)
+	})
+
+	t.Run("Replace Manual Book References", func(t *testing.T) {
+		manual := &models.Book{ID: "manual-abc", Title: "Przedwiośnie", Authors: "Stefan Żeromski", CreatedBy: "user1"}
+		provider := &models.Book{ID: "20", Title: "Przedwiośnie", Authors: "Stefan Żeromski"}
+		assert.NoError(t, repo.Create(manual))
+		assert.NoError(t, repo.Create(provider))
+		assert.NoError(t, db.Create(&models.User{GoogleId: "user1", Username: "user1", Email: "user1@example.com"}).Error)
+		assert.NoError(t, db.Create(&models.User{GoogleId: "user2", Username: "user2", Email: "user2@example.com"}).Error)
+		assert.NoError(t, db.Create(&models.UserBook{UserGoogleId: "user1", BookID: manual.ID}).Error)
+		assert.NoError(t, db.Create(&models.UserBook{UserGoogleId: "user2", BookID: manual.ID}).Error)
+		assert.NoError(t, db.Create(&models.UserBook{UserGoogleId: "user2", BookID: provider.ID}).Error)
+
+		books, err := repo.Search("przedwiośnie", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Len(t, books, 2)
+
+		assert.NoError(t, repo.ReplaceBookReferences(manual.ID, provider.ID))
+
+		var user1Books []models.UserBook
+		assert.NoError(t, db.Where("user_google_id = ?", "user1").Find(&user1Books).Error)
+		assert.Len(t, user1Books, 1)
+		assert.Equal(t, provider.ID, user1Books[0].BookID)
+
+		var user2Manual int64
+		db.Model(&models.UserBook{}).Where("user_google_id = ? AND book_id = ?", "user2", manual.ID).Count(&user2Manual)
+		assert.Equal(t, int64(1), user2Manual, "users who already have the provider book keep their entry")
+
+		linked, err := repo.Get(manual.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, provider.ID, linked.LinkedBookID)
+
+		books, err = repo.Search("przedwiośnie", models.SearchFilters{}, 40, 1)
+		assert.NoError(t, err)
+		assert.Len(t, books, 1, "linked manual books are hidden from search")
+		assert.Equal(t, provider.ID, books[0].ID)
+	})
+
+	t.Run("Replace Only The User's References", func(t *testing.T) {
+		manual := &models.Book{ID: "manual-def", Title: "Ferdydurke", Authors: "Witold Gombrowicz", CreatedBy: "user1"}
+		provider := &models.Book{ID: "21", Title: "Trans-Atlantyk", Authors: "Witold Gombrowicz"}
+		assert.NoError(t, repo.Create(manual))
+		assert.NoError(t, repo.Create(provider))
+		assert.NoError(t, db.Create(&models.UserBook{UserGoogleId: "user1", BookID: manual.ID}).Error)
+		assert.NoError(t, db.Create(&models.UserBook{UserGoogleId: "user2", BookID: manual.ID}).Error)
+
+		assert.NoError(t, repo.ReplaceUserBookReferences(manual.ID, provider.ID, "user1"))
+
+		var moved, kept int64
+		db.Model(&models.UserBook{}).Where("user_google_id = ? AND book_id = ?", "user1", provider.ID).Count(&moved)
+		db.Model(&models.UserBook{}).Where("user_google_id = ? AND book_id = ?", "user2", manual.ID).Count(&kept)
+		assert.Equal(t, int64(1), moved)
+		assert.Equal(t, int64(1), kept, "other users keep the manual book")
+
+		unlinked, err := repo.Get(manual.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, unlinked.LinkedBookID)
+	})
+}
+
+func TestMigrateNumericISBN(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, db.Exec("ALTER TABLE books ADD COLUMN isbn integer").Error)
+	require.NoError(t, db.Create(&models.User{GoogleId: "reader", Username: "reader", Email: "reader@example.com"}).Error)
+	for id, number := range map[string]uint64{"dune": 9780441013593, "zeroed": 441013597, "broken": 12345} {
+		require.NoError(t, db.Create(&models.Book{ID: id, Title: id}).Error)
+		require.NoError(t, db.Exec("UPDATE books SET isbn = ? WHERE id = ?", number, id).Error)
+	}
+	require.NoError(t, db.Create(&models.UserBook{UserGoogleId: "reader", BookID: "dune"}).Error)
+
+	require.NoError(t, repositories.Migrate(db))
+
+	assert.False(t, db.Migrator().HasColumn(&models.Book{}, "isbn"))
+	books := map[string]models.Book{}
+	for _, id := range []string{"dune", "zeroed", "broken"} {
+		book := models.Book{}
+		require.NoError(t, db.First(&book, "id = ?", id).Error)
+		books[id] = book
+	}
+	assert.Equal(t, "9780441013593", books["dune"].ISBN13)
+	assert.Equal(t, "0441013597", books["dune"].ISBN10)
+	assert.Equal(t, "9780441013593", books["zeroed"].ISBN13, "the leading zero of the ISBN-10 is put back")
+	assert.Empty(t, books["broken"].ISBN13)
+
+	var userBooks int64
+	db.Model(&models.UserBook{}).Where("book_id = ?", "dune").Count(&userBooks)
+	assert.Equal(t, int64(1), userBooks, "dropping the column keeps the rows pointing at the books")
 }
@@ -0,0 +1,48 @@
+//go:build sqlite_fts5
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run with -tags sqlite_fts5, as the Makefile does, the other search tests pass on the LIKE fallback too
+func TestBookFullTextSearch(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// saved before the migration, indexed when the index is created
+	require.NoError(t, db.Create(&models.Book{ID: "solitude", Title: "One Hundred Years of Solitude", Authors: "Gabriel García Márquez"}).Error)
+	require.NoError(t, repositories.Migrate(db))
+	repo := repositories.NewBookRepository(db)
+
+	require.NoError(t, repo.Create(&models.Book{ID: "about", Title: "Reading Marquez", Description: "Essays on solitude and cholera"}))
+	require.NoError(t, repo.Create(&models.Book{ID: "cholera", Title: "Love in the Time of Cholera", Authors: "Gabriel García Márquez"}))
+
+	var tables int64
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE name = 'books_fts'").Scan(&tables)
+	assert.Equal(t, int64(1), tables)
+
+	t.Run("Diacritics are ignored", func(t *testing.T) {
+		books, err := repo.Search("garcia marquez", models.SearchFilters{}, 40, 1)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, book := range books {
+			ids = append(ids, book.ID)
+		}
+		assert.ElementsMatch(t, []string{"solitude", "cholera"}, ids)
+	})
+
+	t.Run("Title matches rank first", func(t *testing.T) {
+		books, err := repo.Search("solitude", models.SearchFilters{}, 40, 1)
+		require.NoError(t, err)
+		require.Len(t, books, 2)
+		assert.Equal(t, "solitude", books[0].ID)
+		assert.Equal(t, "about", books[1].ID)
+	})
+}
@@ -1,16 +1,25 @@
 package unit
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/isbn"
 	"github.com/FilipBudzynski/book_it/internal/models"
 	"github.com/FilipBudzynski/book_it/internal/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBookService(t *testing.T) {
+	ctx := context.Background()
 	repo := new(MockBookRepository)
 	provider := new(MockBookProvider)
 	svc := services.NewBookService(repo).WithProvider(provider)
@@ -26,7 +35,7 @@ func TestBookService(t *testing.T) {
 	t.Run("GetByID - Exists in Repo", func(t *testing.T) {
 		book := &models.Book{ID: "1", Title: "Test Book"}
 		repo.On("Get", "1").Return(book, nil)
-		got, err := svc.GetByID("1")
+		got, err := svc.GetByID(ctx, "1")
 		assert.NoError(t, err)
 		assert.Equal(t, book, got)
 		repo.AssertExpectations(t)
@@ -37,7 +46,7 @@ func TestBookService(t *testing.T) {
 		repo.On("Get", "2").Return((*models.Book)(nil), errors.New("not found"))
 		provider.On("GetBook", "2").Return(book, nil)
 		repo.On("Create", book).Return(nil)
-		got, err := svc.GetByID("2")
+		got, err := svc.GetByID(ctx, "2")
 		assert.NoError(t, err)
 		assert.Equal(t, book, got)
 		repo.AssertExpectations(t)
@@ -53,27 +62,181 @@ func TestBookService(t *testing.T) {
 
 	t.Run("GetByQuery", func(t *testing.T) {
 		books := []*models.Book{{ID: "3", Title: "Query Book"}}
-		provider.On("GetBooksByQuery", "test", handlers.QueryTypeTitle, 40, 1).Return(books, nil)
+		provider.On("GetBooksByQuery", handlers.NewSearchQuery("test", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return(books, nil)
 		repo.On("Get", "3").Return((*models.Book)(nil), errors.New("not found"))
 		repo.On("Create", books[0]).Return(nil)
-		got, err := svc.GetByQuery("test", handlers.QueryTypeTitle, 1)
+		got, next, err := svc.GetByQuery(ctx, handlers.NewSearchQuery("test", handlers.QueryTypeTitle), models.SearchFilters{}, 1)
 		assert.NoError(t, err)
 		assert.Equal(t, books, got)
+		assert.Zero(t, next, "a short page is the last one")
 		repo.AssertExpectations(t)
 		provider.AssertExpectations(t)
 	})
 
+	t.Run("GetByQuery - Falls back to local search", func(t *testing.T) {
+		books := []*models.Book{{ID: "4", Title: "Saved Book"}}
+		provider.On("GetBooksByQuery", handlers.NewSearchQuery("saved", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book(nil), errors.New("503"))
+		repo.On("Search", "saved", models.SearchFilters{}, 40, 1).Return(books, nil)
+		got, _, err := svc.GetByQuery(ctx, handlers.NewSearchQuery("saved", handlers.QueryTypeTitle), models.SearchFilters{}, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, books, got)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("GetByQuery - Normalizes ISBN", func(t *testing.T) {
+		books := []*models.Book{{ID: "5", Title: "Dune", ISBN13: "9780441013593"}}
+		provider.On("GetBooksByQuery", handlers.NewSearchQuery("9780441013593", handlers.QueryTypeISBN), models.SearchFilters{}, 40, 1).Return(books, nil)
+		repo.On("Get", "5").Return(books[0], nil)
+		got, _, err := svc.GetByQuery(ctx, handlers.NewSearchQuery("0-441-01359-7", handlers.QueryTypeISBN), models.SearchFilters{}, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, books, got)
+		provider.AssertExpectations(t)
+	})
+
+	t.Run("GetByQuery - Applies the filters the provider cannot", func(t *testing.T) {
+		filters := models.SearchFilters{MinPages: 200, PublishedFrom: 2000, OrderBy: models.SearchOrderNewest}
+		books := []*models.Book{
+			{ID: "13", Pages: 400, PublishedDate: "2021-01"},
+			{ID: "11", Pages: 150, PublishedDate: "2010"},
+			{ID: "10", Pages: 300, PublishedDate: "2004-05-01"},
+			{ID: "12", Pages: 250, PublishedDate: "1999"},
+		}
+		provider.On("GetBooksByQuery", handlers.NewSearchQuery("filtered", handlers.QueryTypeTitle), filters, 40, 1).Return(books, nil)
+		for _, book := range books {
+			repo.On("Get", book.ID).Return(book, nil)
+		}
+
+		got, _, err := svc.GetByQuery(ctx, handlers.NewSearchQuery("filtered", handlers.QueryTypeTitle), filters, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Book{books[0], books[2]}, got)
+	})
+
+	t.Run("GetByQuery - Pages emptied by the filters are followed by the next ones", func(t *testing.T) {
+		filters := models.SearchFilters{MinPages: 500}
+		query := handlers.NewSearchQuery("long", handlers.QueryTypeTitle)
+		short := make([]*models.Book, services.SearchPageSize)
+		for i := range short {
+			short[i] = &models.Book{ID: fmt.Sprintf("short-%d", i), Pages: 100}
+			repo.On("Get", short[i].ID).Return(short[i], nil)
+		}
+		long := &models.Book{ID: "long", Pages: 900}
+		repo.On("Get", "long").Return(long, nil)
+		provider.On("GetBooksByQuery", query, filters, 40, 1).Return(short, nil).Once()
+		provider.On("GetBooksByQuery", query, filters, 40, 2).Return(append(short[:39:39], long), nil).Once()
+		provider.On("GetBooksByQuery", query, filters, 40, 3).Return([]*models.Book{}, nil).Once()
+
+		got, next, err := svc.GetByQuery(ctx, query, filters, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Book{long}, got)
+		assert.Zero(t, next, "the provider ran out of results")
+	})
+
+	t.Run("GetByISBN - Exists in Repo", func(t *testing.T) {
+		book := &models.Book{ID: "6", ISBN13: "9780804429573", ISBN10: "080442957X"}
+		repo.On("GetByISBN", "9780804429573").Return(book, nil)
+		got, err := svc.GetByISBN(ctx, "0-8044-2957-x")
+		assert.NoError(t, err)
+		assert.Equal(t, book, got)
+	})
+
+	t.Run("GetByISBN - Fetch from Provider", func(t *testing.T) {
+		book := &models.Book{ID: "7", ISBN13: "9780593098233"}
+		repo.On("GetByISBN", "9780593098233").Return((*models.Book)(nil), errors.New("not found"))
+		provider.On("GetBooksByQuery", handlers.NewSearchQuery("9780593098233", handlers.QueryTypeISBN), models.SearchFilters{}, 1, 1).Return([]*models.Book{book}, nil)
+		repo.On("Get", "7").Return((*models.Book)(nil), errors.New("not found"))
+		repo.On("Create", book).Return(nil)
+		got, err := svc.GetByISBN(ctx, "0593098234")
+		assert.NoError(t, err)
+		assert.Equal(t, book, got)
+	})
+
+	t.Run("GetByISBN - Invalid", func(t *testing.T) {
+		_, err := svc.GetByISBN(ctx, "0593098235")
+		assert.ErrorIs(t, err, isbn.ErrInvalidChecksum)
+	})
+
+	t.Run("GetEditions", func(t *testing.T) {
+		workID := uint(3)
+		editions := []*models.Book{{ID: "8", WorkID: &workID}, {ID: "9", WorkID: &workID}}
+		repo.On("GetByWork", workID).Return(editions, nil)
+		got, err := svc.GetEditions(workID)
+		assert.NoError(t, err)
+		assert.Equal(t, editions, got)
+	})
+
+	t.Run("CreateManual", func(t *testing.T) {
+		input := &models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Pages: 680}
+		repo.On("Create", mock.MatchedBy(func(b *models.Book) bool { return b.Title == "Lalka" })).Return(nil).Once()
+		got, err := svc.CreateManual("user123", input)
+		assert.NoError(t, err)
+		assert.True(t, got.IsManual())
+		assert.Equal(t, "user123", got.CreatedBy)
+	})
+
+	t.Run("CreateManual - Duplicate ISBN", func(t *testing.T) {
+		input := &models.ManualBook{Title: "Dune", Authors: "Frank Herbert", ISBN: "0441013597"}
+		repo.On("GetByISBN", "9780441013593").Return(&models.Book{ID: "g1"}, nil).Once()
+		_, err := svc.CreateManual("user123", input)
+		assert.ErrorIs(t, err, models.ErrManualBookISBNExists)
+	})
+
+	t.Run("LinkManualBook", func(t *testing.T) {
+		manual := &models.Book{ID: "manual-1", Title: "Lalka", Authors: "Bolesław Prus", CreatedBy: "user123"}
+		target := &models.Book{ID: "g10", Title: "Lalka: powieść", Authors: "Prus, Bolesław"}
+		repo.On("Get", "manual-1").Return(manual, nil)
+		repo.On("Get", "g10").Return(target, nil)
+		repo.On("ReplaceBookReferences", "manual-1", "g10").Return(nil).Once()
+
+		_, err := svc.LinkManualBook(ctx, "other-user", "manual-1", "g10")
+		assert.ErrorIs(t, err, models.ErrManualBookNotCreator)
+
+		got, err := svc.LinkManualBook(ctx, "user123", "manual-1", "g10")
+		assert.NoError(t, err)
+		assert.Equal(t, target, got)
+		repo.AssertCalled(t, "ReplaceBookReferences", "manual-1", "g10")
+	})
+
+	t.Run("LinkManualBook - Another book moves only the creator's references", func(t *testing.T) {
+		other := &models.Book{ID: "g11", Title: "Faraon", Authors: "Bolesław Prus"}
+		repo.On("Get", "g11").Return(other, nil)
+		repo.On("ReplaceUserBookReferences", "manual-1", "g11", "user123").Return(nil).Once()
+
+		_, err := svc.LinkManualBook(ctx, "user123", "manual-1", "g11")
+		assert.NoError(t, err)
+		repo.AssertNotCalled(t, "ReplaceBookReferences", "manual-1", "g11")
+	})
+
+	t.Run("CreateManual - Uploaded covers are stored as files", func(t *testing.T) {
+		dir := t.TempDir()
+		coverSvc := services.NewBookService(repo).WithCoverDir(dir)
+		png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		input := &models.ManualBook{Title: "Emancypantki", Authors: "Bolesław Prus", Cover: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)}
+		repo.On("Create", mock.MatchedBy(func(b *models.Book) bool { return b.Title == "Emancypantki" })).Return(nil).Once()
+
+		got, err := coverSvc.CreateManual("user123", input)
+		require.NoError(t, err)
+		assert.Equal(t, services.CoverURLPrefix+got.ID+".png", got.ImageLink)
+		stored, err := os.ReadFile(filepath.Join(dir, got.ID+".png"))
+		require.NoError(t, err)
+		assert.Equal(t, png, stored)
+	})
+
 	t.Run("FetchReccomendations", func(t *testing.T) {
 		genres := []models.Genre{{Name: "Sci-Fi"}}
 		books := []*models.Book{{ID: "4", Title: "Sci-Fi Book"}}
 		userBooks := []*models.UserBook{{Book: models.Book{ID: "5"}}}
 		provider.On("GetBooksByGenre", "Sci-Fi").Return(books, nil)
+		repo.On("GetByGenre", "Sci-Fi").Return([]*models.Book{{ID: "5"}}, nil)
 		repo.On("Get", "4").Return((*models.Book)(nil), errors.New("not found"))
 		repo.On("Create", books[0]).Return(nil)
-		got, err := svc.FetchReccomendations(genres, userBooks)
+		got, err := svc.FetchReccomendations(ctx, genres, userBooks, nil, 1)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1, "books already on the shelf are not recommended")
+		assert.Equal(t, books[0], got[0].Book)
+
+		got, err = svc.FetchReccomendations(ctx, genres, userBooks, []*models.RecommendationFeedback{{BookID: "4", Kind: models.FeedbackNotInterested}}, 1)
 		assert.NoError(t, err)
-		assert.Len(t, got, 1)
-		assert.Equal(t, books[0], got[0])
+		assert.Empty(t, got, "turned down books are not recommended")
 		repo.AssertExpectations(t)
 		provider.AssertExpectations(t)
 	})
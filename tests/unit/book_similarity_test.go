@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shelf(user string, books ...string) []models.ShelfEntry {
+	entries := make([]models.ShelfEntry, len(books))
+	for i, book := range books {
+		entries[i] = models.ShelfEntry{UserGoogleId: user, BookID: book}
+	}
+	return entries
+}
+
+func TestRecommendSimilarities(t *testing.T) {
+	t.Run("Books shelved together by enough readers are similar", func(t *testing.T) {
+		entries := append(shelf("anna", "solaris", "dune", "hyperion"), shelf("ben", "solaris", "dune")...)
+		entries = append(entries, shelf("cleo", "solaris", "hyperion", "dune")...)
+		entries = append(entries, shelf("dan", "emma")...)
+
+		similarities := recommend.Similarities(entries)
+
+		byPair := map[[2]string]models.BookSimilarity{}
+		for _, similarity := range similarities {
+			byPair[[2]string{similarity.BookID, similarity.SimilarBookID}] = similarity
+		}
+		require.Len(t, byPair, 6, "every pair is stored in both directions")
+		assert.InDelta(t, 1, byPair[[2]string{"solaris", "dune"}].Score, 1e-9)
+		assert.Equal(t, 3, byPair[[2]string{"dune", "solaris"}].Readers)
+		assert.InDelta(t, 2/(3*0.8164965809), byPair[[2]string{"solaris", "hyperion"}].Score, 1e-6)
+		assert.Equal(t, "dune", similarities[0].BookID, "sorted by book and score")
+	})
+
+	t.Run("Pairs of a single reader are ignored", func(t *testing.T) {
+		assert.Empty(t, recommend.Similarities(shelf("anna", "solaris", "dune")))
+	})
+
+	t.Run("CollaborativeScores leave owned books out and scale to 1", func(t *testing.T) {
+		library := []*models.UserBook{
+			{BookID: "solaris", Book: models.Book{Title: "Solaris"}, ReadingProgress: &models.ReadingProgress{Completed: true}},
+			{BookID: "dune", Book: models.Book{Title: "Dune"}},
+		}
+		similarities := []models.BookSimilarity{
+			{BookID: "solaris", SimilarBookID: "dune", Score: 0.9},
+			{BookID: "solaris", SimilarBookID: "hyperion", Score: 0.5},
+			{BookID: "dune", SimilarBookID: "hyperion", Score: 0.5},
+			{BookID: "dune", SimilarBookID: "emma", Score: 0.4},
+		}
+
+		scores := recommend.CollaborativeScores(library, similarities)
+
+		require.Len(t, scores, 2)
+		assert.InDelta(t, 1, scores["hyperion"].Score, 1e-9)
+		assert.Equal(t, "Solaris", scores["hyperion"].Because.Title, "the finished book weighs more")
+		assert.InDelta(t, 0.4/1.5, scores["emma"].Score, 1e-9)
+		assert.Equal(t, "Dune", scores["emma"].Because.Title)
+	})
+
+	t.Run("Blend reorders by the mixed score", func(t *testing.T) {
+		genre := models.RecommendationReason{Kind: models.ReasonGenre, Subject: "Fantasy"}
+		recommendations := []*models.Recommendation{
+			{Book: &models.Book{ID: "a"}, Score: 0.6, GenreScore: 1, Reason: genre},
+			{Book: &models.Book{ID: "b"}, Score: 0.4, GenreScore: 1, Reason: genre},
+		}
+
+		blended := recommend.Blend(recommendations, map[string]recommend.Collaborative{
+			"a": {Score: 0.1, Because: &models.Book{Title: "Solaris"}},
+			"b": {Score: 1, Because: &models.Book{Title: "Solaris"}},
+		}, 0.5)
+
+		assert.Equal(t, "b", blended[0].Book.ID)
+		assert.InDelta(t, 0.7, blended[0].Score, 1e-9)
+		assert.InDelta(t, 1, blended[0].CollaborativeScore, 1e-9)
+		assert.InDelta(t, 0.35, blended[1].Score, 1e-9)
+		assert.Equal(t, "Readers of Solaris also read this", blended[0].Reason.String())
+		assert.Equal(t, genre, blended[1].Reason, "the genre still outweighs the readers")
+	})
+}
+
+func TestBookSimilarityRepository(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := repositories.NewBookSimilarityRepository(db)
+
+	for _, user := range []string{"anna", "ben"} {
+		require.NoError(t, db.Create(&models.User{GoogleId: user, Email: user + "@example.com"}).Error)
+	}
+	for _, book := range []string{"solaris", "dune", "emma"} {
+		require.NoError(t, db.Create(&models.Book{ID: book, Title: book}).Error)
+	}
+	shelve := func(user, book string, completed bool) *models.UserBook {
+		userBook := &models.UserBook{UserGoogleId: user, BookID: book}
+		require.NoError(t, db.Create(userBook).Error)
+		if completed {
+			require.NoError(t, db.Create(&models.ReadingProgress{UserBookID: userBook.ID, TotalPages: 10, CurrentPage: 10, Completed: true}).Error)
+		}
+		return userBook
+	}
+	shelve("anna", "solaris", true)
+	shelve("anna", "dune", false)
+	shelve("ben", "solaris", false)
+	shelve("ben", "dune", false)
+	removed := shelve("ben", "emma", false)
+	require.NoError(t, db.Delete(removed).Error)
+
+	t.Run("ShelfEntries", func(t *testing.T) {
+		entries, err := repo.ShelfEntries()
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []models.ShelfEntry{
+			{UserGoogleId: "anna", BookID: "solaris", Completed: true},
+			{UserGoogleId: "anna", BookID: "dune"},
+			{UserGoogleId: "ben", BookID: "solaris"},
+			{UserGoogleId: "ben", BookID: "dune"},
+		}, entries)
+	})
+
+	t.Run("Rebuild replaces the table", func(t *testing.T) {
+		require.NoError(t, repo.Replace([]models.BookSimilarity{{BookID: "emma", SimilarBookID: "dune", Score: 1}}))
+		require.NoError(t, services.NewSimilarityService(repo).Rebuild())
+
+		similarities, err := repo.GetForBooks([]string{"solaris", "emma"})
+		require.NoError(t, err)
+		require.Len(t, similarities, 1)
+		assert.Equal(t, "dune", similarities[0].SimilarBookID)
+		assert.Equal(t, 2, similarities[0].Readers)
+	})
+}
@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/providers"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestCachedProvider(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*MockBookProvider, *fakeClock, interface {
+		handlers.BookProvider
+		Stats() providers.CacheStats
+		Prune() error
+	}) {
+		db, cleanup := setupTestDB(t)
+		t.Cleanup(cleanup)
+
+		mockProvider := new(MockBookProvider)
+		clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+		cached := providers.NewCachedProvider(mockProvider, repositories.NewProviderCacheRepository(db)).
+			WithTTL(time.Hour).
+			WithStaleWindow(24 * time.Hour).
+			WithClock(clock.Now)
+		return mockProvider, clock, cached
+	}
+
+	t.Run("repeated query is served from cache", func(t *testing.T) {
+		mockProvider, _, cached := setup(t)
+		books := []*models.Book{{ID: "1", Title: "Solaris", Genres: []models.Genre{{Name: "Sci-Fi"}}}}
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("solaris", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return(books, nil).Once()
+
+		first, err := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("solaris", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1)
+		require.NoError(t, err)
+		second, err := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("  Solaris ", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1)
+		require.NoError(t, err)
+
+		assert.Equal(t, first[0].Title, second[0].Title)
+		assert.Equal(t, "Sci-Fi", second[0].Genres[0].Name)
+		assert.Equal(t, providers.CacheStats{Hits: 1, Misses: 1}, cached.Stats())
+		mockProvider.AssertExpectations(t)
+	})
+
+	t.Run("key includes query type, page and genre", func(t *testing.T) {
+		mockProvider, _, cached := setup(t)
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("lem", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book{{ID: "1"}}, nil).Once()
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("lem", handlers.QueryTypeAuthor), models.SearchFilters{}, 40, 1).Return([]*models.Book{{ID: "2"}}, nil).Once()
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("lem", handlers.QueryTypeAuthor), models.SearchFilters{}, 40, 2).Return([]*models.Book{{ID: "3"}}, nil).Once()
+		mockProvider.On("GetBooksByGenre", "Fantasy").Return([]*models.Book{{ID: "4"}}, nil).Once()
+		mockProvider.On("GetBooksByGenre", "Horror").Return([]*models.Book{{ID: "5"}}, nil).Once()
+
+		byTitle, _ := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("lem", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1)
+		byAuthor, _ := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("lem", handlers.QueryTypeAuthor), models.SearchFilters{}, 40, 1)
+		secondPage, _ := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("lem", handlers.QueryTypeAuthor), models.SearchFilters{}, 40, 2)
+		fantasy, _ := cached.GetBooksByGenre(ctx, "Fantasy")
+		horror, _ := cached.GetBooksByGenre(ctx, "Horror")
+
+		assert.Equal(t, "1", byTitle[0].ID)
+		assert.Equal(t, "2", byAuthor[0].ID)
+		assert.Equal(t, "3", secondPage[0].ID)
+		assert.Equal(t, "4", fantasy[0].ID)
+		assert.Equal(t, "5", horror[0].ID)
+		assert.Equal(t, int64(5), cached.Stats().Misses)
+		mockProvider.AssertExpectations(t)
+	})
+
+	t.Run("stale entry is served while refreshed in the background", func(t *testing.T) {
+		mockProvider, clock, cached := setup(t)
+		mockProvider.On("GetBook", "abc").Return(&models.Book{ID: "abc", Title: "Old"}, nil).Once()
+		_, err := cached.GetBook(ctx, "abc")
+		require.NoError(t, err)
+
+		clock.Advance(2 * time.Hour)
+		mockProvider.On("GetBook", "abc").Return(&models.Book{ID: "abc", Title: "New"}, nil).Once()
+
+		stale, err := cached.GetBook(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "Old", stale.Title)
+
+		assert.Eventually(t, func() bool {
+			book, err := cached.GetBook(ctx, "abc")
+			return err == nil && book.Title == "New"
+		}, time.Second, 10*time.Millisecond)
+		assert.GreaterOrEqual(t, cached.Stats().StaleHits, int64(1))
+		mockProvider.AssertExpectations(t)
+	})
+
+	t.Run("provider outage is covered by stale entries", func(t *testing.T) {
+		mockProvider, clock, cached := setup(t)
+		mockProvider.On("GetBooksByGenre", "Fantasy").Return([]*models.Book{{ID: "1"}}, nil).Once()
+		_, err := cached.GetBooksByGenre(ctx, "Fantasy")
+		require.NoError(t, err)
+
+		clock.Advance(3 * time.Hour)
+		mockProvider.On("GetBooksByGenre", "Fantasy").Return([]*models.Book(nil), errors.New("503"))
+
+		books, err := cached.GetBooksByGenre(ctx, "Fantasy")
+		require.NoError(t, err)
+		assert.Len(t, books, 1)
+
+		clock.Advance(48 * time.Hour)
+		_, err = cached.GetBooksByGenre(ctx, "Fantasy")
+		assert.Error(t, err, "entries past the stale window are not served")
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		mockProvider, _, cached := setup(t)
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("x", handlers.QueryTypeISBN), models.SearchFilters{}, 40, 1).Return([]*models.Book(nil), errors.New("429")).Once()
+		mockProvider.On("GetBooksByQuery", handlers.NewSearchQuery("x", handlers.QueryTypeISBN), models.SearchFilters{}, 40, 1).Return([]*models.Book{{ID: "1"}}, nil).Once()
+
+		_, err := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("x", handlers.QueryTypeISBN), models.SearchFilters{}, 40, 1)
+		assert.Error(t, err)
+		books, err := cached.GetBooksByQuery(ctx, handlers.NewSearchQuery("x", handlers.QueryTypeISBN), models.SearchFilters{}, 40, 1)
+		require.NoError(t, err)
+		assert.Len(t, books, 1)
+		assert.Equal(t, int64(1), cached.Stats().Errors)
+	})
+
+	t.Run("prune removes expired entries", func(t *testing.T) {
+		mockProvider, clock, cached := setup(t)
+		mockProvider.On("GetBooksByGenre", "Poetry").Return([]*models.Book{{ID: "1"}}, nil).Twice()
+
+		_, _ = cached.GetBooksByGenre(ctx, "Poetry")
+		clock.Advance(30 * time.Hour)
+		require.NoError(t, cached.Prune())
+		_, _ = cached.GetBooksByGenre(ctx, "Poetry")
+
+		assert.Equal(t, int64(2), cached.Stats().Misses)
+		mockProvider.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,149 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompositeProvider(t *testing.T) {
+	ctx := context.Background()
+	t.Run("GetBook falls back when the primary provider errors", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		book := &models.Book{ID: "OL1W", Title: "Solaris", Pages: 204, Description: "Ocean", ImageLink: "img"}
+		primary.On("GetBook", "OL1W").Return((*models.Book)(nil), errors.New("404"))
+		secondary.On("GetBook", "OL1W").Return(book, nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		got, err := provider.GetBook(ctx, "OL1W")
+
+		require.NoError(t, err)
+		assert.Equal(t, book, got)
+		primary.AssertExpectations(t)
+		secondary.AssertExpectations(t)
+	})
+
+	t.Run("GetBook fills missing fields by ISBN", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		book := &models.Book{ID: "g1", Title: "Dune", ISBN13: "9780441013593", Pages: 0, ImageLink: "google-img"}
+		other := &models.Book{ID: "OL2M", ISBN13: "9780441013593", Pages: 528, Description: "Arrakis", ImageLink: "ol-img"}
+		primary.On("GetBook", "g1").Return(book, nil)
+		secondary.On("GetBooksByQuery", handlers.NewSearchQuery("9780441013593", handlers.QueryTypeISBN), models.SearchFilters{}, 1, 1).Return([]*models.Book{other}, nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		got, err := provider.GetBook(ctx, "g1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "g1", got.ID)
+		assert.Equal(t, 528, got.Pages)
+		assert.Equal(t, "Arrakis", got.Description)
+		assert.Equal(t, "google-img", got.ImageLink, "fields present in the primary are kept")
+		secondary.AssertExpectations(t)
+	})
+
+	t.Run("GetBook returns an error when all providers fail", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		primary.On("GetBook", "x").Return((*models.Book)(nil), errors.New("quota"))
+		secondary.On("GetBook", "x").Return((*models.Book)(nil), errors.New("down"))
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		_, err := provider.GetBook(ctx, "x")
+
+		assert.ErrorContains(t, err, "quota")
+		assert.ErrorContains(t, err, "down")
+	})
+
+	t.Run("GetBook asks only the provider that owns the id", func(t *testing.T) {
+		google := &ownerProvider{MockBookProvider: new(MockBookProvider), owns: func(id string) bool { return !providers.IsOpenLibraryID(id) }}
+		openLibrary := &ownerProvider{MockBookProvider: new(MockBookProvider), owns: providers.IsOpenLibraryID}
+		google.On("GetBook", "zyTCAlFPjgYC").Return((*models.Book)(nil), errors.New("quota"))
+
+		provider := providers.NewCompositeProvider(google, openLibrary)
+		_, err := provider.GetBook(ctx, "zyTCAlFPjgYC")
+
+		assert.ErrorContains(t, err, "quota")
+		google.AssertExpectations(t)
+		openLibrary.AssertNotCalled(t, "GetBook", "zyTCAlFPjgYC")
+	})
+
+	t.Run("GetBook treats a nil book as not found", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		primary.On("GetBook", "x").Return((*models.Book)(nil), nil)
+		secondary.On("GetBook", "x").Return((*models.Book)(nil), nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		_, err := provider.GetBook(ctx, "x")
+
+		assert.ErrorIs(t, err, providers.ErrProviderBookNotFound)
+	})
+
+	t.Run("GetBooksByQuery merges editions by ISBN", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		primary.On("GetBooksByQuery", handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book{
+			{ID: "g1", Title: "Dune", ISBN13: "9780441013593"},
+			{ID: "g2", Title: "Dune Messiah"},
+		}, nil)
+		secondary.On("GetBooksByQuery", handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book{
+			{ID: "OL1W", Title: "Dune", ISBN13: "9780441013593", Pages: 604},
+			{ID: "OL3W", Title: "Children of Dune", ISBN13: "9780593098240"},
+		}, nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		books, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1)
+
+		require.NoError(t, err)
+		require.Len(t, books, 3)
+		assert.Equal(t, "g1", books[0].ID)
+		assert.Equal(t, 604, books[0].Pages)
+		assert.Equal(t, "g2", books[1].ID)
+		assert.Equal(t, "OL3W", books[2].ID)
+	})
+
+	t.Run("GetBooksByQuery tolerates a failing provider", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		primary.On("GetBooksByQuery", handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book(nil), errors.New("429"))
+		secondary.On("GetBooksByQuery", handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1).Return([]*models.Book{{ID: "OL1W"}}, nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		books, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 40, 1)
+
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+		assert.Equal(t, "OL1W", books[0].ID)
+	})
+
+	t.Run("GetBooksByGenre uses the first provider that answers", func(t *testing.T) {
+		primary := new(MockBookProvider)
+		secondary := new(MockBookProvider)
+		primary.On("GetBooksByGenre", "Fantasy").Return([]*models.Book(nil), errors.New("503"))
+		secondary.On("GetBooksByGenre", "Fantasy").Return([]*models.Book{{ID: "OL5W"}}, nil)
+
+		provider := providers.NewCompositeProvider(primary, secondary)
+		books, err := provider.GetBooksByGenre(ctx, "Fantasy")
+
+		require.NoError(t, err)
+		assert.Len(t, books, 1)
+	})
+}
+
+// ownerProvider is a mocked provider that tells which book ids are its own
+type ownerProvider struct {
+	*MockBookProvider
+	owns func(id string) bool
+}
+
+func (p *ownerProvider) OwnsID(bookID string) bool {
+	return p.owns(bookID)
+}
@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEbookService(t *testing.T) {
+	ctx := context.Background()
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, db.Create(&models.User{GoogleId: "user1", Username: "user1", Email: "user1@example.com"}).Error)
+
+	bookRepo := repositories.NewBookRepository(db)
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "dune", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593", ISBN10: "0441013597"}))
+
+	userBookRepo := repositories.NewUserBookRepository(db)
+	coverDir := t.TempDir()
+	bookService := services.NewBookService(bookRepo).WithProvider(new(MockBookProvider)).WithCoverDir(coverDir)
+	storageDir := t.TempDir()
+	service := services.NewEbookService(repositories.NewEbookFileRepository(db), bookService, userBookRepo).
+		WithStorageDir(storageDir)
+
+	t.Run("Matches the catalogue by isbn", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf":      epub2Package,
+		})
+
+		book, err := service.Upload(ctx, "user1", "dune.epub", data, false)
+		require.NoError(t, err)
+		assert.Equal(t, "dune", book.ID)
+
+		_, err = userBookRepo.GetByUserAndBook("user1", "dune")
+		assert.NoError(t, err)
+
+		_, err = service.File("user1", "dune")
+		assert.Error(t, err, "the file is not kept unless asked for")
+		entries, _ := os.ReadDir(storageDir)
+		assert.Empty(t, entries)
+	})
+
+	var manualID string
+
+	t.Run("Unknown books are added as manual books", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf":      epub3Package,
+			"OEBPS/cover.png":        "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		})
+
+		book, err := service.Upload(ctx, "user1", "przedwiosnie.epub", data, true)
+		require.NoError(t, err)
+		assert.True(t, book.IsManual())
+		assert.Equal(t, "user1", book.CreatedBy)
+		assert.Equal(t, "Stefan Żeromski", book.Authors)
+		assert.Equal(t, "pl", book.Language)
+		assert.Equal(t, services.CoverURLPrefix+book.ID+".png", book.ImageLink)
+		_, err = os.Stat(filepath.Join(coverDir, book.ID+".png"))
+		assert.NoError(t, err, "the cover is kept as a file")
+		manualID = book.ID
+
+		file, err := service.File("user1", book.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "przedwiosnie.epub", file.FileName)
+		stored, err := os.ReadFile(file.Path)
+		require.NoError(t, err)
+		assert.Equal(t, data, stored)
+	})
+
+	t.Run("Uploading the same book again reuses it", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf":      epub3Package,
+		})
+
+		book, err := service.Upload(ctx, "user1", "przedwiosnie.epub", data, false)
+		require.NoError(t, err)
+		assert.Equal(t, manualID, book.ID)
+
+		userBooks, err := userBookRepo.GetAllUserBooks("user1")
+		require.NoError(t, err)
+		assert.Len(t, userBooks, 2)
+	})
+
+	t.Run("Keeping files needs a storage directory", func(t *testing.T) {
+		withoutStorage := services.NewEbookService(repositories.NewEbookFileRepository(db), bookService, userBookRepo)
+		_, err := withoutStorage.Upload(ctx, "user1", "dune.epub", []byte("data"), true)
+		assert.ErrorIs(t, err, models.ErrEbookStorageMissing)
+	})
+}
@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/epub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const epubContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const epub2Package = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:title>Dune</dc:title>
+    <dc:creator opf:role="aut" opf:file-as="Herbert, Frank">Frank Herbert</dc:creator>
+    <dc:creator opf:role="ill">John Schoenherr</dc:creator>
+    <dc:identifier id="bookid">urn:uuid:1b2c3d</dc:identifier>
+    <dc:identifier opf:scheme="ISBN">urn:isbn:0-441-01359-7</dc:identifier>
+    <dc:subject>Science Fiction</dc:subject>
+    <dc:subject>Classics</dc:subject>
+    <dc:language>en</dc:language>
+    <dc:description>&lt;p&gt;Set on the desert planet &lt;b&gt;Arrakis&lt;/b&gt;.&lt;/p&gt;&lt;p&gt;By Frank Herbert&lt;br/&gt;1965&lt;/p&gt;</dc:description>
+    <meta name="cover" content="cover-img"/>
+  </metadata>
+  <manifest>
+    <item id="cover-img" href="images/cover%20art.jpg" media-type="image/jpeg"/>
+    <item id="chapter1" href="chapter1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+</package>`
+
+const epub3Package = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Przedwiośnie</dc:title>
+    <dc:creator id="author">Stefan Żeromski</dc:creator>
+    <dc:creator id="editor">Jan Kowalski</dc:creator>
+    <meta refines="#author" property="role" scheme="marc:relators">aut</meta>
+    <meta refines="#editor" property="role" scheme="marc:relators">edt</meta>
+    <dc:identifier id="uid">urn:uuid:aaaa</dc:identifier>
+    <dc:language>pl</dc:language>
+  </metadata>
+  <manifest>
+    <item id="c" href="cover.png" media-type="image/png" properties="cover-image"/>
+  </manifest>
+</package>`
+
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func TestEPUBParse(t *testing.T) {
+	t.Run("EPUB 2 package", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"mimetype":                   "application/epub+zip",
+			"META-INF/container.xml":     epubContainer,
+			"OEBPS/content.opf":          epub2Package,
+			"OEBPS/images/cover art.jpg": "jpeg bytes",
+		})
+
+		metadata, err := epub.Parse(bytes.NewReader(data), int64(len(data)), 1<<20)
+		require.NoError(t, err)
+		assert.Equal(t, "Dune", metadata.Title)
+		assert.Equal(t, []string{"Frank Herbert"}, metadata.Creators, "illustrators are not authors")
+		assert.Equal(t, "9780441013593", metadata.ISBN.ISBN13())
+		assert.Len(t, metadata.Identifiers, 2)
+		assert.Equal(t, []string{"Science Fiction", "Classics"}, metadata.Subjects)
+		assert.Equal(t, "en", metadata.Language)
+		assert.Equal(t, "Set on the desert planet Arrakis. By Frank Herbert 1965", metadata.Description)
+		assert.Equal(t, []byte("jpeg bytes"), metadata.Cover)
+		assert.Equal(t, "data:image/jpeg;base64,anBlZyBieXRlcw==", metadata.CoverDataURI())
+	})
+
+	t.Run("EPUB 3 package", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf":      epub3Package,
+			"OEBPS/cover.png":        "png bytes",
+		})
+
+		metadata, err := epub.Parse(bytes.NewReader(data), int64(len(data)), 1<<20)
+		require.NoError(t, err)
+		assert.Equal(t, "Przedwiośnie", metadata.Title)
+		assert.Equal(t, []string{"Stefan Żeromski"}, metadata.Creators)
+		assert.Empty(t, metadata.ISBN)
+		assert.Equal(t, "pl", metadata.Language)
+		assert.Equal(t, "image/png", metadata.CoverType)
+	})
+
+	t.Run("Large covers are left out", func(t *testing.T) {
+		data := buildEPUB(t, map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf":      epub3Package,
+			"OEBPS/cover.png":        "png bytes",
+		})
+
+		metadata, err := epub.Parse(bytes.NewReader(data), int64(len(data)), 4)
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Cover)
+		assert.Empty(t, metadata.CoverDataURI())
+	})
+
+	t.Run("Description markup", func(t *testing.T) {
+		original := "<dc:description>&lt;p&gt;Set on the desert planet &lt;b&gt;Arrakis&lt;/b&gt;.&lt;/p&gt;&lt;p&gt;By Frank Herbert&lt;br/&gt;1965&lt;/p&gt;</dc:description>"
+		for _, tc := range []struct {
+			description string
+			want        string
+		}{
+			{"Spice&lt;br&gt;melange", "Spice melange"},
+			{"&lt;&gt;Arrakis", "Arrakis"},
+			{"&lt; &gt;Arrakis", "Arrakis"},
+			{"Dune&lt;", "Dune"},
+		} {
+			data := buildEPUB(t, map[string]string{
+				"META-INF/container.xml": epubContainer,
+				"OEBPS/content.opf":      strings.Replace(epub2Package, original, "<dc:description>"+tc.description+"</dc:description>", 1),
+			})
+
+			metadata, err := epub.Parse(bytes.NewReader(data), int64(len(data)), 1<<20)
+			require.NoError(t, err, tc.description)
+			assert.Equal(t, tc.want, metadata.Description, tc.description)
+		}
+	})
+
+	t.Run("Invalid files", func(t *testing.T) {
+		_, err := epub.Parse(bytes.NewReader([]byte("not a zip")), 9, 1<<20)
+		assert.ErrorIs(t, err, epub.ErrNotEPUB)
+
+		data := buildEPUB(t, map[string]string{"mimetype": "application/epub+zip"})
+		_, err = epub.Parse(bytes.NewReader(data), int64(len(data)), 1<<20)
+		assert.ErrorIs(t, err, epub.ErrNotEPUB)
+
+		data = buildEPUB(t, map[string]string{"META-INF/container.xml": epubContainer})
+		_, err = epub.Parse(bytes.NewReader(data), int64(len(data)), 1<<20)
+		assert.ErrorIs(t, err, epub.ErrMissingPackage)
+	})
+}
@@ -49,8 +49,8 @@ func (m *MockExchangeRequestRepository) Update(exchange *models.ExchangeRequest)
 	return args.Error(0)
 }
 
-func (m *MockExchangeRequestRepository) FindMatchingRequests(userId, requestId, desiredBookId string, offeredBooks []string) ([]*models.ExchangeRequest, error) {
-	args := m.Called(userId, requestId, desiredBookId, offeredBooks)
+func (m *MockExchangeRequestRepository) FindMatchCandidates(request *models.ExchangeRequest) ([]*models.ExchangeRequest, error) {
+	args := m.Called(request)
 	return args.Get(0).([]*models.ExchangeRequest), args.Error(1)
 }
 
@@ -84,6 +84,11 @@ func (m *MockExchangeRequestRepository) GetActiveExchangeRequestsByBookID(id str
 	return args.Get(0).([]*models.ExchangeRequest), args.Error(1)
 }
 
+func (m *MockExchangeRequestRepository) GetActiveOffering(bookIDs []string, excludeUserID string) ([]*models.ExchangeRequest, error) {
+	args := m.Called(bookIDs, excludeUserID)
+	return args.Get(0).([]*models.ExchangeRequest), args.Error(1)
+}
+
 func (m *MockExchangeRequestRepository) ClearExpectedCalls() {
 	m.Mock.ExpectedCalls = nil
 }
@@ -226,3 +226,80 @@ func TestExchangeMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestExchangeRequest_MatchWith(t *testing.T) {
+	duneGoogle := models.Book{ID: "g1", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593"}
+	duneOpenLibrary := models.Book{ID: "OL1M", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593"}
+	duneDeluxe := models.Book{ID: "g2", Title: "Dune: Deluxe Edition", Authors: "Herbert, Frank"}
+	solaris := models.Book{ID: "g3", Title: "Solaris", Authors: "Stanisław Lem"}
+	solarisOther := models.Book{ID: "g4", Title: "Solaris", Authors: "Stanislaw Lem"}
+
+	request := func(desired models.Book, anyEdition bool, offered ...models.Book) *models.ExchangeRequest {
+		offeredBooks := make([]models.OfferedBook, len(offered))
+		for i, book := range offered {
+			offeredBooks[i] = models.OfferedBook{BookID: book.ID, Book: book}
+		}
+		return &models.ExchangeRequest{DesiredBookID: desired.ID, DesiredBook: desired, AnyEdition: anyEdition, OfferedBooks: offeredBooks}
+	}
+
+	tests := []struct {
+		name          string
+		request       *models.ExchangeRequest
+		other         *models.ExchangeRequest
+		expectedMatch bool
+		expectedRule  models.MatchRule
+	}{
+		{
+			name:          "Exact books",
+			request:       request(duneGoogle, false, solaris),
+			other:         request(solaris, false, duneGoogle),
+			expectedMatch: true,
+			expectedRule:  models.MatchRuleExact,
+		},
+		{
+			name:          "Different edition without any edition",
+			request:       request(duneGoogle, false, solaris),
+			other:         request(solaris, false, duneOpenLibrary),
+			expectedMatch: false,
+		},
+		{
+			name:          "Same ISBN with any edition",
+			request:       request(duneGoogle, true, solaris),
+			other:         request(solaris, false, duneOpenLibrary),
+			expectedMatch: true,
+			expectedRule:  models.MatchRuleISBN,
+		},
+		{
+			name:          "Loosest rule of both sides is recorded",
+			request:       request(duneGoogle, true, solaris),
+			other:         request(solarisOther, true, duneDeluxe),
+			expectedMatch: true,
+			expectedRule:  models.MatchRuleTitleAuthor,
+		},
+		{
+			name:          "Other side only accepts the exact edition",
+			request:       request(duneGoogle, true, solaris),
+			other:         request(solarisOther, false, duneDeluxe),
+			expectedMatch: false,
+		},
+		{
+			name:          "Exact edition preferred over equivalents",
+			request:       request(duneGoogle, true, solaris),
+			other:         request(solaris, false, duneDeluxe, duneGoogle),
+			expectedMatch: true,
+			expectedRule:  models.MatchRuleExact,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := tt.request.MatchWith(tt.other)
+			assert.Equal(t, tt.expectedMatch, ok)
+			assert.Equal(t, tt.expectedRule, rule)
+
+			reverseRule, reverseOk := tt.other.MatchWith(tt.request)
+			assert.Equal(t, ok, reverseOk, "matching is symmetric")
+			assert.Equal(t, rule, reverseRule)
+		})
+	}
+}
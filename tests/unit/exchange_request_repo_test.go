@@ -115,6 +115,98 @@ func TestExchangeRequestRepository(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, gotMatch)
 	})
+
+	t.Run("GetActiveOffering", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Book{ID: "offered", Title: "Offered Book"}).Error)
+		offering := &models.ExchangeRequest{
+			UserGoogleId:  user.GoogleId,
+			DesiredBookID: "book123",
+			Status:        models.ExchangeRequestStatusActive,
+			OfferedBooks:  []models.OfferedBook{{BookID: "offered"}},
+		}
+		require.NoError(t, repo.Create(offering))
+
+		got, err := repo.GetActiveOffering([]string{"offered"}, "someone-else")
+		assert.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, offering.ID, got[0].ID)
+		assert.Equal(t, user.Username, got[0].User.Username)
+
+		got, err = repo.GetActiveOffering([]string{"offered"}, user.GoogleId)
+		assert.NoError(t, err)
+		assert.Empty(t, got, "the user's own offers are left out")
+	})
+
+	t.Run("FindMatchCandidates", func(t *testing.T) {
+		bookRepo := repositories.NewBookRepository(db)
+		for _, book := range []*models.Book{
+			{ID: "dune-hc", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593"},
+			{ID: "dune-pb", Title: "Dune: Deluxe Edition", Authors: "Herbert, Frank"},
+			{ID: "solaris", Title: "Solaris", Authors: "Stanisław Lem"},
+			{ID: "eden", Title: "Eden", Authors: "Stanisław Lem"},
+		} {
+			require.NoError(t, bookRepo.Create(book))
+		}
+		require.NoError(t, db.Create(&models.User{GoogleId: "user456", Username: "other", Email: "other@example.com"}).Error)
+
+		ours := &models.ExchangeRequest{UserGoogleId: user.GoogleId, DesiredBookID: "dune-hc", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "solaris"}}}
+		fits := &models.ExchangeRequest{UserGoogleId: "user456", DesiredBookID: "solaris", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "dune-pb"}}}
+		wantsOther := &models.ExchangeRequest{UserGoogleId: "user456", DesiredBookID: "eden", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "dune-pb"}}}
+		offersOther := &models.ExchangeRequest{UserGoogleId: "user456", DesiredBookID: "solaris", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "eden"}}}
+		for _, request := range []*models.ExchangeRequest{ours, fits, wantsOther, offersOther} {
+			require.NoError(t, repo.Create(request))
+		}
+
+		request, err := repo.GetByID(fmt.Sprintf("%d", ours.ID))
+		require.NoError(t, err)
+		got, err := repo.FindMatchCandidates(request)
+		assert.NoError(t, err)
+		require.Len(t, got, 1, "only requests with editions of the books are loaded")
+		assert.Equal(t, fits.ID, got[0].ID)
+		assert.Equal(t, "dune-pb", got[0].OfferedBooks[0].Book.ID)
+	})
+
+	t.Run("FindMatchCandidates across works", func(t *testing.T) {
+		bookRepo := repositories.NewBookRepository(db)
+		for _, book := range []*models.Book{
+			{ID: "diuna", Title: "Diuna", Authors: "Frank Herbert", ProviderWorkID: "openlibrary:OL893415W"},
+			{ID: "dune-google", Title: "Dune", Authors: "Frank Herbert"},
+			{ID: "dune-ol", Title: "Dune", Authors: "Herbert, Frank", ProviderWorkID: "openlibrary:OL893415W"},
+			{ID: "fiasco", Title: "Fiasco", Authors: "Stanisław Lem"},
+		} {
+			require.NoError(t, bookRepo.Create(book))
+		}
+		google, err := bookRepo.Get("dune-google")
+		require.NoError(t, err)
+		openLibrary, err := bookRepo.Get("dune-ol")
+		require.NoError(t, err)
+		require.NotEqual(t, *google.WorkID, *openLibrary.WorkID, "the editions are grouped under different works")
+		require.NoError(t, db.Create(&models.User{GoogleId: "user789", Username: "third", Email: "third@example.com"}).Error)
+
+		ours := &models.ExchangeRequest{UserGoogleId: user.GoogleId, DesiredBookID: "dune-google", Status: models.ExchangeRequestStatusActive,
+			AnyEdition: true, OfferedBooks: []models.OfferedBook{{BookID: "fiasco"}}}
+		sameTitle := &models.ExchangeRequest{UserGoogleId: "user789", DesiredBookID: "fiasco", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "dune-ol"}}}
+		translation := &models.ExchangeRequest{UserGoogleId: "user789", DesiredBookID: "fiasco", Status: models.ExchangeRequestStatusActive,
+			OfferedBooks: []models.OfferedBook{{BookID: "diuna"}}}
+		for _, request := range []*models.ExchangeRequest{ours, sameTitle, translation} {
+			require.NoError(t, repo.Create(request))
+		}
+
+		request, err := repo.GetByID(fmt.Sprintf("%d", ours.ID))
+		require.NoError(t, err)
+		got, err := repo.FindMatchCandidates(request)
+		assert.NoError(t, err)
+		require.Len(t, got, 1, "candidates follow the rules of models.EditionEquivalence")
+		assert.Equal(t, sameTitle.ID, got[0].ID)
+		rule, ok := request.MatchWith(got[0])
+		assert.True(t, ok)
+		assert.Equal(t, models.MatchRuleTitleAuthor, rule)
+	})
 }
 
 func seedExchangeRequestTestData(t *testing.T, db *gorm.DB) (*models.User, *models.Book, *models.ExchangeRequest) {
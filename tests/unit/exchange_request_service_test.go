@@ -35,7 +35,7 @@ func TestExchangeService_Request(t *testing.T) {
 		mockRepo.On("Create", mock.Anything).Return(nil)
 		mockRepo.On("Get", mock.Anything, userID).Return(exchange, nil)
 
-		result, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude)
+		result, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude, false)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -46,7 +46,7 @@ func TestExchangeService_Request(t *testing.T) {
 
 	t.Run("Create -- Validation Error", func(t *testing.T) {
 		mockRepo.On("Create", mock.Anything).Return(models.ErrExchangeRequestNoOfferedBooksProvided)
-		_, err := service.Create(userID, userEmail, desiredBookID, []string{}, latitude, longitude)
+		_, err := service.Create(userID, userEmail, desiredBookID, []string{}, latitude, longitude, false)
 		assert.Error(t, err)
 		mockRepo.ClearExpectedCalls()
 	})
@@ -55,7 +55,7 @@ func TestExchangeService_Request(t *testing.T) {
 		mockRepo.On("Create", mock.Anything).Return(errors.New("database error"))
 		mockRepo.On("Get", mock.Anything, userID).Return(exchange, nil)
 
-		_, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude)
+		_, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude, false)
 
 		assert.Error(t, err)
 		assert.Equal(t, "database error", err.Error())
@@ -67,7 +67,7 @@ func TestExchangeService_Request(t *testing.T) {
 		mockRepo.On("Create", mock.Anything).Return(nil)
 		mockRepo.On("Get", mock.Anything, userID).Return(&models.ExchangeRequest{}, errors.New("not found"))
 
-		_, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude)
+		_, err := service.Create(userID, userEmail, desiredBookID, userBookIDs, latitude, longitude, false)
 
 		assert.Error(t, err)
 		assert.Equal(t, "not found", err.Error())
@@ -142,7 +142,7 @@ func TestExchangeService_Matches(t *testing.T) {
 
 	t.Run("Successful Match Creation", func(t *testing.T) {
 		mockRepo.On("CreateMatch", expectedMatch).Return(nil)
-		match, err := service.CreateMatch(request, otherRequests[0])
+		match, err := service.CreateMatch(request, otherRequests[0], models.MatchRuleExact)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedMatch, match)
@@ -200,7 +200,28 @@ func TestExchangeService_Matches(t *testing.T) {
 
 	t.Run("Successful Match Finding", func(t *testing.T) {
 		mockRepo.On("Get", requestID, userID).Return(request, nil)
-		mockRepo.On("FindMatchingRequests", requestID, userID, request.DesiredBookID, getOfferedBookIDs(request.OfferedBooks)).Return(otherRequests, nil)
+		mockRepo.On("FindMatchCandidates", request).Return(otherRequests, nil)
+		mockRepo.On("Update", request).Return(nil)
+		mockRepo.On("CreateMatch", expectedMatch).Return(nil, nil)
+
+		matchedRequests, err := service.FindMatchingRequests(requestID, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, otherRequests, matchedRequests)
+		mockRepo.AssertExpectations(t)
+		mockRepo.ClearExpectedCalls()
+	})
+
+	t.Run("Candidates Without Matching Books Are Skipped", func(t *testing.T) {
+		unrelated := &models.ExchangeRequest{
+			ID:            3,
+			UserGoogleId:  "user789",
+			DesiredBookID: "bookC",
+			OfferedBooks:  []models.OfferedBook{{BookID: "bookA"}},
+			Status:        models.ExchangeRequestStatusActive,
+		}
+		mockRepo.On("Get", requestID, userID).Return(request, nil)
+		mockRepo.On("FindMatchCandidates", request).Return([]*models.ExchangeRequest{unrelated, otherRequests[0]}, nil)
 		mockRepo.On("Update", request).Return(nil)
 		mockRepo.On("CreateMatch", expectedMatch).Return(nil, nil)
 
@@ -249,6 +270,7 @@ func seedDataRequestsMatches(t *testing.T) (*models.ExchangeRequest, []*models.E
 		Request1Decision:         models.MatchDecisionPending,
 		Request2Decision:         models.MatchDecisionPending,
 		Distance:                 expectedDistance,
+		MatchRule:                models.MatchRuleExact,
 	}
 	return request, otherRequests, expectedMatch
 }
@@ -439,11 +461,3 @@ func seedDataFilterMatches(t *testing.T) []*models.ExchangeMatch {
 		{ExchangeRequestID: 1, MatchedExchangeRequestID: 5, Distance: 15.0},
 	}
 }
-
-func getOfferedBookIDs(offeredBooks []models.OfferedBook) []string {
-	ids := make([]string, len(offeredBooks))
-	for i, book := range offeredBooks {
-		ids[i] = book.BookID
-	}
-	return ids
-}
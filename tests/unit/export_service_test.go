@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/goodreads"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "user1", Username: "user1", Email: "user1@example.com"}).Error)
+	bookRepo := repositories.NewBookRepository(db)
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "dune", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593", ISBN10: "0441013597", Pages: 658,
+		Language: "en", ProviderWorkID: "openlibrary:OL893415W", Series: &models.SeriesInfo{Name: "Dune Chronicles", Position: 1},
+		Genres: []models.Genre{{Name: "Fiction / Science Fiction"}}}))
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "road", Title: "The Road", Authors: "Cormac McCarthy"}))
+
+	userBookRepo := repositories.NewUserBookRepository(db)
+	dune := &models.UserBook{UserGoogleId: "user1", BookID: "dune"}
+	require.NoError(t, userBookRepo.Create(dune))
+	require.NoError(t, userBookRepo.Create(&models.UserBook{UserGoogleId: "user1", BookID: "road"}))
+
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repositories.NewProgressRepository(db).Create(models.ReadingProgress{
+		UserBookID:  dune.ID,
+		BookTitle:   "Dune",
+		StartDate:   start,
+		EndDate:     end,
+		TotalPages:  658,
+		CurrentPage: 658,
+		Completed:   true,
+		DailyProgress: []models.DailyProgressLog{
+			{UserBookID: dune.ID, Date: start, PagesRead: 300, TotalPages: 658, TargetPages: 329, Comment: "the spice must flow"},
+			{UserBookID: dune.ID, Date: end, PagesRead: 358, TotalPages: 658, TargetPages: 329, Completed: true},
+		},
+	}))
+
+	service := services.NewExportService(userBookRepo)
+
+	t.Run("CSV can be imported back", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, service.WriteCSV("user1", &buf))
+
+		rows, err := goodreads.Parse(&buf)
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+
+		assert.Equal(t, "Dune", rows[0].Title)
+		assert.Equal(t, "9780441013593", rows[0].ISBN13)
+		assert.Equal(t, goodreads.ShelfRead, rows[0].ExclusiveShelf)
+		require.NotNil(t, rows[0].DateRead)
+		assert.Equal(t, "2023-03-02", rows[0].DateRead.Format(time.DateOnly))
+
+		assert.Equal(t, goodreads.ShelfToRead, rows[1].ExclusiveShelf)
+		assert.Nil(t, rows[1].DateRead)
+	})
+
+	t.Run("JSON keeps the reading logs", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, service.WriteJSON("user1", &buf))
+
+		var library struct {
+			Version int `json:"version"`
+			Books   []struct {
+				StatusChanges []struct {
+					To models.UserBookStatus `json:"to"`
+				} `json:"status_changes"`
+				Book struct {
+					ID             string             `json:"id"`
+					WorkID         *uint              `json:"work_id"`
+					ProviderWorkID string             `json:"provider_work_id"`
+					Language       string             `json:"language"`
+					Series         *models.SeriesInfo `json:"series"`
+					Genres         []string           `json:"genres"`
+				} `json:"book"`
+				Progress *struct {
+					Completed bool `json:"completed"`
+					Logs      []struct {
+						PagesRead int    `json:"pages_read"`
+						Comment   string `json:"comment"`
+					} `json:"logs"`
+				} `json:"progress"`
+			} `json:"books"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &library))
+		assert.Equal(t, 2, library.Version)
+		require.Len(t, library.Books, 2)
+
+		dune := library.Books[0]
+		assert.Equal(t, "dune", dune.Book.ID)
+		assert.NotNil(t, dune.Book.WorkID)
+		assert.Equal(t, "openlibrary:OL893415W", dune.Book.ProviderWorkID)
+		assert.Equal(t, "en", dune.Book.Language)
+		assert.Equal(t, &models.SeriesInfo{Name: "Dune Chronicles", Position: 1}, dune.Book.Series)
+		assert.Equal(t, []string{"Fiction / Science Fiction"}, dune.Book.Genres)
+		require.NotEmpty(t, dune.StatusChanges)
+		assert.Equal(t, models.UserBookStatusRead, dune.StatusChanges[len(dune.StatusChanges)-1].To, "the shelf history is kept in order")
+		assert.Nil(t, library.Books[1].Book.Series)
+		require.NotNil(t, library.Books[0].Progress)
+		assert.True(t, library.Books[0].Progress.Completed)
+		require.Len(t, library.Books[0].Progress.Logs, 2)
+		assert.Equal(t, "the spice must flow", library.Books[0].Progress.Logs[0].Comment)
+		assert.Equal(t, 358, library.Books[0].Progress.Logs[1].PagesRead)
+
+		assert.Nil(t, library.Books[1].Progress)
+	})
+
+	t.Run("Empty library", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, service.WriteJSON("nobody", &buf))
+		assert.JSONEq(t, `[]`, string(mustField(t, buf.Bytes(), "books")))
+
+		buf.Reset()
+		require.NoError(t, service.WriteCSV("nobody", &buf))
+		rows, err := goodreads.Parse(&buf)
+		require.NoError(t, err, "the header is written even without books")
+		assert.Empty(t, rows)
+	})
+}
+
+func mustField(t *testing.T, data []byte, field string) json.RawMessage {
+	t.Helper()
+	fields := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	return fields[field]
+}
@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenrePath(t *testing.T) {
+	assert.Equal(t, []string{"Fiction", "Science Fiction", "Space Opera"}, models.GenrePath("Fiction / Science Fiction / Space Opera"))
+	assert.Equal(t, []string{"Fiction", "Science Fiction"}, models.GenrePath("Fiction / Science  Fiction / General"))
+	assert.Equal(t, []string{"General"}, models.GenrePath("General"))
+	assert.Empty(t, models.GenrePath(" / "))
+	assert.Equal(t, "sci fi and fantasy", models.GenreKey(" Sci-Fi & Fantasy"))
+	assert.Equal(t, []string{"Fiction", "Fantasy"}, models.Genre{Name: "Fantasy", Path: "Fiction / Fantasy"}.Ancestors())
+}
+
+func TestGenreHierarchy(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// genres saved verbatim before the hierarchy existed
+	legacy := []models.Genre{{Name: "Fiction / Science Fiction / Space Opera"}, {Name: "science fiction"}, {Name: "Mars (Planet) -- Fiction"}}
+	require.NoError(t, db.Create(&legacy).Error)
+	require.NoError(t, db.Create(&models.Book{ID: "old", Title: "Old Book", Genres: legacy[:2]}).Error)
+	require.NoError(t, db.Create(&models.User{GoogleId: "anna", Username: "anna", Email: "anna@example.com", Genres: legacy[1:2]}).Error)
+
+	require.NoError(t, repositories.Migrate(db))
+	repo := repositories.NewBookRepository(db)
+	genreByName := func(name string) models.Genre {
+		var genre models.Genre
+		require.NoError(t, db.First(&genre, "name = ?", name).Error)
+		return genre
+	}
+
+	t.Run("Saved genres are merged into the hierarchy", func(t *testing.T) {
+		var count int64
+		db.Model(&models.Genre{}).Where("name IN ?", []string{legacy[0].Name, legacy[1].Name}).Count(&count)
+		assert.Zero(t, count)
+
+		book, err := repo.Get("old")
+		require.NoError(t, err)
+		names := []string{}
+		for _, genre := range book.Genres {
+			names = append(names, genre.Name)
+		}
+		assert.ElementsMatch(t, []string{"Space Opera", "Science Fiction"}, names)
+
+		user, err := repositories.NewUserRepository(db).GetByGoogleID("anna")
+		require.NoError(t, err)
+		require.Len(t, user.Genres, 1)
+		assert.Equal(t, "Fiction / Science Fiction", user.Genres[0].Path)
+
+		assert.Equal(t, "Mars (Planet) -- Fiction", genreByName("Mars (Planet) -- Fiction").Path, "unknown genres become roots")
+	})
+
+	t.Run("Categories map onto canonical genres", func(t *testing.T) {
+		require.NoError(t, repo.Create(&models.Book{ID: "a", Title: "A", Genres: []models.Genre{
+			{Name: "Fiction / Science Fiction / Military"},
+			{Name: "SCI-FI"},
+			{Name: "Science Fiction"},
+		}}))
+
+		book, err := repo.Get("a")
+		require.NoError(t, err)
+		require.Len(t, book.Genres, 2, "aliases of the same genre are saved once")
+
+		military := genreByName("Military")
+		assert.Equal(t, "Fiction / Science Fiction / Military", military.Path)
+		assert.Equal(t, genreByName("Science Fiction").ID, *military.ParentID)
+
+		require.NoError(t, repo.Create(&models.Book{ID: "b", Title: "B", Genres: []models.Genre{{Name: "Fiction / Science Fiction / Military"}}}))
+		var count int64
+		db.Model(&models.Genre{}).Where("name = ?", "Military").Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Levels are resolved under their parent", func(t *testing.T) {
+		require.NoError(t, repo.Create(&models.Book{ID: "c", Title: "C", Genres: []models.Genre{{Name: "Juvenile Fiction / Animals / Dogs"}}}))
+		require.NoError(t, repo.Create(&models.Book{ID: "d", Title: "D", Genres: []models.Genre{{Name: "Pets / Dogs"}}}))
+		require.NoError(t, repo.Create(&models.Book{ID: "e", Title: "E", Genres: []models.Genre{{Name: "Pets / Dogs / General"}}}))
+
+		var dogs []models.Genre
+		require.NoError(t, db.Where("name = ?", "Dogs").Order("id").Find(&dogs).Error)
+		require.Len(t, dogs, 2, "a leaf name shared by two categories gives two genres")
+		assert.Equal(t, "Juvenile Fiction / Animals / Dogs", dogs[0].Path)
+		assert.Equal(t, "Pets / Dogs", dogs[1].Path)
+		assert.Equal(t, genreByName("Pets").ID, *dogs[1].ParentID)
+
+		for id, path := range map[string]string{"c": dogs[0].Path, "d": dogs[1].Path, "e": dogs[1].Path} {
+			book, err := repo.Get(id)
+			require.NoError(t, err)
+			require.Len(t, book.Genres, 1)
+			assert.Equal(t, path, book.Genres[0].Path, id)
+		}
+	})
+
+	t.Run("GetByGenre finds the books of descendant genres", func(t *testing.T) {
+		books, err := repo.GetByGenre("Fiction")
+		require.NoError(t, err)
+		assert.Len(t, books, 3)
+
+		books, err = repo.GetByGenre("Military")
+		require.NoError(t, err)
+		assert.Len(t, books, 2)
+
+		books, err = repo.GetByGenre("Cooking")
+		require.NoError(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("The profile offers the top two levels", func(t *testing.T) {
+		genres, err := repositories.NewUserRepository(db).GetAllGenres()
+		require.NoError(t, err)
+
+		paths := []string{}
+		for _, genre := range genres {
+			paths = append(paths, genre.Path)
+		}
+		assert.Contains(t, paths, "Fiction / Science Fiction")
+		assert.NotContains(t, paths, "Fiction / Science Fiction / Military")
+		assert.IsIncreasing(t, paths)
+	})
+}
+
+func TestRecommendRankGenreHierarchy(t *testing.T) {
+	candidates := []*models.Book{
+		{ID: "opera", Title: "Opera", Genres: []models.Genre{{Name: "Space Opera", Path: "Fiction / Science Fiction / Space Opera"}}},
+		{ID: "cookbook", Title: "Cookbook", Genres: []models.Genre{{Name: "Cooking", Path: "Cooking"}}},
+	}
+
+	recommendations := recommend.Rank(nil, []models.Genre{{Name: "Fiction", Path: "Fiction"}}, candidates)
+
+	require.Len(t, recommendations, 2)
+	assert.Equal(t, "opera", recommendations[0].Book.ID)
+	assert.Greater(t, recommendations[0].GenreScore, 0.0)
+	assert.Equal(t, "Because you like Fiction", recommendations[0].Reason.String())
+	assert.Zero(t, recommendations[1].GenreScore)
+}
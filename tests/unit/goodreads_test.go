@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/goodreads"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goodreadsExport = "\ufeffBook Id,Title,Author,Author l-f,ISBN,ISBN13,My Rating,Number of Pages,Date Read,Date Added,Exclusive Shelf,My Review\n" +
+	`234225,"Dune (Dune Chronicles, #1)",Frank Herbert,"Herbert, Frank","=""0441013597""","=""9780441013593""",5,658,2023/04/02,2023/03/01,read,"Great.` + "\n" + `Really great."` + "\n" +
+	`1,The Road,Cormac McCarthy,"McCarthy, Cormac","=""""","=""""",0,,,2024/01/10,to-read,` + "\n" +
+	`2,,Nobody,,,,0,,,,to-read,` + "\n"
+
+func TestGoodreadsParse(t *testing.T) {
+	t.Run("Reads the export columns", func(t *testing.T) {
+		rows, err := goodreads.Parse(strings.NewReader(goodreadsExport))
+		require.NoError(t, err)
+		require.Len(t, rows, 2, "rows without a title are skipped")
+
+		dune := rows[0]
+		assert.Equal(t, 2, dune.Line)
+		assert.Equal(t, "Dune (Dune Chronicles, #1)", dune.Title)
+		assert.Equal(t, "Dune", dune.SearchTitle())
+		assert.Equal(t, "0441013597", dune.ISBN)
+		assert.Equal(t, "9780441013593", dune.ISBN13)
+		assert.Equal(t, 658, dune.Pages)
+		assert.Equal(t, 5, dune.Rating)
+		assert.Equal(t, goodreads.ShelfRead, dune.ExclusiveShelf)
+		require.NotNil(t, dune.DateRead)
+		assert.Equal(t, "2023-04-02", dune.DateRead.Format("2006-01-02"))
+		require.NotNil(t, dune.DateAdded)
+
+		road := rows[1]
+		assert.Equal(t, 4, road.Line, "the multi-line review moves the next row")
+		assert.Empty(t, road.ISBN)
+		assert.Empty(t, road.ISBN13)
+		assert.Nil(t, road.DateRead)
+		assert.Equal(t, goodreads.ShelfToRead, road.ExclusiveShelf)
+	})
+
+	t.Run("Rejects files that are not an export", func(t *testing.T) {
+		_, err := goodreads.Parse(strings.NewReader("name,isbn\nDune,123\n"))
+		assert.ErrorIs(t, err, goodreads.ErrMissingColumn)
+
+		_, err = goodreads.Parse(strings.NewReader(""))
+		assert.ErrorIs(t, err, goodreads.ErrEmptyFile)
+	})
+
+	t.Run("Falls back to the last name first column", func(t *testing.T) {
+		rows, err := goodreads.Parse(strings.NewReader("Title,Author l-f\nDune,\"Herbert, Frank\"\nThe Iliad,Homer\n"))
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "Frank Herbert", rows[0].Author)
+		assert.Equal(t, "Homer", rows[1].Author)
+	})
+}
+
+func TestGoodreadsWriter(t *testing.T) {
+	dateRead := time.Date(2023, 4, 2, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	writer := goodreads.NewWriter(&buf)
+	require.NoError(t, writer.Write(goodreads.Row{
+		Title:             "Good Omens",
+		Author:            "Terry Pratchett",
+		AdditionalAuthors: "Neil Gaiman",
+		ISBN:              "0441013597",
+		ISBN13:            "9780441013593",
+		Pages:             412,
+		DateRead:          &dateRead,
+		ExclusiveShelf:    goodreads.ShelfRead,
+	}))
+	require.NoError(t, writer.Flush())
+
+	assert.Contains(t, buf.String(), `"Pratchett, Terry"`)
+	assert.Contains(t, buf.String(), `"=""0441013597"""`, "isbns are quoted like in a Goodreads export")
+
+	rows, err := goodreads.Parse(&buf)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Good Omens", rows[0].Title)
+	assert.Equal(t, "Neil Gaiman", rows[0].AdditionalAuthors)
+	assert.Equal(t, "9780441013593", rows[0].ISBN13)
+	assert.Equal(t, 412, rows[0].Pages)
+	require.NotNil(t, rows[0].DateRead)
+	assert.True(t, dateRead.Equal(*rows[0].DateRead))
+}
@@ -0,0 +1,34 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type healthStub map[string]string
+
+func (h healthStub) Health() map[string]string {
+	return h
+}
+
+func TestHealthHandler(t *testing.T) {
+	database := healthStub{"status": "up", "open_connections": "3", "wait_count": "0"}
+	providers := healthStub{"status": "degraded", "breaker www.googleapis.com": "open"}
+
+	e := echo.New()
+	handlers.NewHealthHandler(database, providers, healthStub{"hits": "12", "misses": "4"}).RegisterRoutes(e)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"degraded"`)
+	assert.Contains(t, rec.Body.String(), `"database":"up"`)
+	assert.Contains(t, rec.Body.String(), `"breaker www.googleapis.com":"open"`)
+	assert.Contains(t, rec.Body.String(), `"cache":{"hits":"12","misses":"4"}`)
+	assert.NotContains(t, rec.Body.String(), "open_connections", "the pool stats are not public")
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightTags(t *testing.T) {
+	assert.Equal(t, []string{"love", "war and peace"}, models.ParseTags(" Love,war   and Peace,, love "))
+	assert.Empty(t, models.ParseTags(" , "))
+
+	page := 0
+	assert.ErrorIs(t, (&models.Highlight{Text: " "}).Validate(), models.ErrHighlightTextEmpty)
+	assert.ErrorIs(t, (&models.Highlight{Text: "Quote", Page: &page}).Validate(), models.ErrHighlightInvalidPage)
+	assert.NoError(t, (&models.Highlight{Text: "Quote"}).Validate())
+}
+
+func TestHighlightService(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "anna", Username: "anna", Email: "anna@example.com"}).Error)
+	require.NoError(t, db.Create(&models.User{GoogleId: "bob", Username: "bob", Email: "bob@example.com"}).Error)
+	require.NoError(t, db.Create(&models.Book{ID: "solaris", Title: "Solaris"}).Error)
+	require.NoError(t, db.Create(&models.Book{ID: "dune", Title: "Dune"}).Error)
+	solaris := &models.UserBook{UserGoogleId: "anna", BookID: "solaris"}
+	dune := &models.UserBook{UserGoogleId: "anna", BookID: "dune"}
+	bobs := &models.UserBook{UserGoogleId: "bob", BookID: "dune"}
+	for _, userBook := range []*models.UserBook{solaris, dune, bobs} {
+		require.NoError(t, db.Create(userBook).Error)
+	}
+	progress := &models.ReadingProgress{UserBookID: solaris.ID, TotalPages: 200, DailyProgress: []models.DailyProgressLog{
+		{UserBookID: solaris.ID, Date: time.Now(), TotalPages: 200},
+	}}
+	require.NoError(t, db.Create(progress).Error)
+	logID := progress.DailyProgress[0].ID
+
+	require.NoError(t, repositories.Migrate(db))
+	svc := services.NewHighlightService(
+		repositories.NewHighlightRepository(db),
+		repositories.NewUserBookRepository(db),
+		repositories.NewProgressRepository(db),
+	)
+	annaBook := fmt.Sprint(solaris.ID)
+
+	page := 42
+	ocean, err := svc.Create("anna", annaBook, &models.Highlight{
+		Text: " We don't want to conquer the cosmos. ", Note: "Snaut", Tags: "Contact, humanity", Page: &page, DailyProgressLogID: &logID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "We don't want to conquer the cosmos.", ocean.Text)
+	assert.Equal(t, "contact, humanity", ocean.Tags)
+	_, err = svc.Create("anna", fmt.Sprint(dune.ID), &models.Highlight{Text: "Fear is the mind-killer.", Tags: "fear"})
+	require.NoError(t, err)
+	_, err = svc.Create("bob", fmt.Sprint(bobs.ID), &models.Highlight{Text: "Fear is the mind-killer.", Tags: "fear"})
+	require.NoError(t, err)
+
+	t.Run("Highlights stay with the owner's books", func(t *testing.T) {
+		_, err := svc.Create("bob", annaBook, &models.Highlight{Text: "Mine now"})
+		assert.ErrorIs(t, err, models.ErrHighlightNotOwner)
+		_, err = svc.Create("anna", fmt.Sprint(dune.ID), &models.Highlight{Text: "Wrong day", DailyProgressLogID: &logID})
+		assert.ErrorIs(t, err, models.ErrHighlightLogNotOfBook)
+		_, err = svc.Update("bob", fmt.Sprint(ocean.ID), &models.Highlight{Text: "Changed"})
+		assert.ErrorIs(t, err, models.ErrHighlightNotOwner)
+		assert.ErrorIs(t, svc.Delete("bob", fmt.Sprint(ocean.ID)), models.ErrHighlightNotOwner)
+		assert.Error(t, svc.Delete("bob", "0 OR 1=1"))
+		_, err = svc.Get("bob", "abc")
+		assert.Error(t, err)
+		_, err = svc.GetByUserBook("bob", annaBook)
+		assert.ErrorIs(t, err, models.ErrHighlightNotOwner)
+	})
+
+	t.Run("Search covers the text, the note and the tags of the user's highlights", func(t *testing.T) {
+		for query, expected := range map[string]int{"cosmos": 1, "snaut": 1, "humanity": 1, "fear": 1, "": 2, "kelvin": 0} {
+			highlights, err := svc.Search("anna", query)
+			require.NoError(t, err)
+			assert.Len(t, highlights, expected, query)
+		}
+	})
+
+	t.Run("Edits are searchable", func(t *testing.T) {
+		updated, err := svc.Update("anna", fmt.Sprint(ocean.ID), &models.Highlight{Text: "We need mirrors.", Note: "Snaut"})
+		require.NoError(t, err)
+		assert.Nil(t, updated.Page)
+		assert.Nil(t, updated.DailyProgressLog)
+
+		highlights, err := svc.Search("anna", "mirrors")
+		require.NoError(t, err)
+		require.Len(t, highlights, 1)
+		assert.Equal(t, "Solaris", highlights[0].UserBook.Book.Title)
+		highlights, err = svc.Search("anna", "cosmos")
+		require.NoError(t, err)
+		assert.Empty(t, highlights)
+	})
+
+	t.Run("Deleted highlights are gone", func(t *testing.T) {
+		require.NoError(t, svc.Delete("anna", fmt.Sprint(ocean.ID)))
+		highlights, err := svc.GetByUserBook("anna", annaBook)
+		require.NoError(t, err)
+		assert.Empty(t, highlights)
+		highlights, err = svc.Search("anna", "mirrors")
+		require.NoError(t, err)
+		assert.Empty(t, highlights)
+	})
+}
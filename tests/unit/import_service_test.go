@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportService(t *testing.T) {
+	ctx := context.Background()
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	// the import runs in its own goroutine, every connection to :memory: would open a new empty database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "user1", Username: "user1", Email: "user1@example.com"}).Error)
+
+	bookRepo := repositories.NewBookRepository(db)
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "dune", Title: "Dune", Authors: "Frank Herbert", ISBN13: "9780441013593", ISBN10: "0441013597"}))
+
+	provider := new(MockBookProvider)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("The Road", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Return([]*models.Book{{ID: "road", Title: "The Road", Authors: "Cormac McCarthy", Pages: 287}}, nil)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("Solaris", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Return([]*models.Book{
+			{ID: "solaris-1", Title: "Solaris", Authors: "Someone Else"},
+			{ID: "solaris-2", Title: "Solaris: a novel", Authors: "Another Author"},
+		}, nil)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("Unknown Book", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Return([]*models.Book{}, nil)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("Broken Book", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Run(func(mock.Arguments) { panic("provider bug") }).
+		Return([]*models.Book{}, nil)
+
+	bookService := services.NewBookService(bookRepo).WithProvider(provider)
+	userBookRepo := repositories.NewUserBookRepository(db)
+	service := services.NewImportService(
+		repositories.NewImportRepository(db),
+		bookService,
+		bookRepo,
+		userBookRepo,
+		repositories.NewProgressRepository(db),
+	)
+
+	export := []byte("Book Id,Title,Author,ISBN,ISBN13,Number of Pages,Date Read,Date Added,Exclusive Shelf\n" +
+		`1,"Dune (Dune Chronicles, #1)",Frank Herbert,"=""0441013597""","=""9780441013593""",658,2023/04/02,2023/03/01,read` + "\n" +
+		`2,The Road,Cormac McCarthy,"=""""","=""""",,,,to-read` + "\n" +
+		`3,Solaris,Stanisław Lem,,,,,,to-read` + "\n" +
+		`4,Unknown Book,Nobody,,,,,,to-read` + "\n")
+
+	waitForJob := func(t *testing.T, id uint) *models.ImportJob {
+		var job *models.ImportJob
+		require.Eventually(t, func() bool {
+			var err error
+			job, err = service.Get(jobID(id), "user1")
+			return err == nil && job.Status.Finished()
+		}, 5*time.Second, 20*time.Millisecond)
+		return job
+	}
+
+	var firstJobID uint
+
+	t.Run("Imports the rows in the background", func(t *testing.T) {
+		started, err := service.StartGoodreads(ctx, "user1", "goodreads.csv", export)
+		require.NoError(t, err)
+		assert.Equal(t, 4, started.Total)
+		firstJobID = started.ID
+
+		job := waitForJob(t, started.ID)
+		assert.Equal(t, models.ImportJobStatusCompleted, job.Status)
+		assert.Equal(t, 2, job.Matched)
+		assert.Equal(t, 1, job.Ambiguous)
+		assert.Equal(t, 1, job.Failed)
+		require.Len(t, job.Rows, 4)
+
+		assert.Equal(t, models.ImportRowStatusMatched, job.Rows[0].Status)
+		assert.Equal(t, "dune", job.Rows[0].BookID)
+		assert.Equal(t, models.ImportRowStatusMatched, job.Rows[1].Status)
+		assert.Equal(t, "road", job.Rows[1].BookID)
+		assert.Equal(t, models.ImportRowStatusAmbiguous, job.Rows[2].Status)
+		assert.Contains(t, job.Rows[2].Message, "solaris-1")
+		assert.Equal(t, models.ImportRowStatusFailed, job.Rows[3].Status)
+
+		userBooks, err := userBookRepo.GetAllUserBooks("user1")
+		require.NoError(t, err)
+		assert.Len(t, userBooks, 2)
+
+		dune, err := userBookRepo.GetByUserAndBook("user1", "dune")
+		require.NoError(t, err)
+		require.NotNil(t, dune.ReadingProgress, "the read date becomes a completed progress")
+		assert.True(t, dune.ReadingProgress.Completed)
+		assert.Equal(t, 658, dune.ReadingProgress.CurrentPage)
+		assert.Equal(t, "2023-03-01", dune.ReadingProgress.StartDate.Format(time.DateOnly))
+		assert.Equal(t, "2023-04-02", dune.ReadingProgress.EndDate.Format(time.DateOnly))
+
+		road, err := userBookRepo.GetByUserAndBook("user1", "road")
+		require.NoError(t, err)
+		assert.Nil(t, road.ReadingProgress)
+	})
+
+	t.Run("Uploading the same file again returns the first job", func(t *testing.T) {
+		job, err := service.StartGoodreads(ctx, "user1", "copy.csv", export)
+		assert.ErrorIs(t, err, models.ErrImportAlreadyImported)
+		assert.Equal(t, firstJobID, job.ID)
+
+		jobs, err := service.GetAll("user1")
+		require.NoError(t, err)
+		assert.Len(t, jobs, 1)
+	})
+
+	t.Run("Books already on the shelf are not added twice", func(t *testing.T) {
+		started, err := service.StartGoodreads(ctx, "user1", "dune.csv", []byte("Title,Author,ISBN13\nDune,Frank Herbert,9780441013593\n"))
+		require.NoError(t, err)
+
+		job := waitForJob(t, started.ID)
+		assert.Equal(t, 1, job.Matched)
+		assert.Contains(t, job.Rows[0].Message, "already")
+
+		userBooks, err := userBookRepo.GetAllUserBooks("user1")
+		require.NoError(t, err)
+		assert.Len(t, userBooks, 2)
+	})
+
+	t.Run("A panicking row fails the job instead of the server", func(t *testing.T) {
+		broken := []byte("Title,Author\nBroken Book,Nobody\n")
+		started, err := service.StartGoodreads(ctx, "user1", "broken.csv", broken)
+		require.NoError(t, err)
+
+		job := waitForJob(t, started.ID)
+		assert.Equal(t, models.ImportJobStatusFailed, job.Status)
+		assert.Contains(t, job.Error, "provider bug")
+
+		again, err := service.StartGoodreads(ctx, "user1", "broken.csv", broken)
+		require.NoError(t, err)
+		assert.NotEqual(t, started.ID, again.ID)
+		waitForJob(t, again.ID)
+	})
+
+	t.Run("Jobs left unfinished by a restart fail", func(t *testing.T) {
+		stuck := &models.ImportJob{UserGoogleId: "user1", FileHash: "stuck", Status: models.ImportJobStatusRunning}
+		require.NoError(t, db.Create(stuck).Error)
+
+		require.NoError(t, service.FailInterrupted())
+		job, err := service.Get(jobID(stuck.ID), "user1")
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportJobStatusFailed, job.Status)
+		assert.Equal(t, models.ErrImportInterrupted.Error(), job.Error)
+	})
+
+	t.Run("Rejects files without books", func(t *testing.T) {
+		_, err := service.StartGoodreads(ctx, "user1", "empty.csv", []byte("Title,Author\n"))
+		assert.ErrorIs(t, err, models.ErrImportEmptyFile)
+	})
+}
+
+func jobID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
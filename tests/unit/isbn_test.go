@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestISBN(t *testing.T) {
+	t.Run("Parse normalizes to ISBN-13", func(t *testing.T) {
+		tests := []struct {
+			input  string
+			isbn13 string
+			isbn10 string
+		}{
+			{"9780441013593", "9780441013593", "0441013597"},
+			{"978-0-441-01359-3", "9780441013593", "0441013597"},
+			{"0441013597", "9780441013593", "0441013597"},
+			{"0-8044-2957-x", "9780804429573", "080442957X"},
+			{" 0 8044 2957 X ", "9780804429573", "080442957X"},
+			{"9791032305690", "9791032305690", ""},
+		}
+
+		for _, tt := range tests {
+			got, err := isbn.Parse(tt.input)
+			assert.NoError(t, err, tt.input)
+			assert.Equal(t, tt.isbn13, got.ISBN13(), tt.input)
+			assert.Equal(t, tt.isbn10, got.ISBN10(), tt.input)
+		}
+	})
+
+	t.Run("Parse rejects invalid ISBNs", func(t *testing.T) {
+		tests := []struct {
+			input string
+			err   error
+		}{
+			{"9780441013594", isbn.ErrInvalidChecksum},
+			{"0441013598", isbn.ErrInvalidChecksum},
+			{"044101359", isbn.ErrInvalidLength},
+			{"", isbn.ErrInvalidLength},
+			{"04410X3597", isbn.ErrInvalidCharacter},
+			{"978044101359X", isbn.ErrInvalidCharacter},
+			{"dune", isbn.ErrInvalidCharacter},
+			{"9770441013593", isbn.ErrInvalidPrefix},
+		}
+
+		for _, tt := range tests {
+			_, err := isbn.Parse(tt.input)
+			assert.ErrorIs(t, err, tt.err, tt.input)
+		}
+	})
+}
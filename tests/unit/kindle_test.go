@@ -0,0 +1,255 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/kindle"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const clippingsFile = "\ufeffDune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+	"- Your Highlight on page 8 | Location 117-118 | Added on Sunday, 5 January 2020 10:12:33\r\n" +
+	"\r\n" +
+	"I must not fear.\r\n" +
+	"==========\r\n" +
+	"\ufeffDune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+	"- Your Highlight on page 8 | Location 117-119 | Added on Sunday, 5 January 2020 10:13:01\r\n" +
+	"\r\n" +
+	"I must not fear. Fear is the mind-killer.\r\n" +
+	"==========\r\n" +
+	"Dune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+	"- Your Note on page 8 | Location 119 | Added on Sunday, 5 January 2020 10:14:00\r\n" +
+	"\r\n" +
+	"The litany\r\n" +
+	"==========\r\n" +
+	"Dune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+	"- Your Bookmark on page 20 | Location 300 | Added on Sunday, 5 January 2020 11:00:00\r\n" +
+	"\r\n" +
+	"\r\n" +
+	"==========\r\n" +
+	"Solaris (Stanisław Lem)\r\n" +
+	"- Ihre Markierung bei Position 1406-12 | Hinzugefügt am Montag, 3. Februar 2020 21:05:10\r\n" +
+	"\r\n" +
+	"Wir wollen gar nicht den Kosmos erobern.\r\n" +
+	"==========\r\n" +
+	"Solaris (Stanisław Lem)\r\n" +
+	"- Votre note sur la page 40 | emplacement 2001 | Ajouté le mardi 4 février 2020 08:00:00\r\n" +
+	"\r\n" +
+	"Snaut is right\r\n" +
+	"==========\r\n" +
+	"The Road (Cormac McCarthy)\r\n" +
+	"- Tu subrayado en la página 3 | posición 45-46 | Añadido el miércoles, 1 de abril de 2020 7:30:00\r\n" +
+	"\r\n" +
+	"Carry the fire.\r\n" +
+	"==========\r\n" +
+	"Unknown Book (Nobody)\r\n" +
+	"- Your Highlight at location 10-12 | Added on Wednesday, April 1, 2020 7:30:00 PM\r\n" +
+	"\r\n" +
+	"Nothing to see.\r\n" +
+	"==========\r\n"
+
+func TestKindleParse(t *testing.T) {
+	clippings, err := kindle.Parse(strings.NewReader(clippingsFile))
+	require.NoError(t, err)
+	require.Len(t, clippings, 8)
+
+	first := clippings[0]
+	assert.Equal(t, "Dune (Dune Chronicles Book 1)", first.Title)
+	assert.Equal(t, "Herbert, Frank", first.Author)
+	assert.Equal(t, "Frank Herbert", first.FirstAuthor())
+	assert.Equal(t, kindle.KindHighlight, first.Kind)
+	assert.Equal(t, 8, first.Page)
+	assert.Equal(t, "117-118", first.Location())
+	assert.Equal(t, time.Date(2020, time.January, 5, 10, 12, 33, 0, time.UTC), *first.AddedAt)
+	assert.Equal(t, "I must not fear.", first.Text)
+
+	assert.Equal(t, kindle.KindNote, clippings[2].Kind)
+	assert.Equal(t, kindle.KindBookmark, clippings[3].Kind)
+
+	german := clippings[4]
+	assert.Equal(t, kindle.KindHighlight, german.Kind)
+	assert.Equal(t, 1406, german.LocationStart)
+	assert.Equal(t, 1412, german.LocationEnd, "shortened range")
+	assert.Equal(t, time.Date(2020, time.February, 3, 21, 5, 10, 0, time.UTC), *german.AddedAt)
+
+	french := clippings[5]
+	assert.Equal(t, kindle.KindNote, french.Kind)
+	assert.Equal(t, 40, french.Page)
+	assert.Equal(t, 2001, french.LocationStart)
+
+	spanish := clippings[6]
+	assert.Equal(t, kindle.KindHighlight, spanish.Kind)
+	assert.Equal(t, 3, spanish.Page)
+	assert.Equal(t, time.Date(2020, time.April, 1, 7, 30, 0, 0, time.UTC), *spanish.AddedAt)
+
+	english := clippings[7]
+	assert.Equal(t, "10-12", english.Location())
+	assert.Equal(t, time.Date(2020, time.April, 1, 19, 30, 0, 0, time.UTC), *english.AddedAt, "12-hour clock")
+
+	t.Run("Not a clippings file", func(t *testing.T) {
+		_, err := kindle.Parse(strings.NewReader(""))
+		assert.ErrorIs(t, err, kindle.ErrEmptyFile)
+		_, err = kindle.Parse(strings.NewReader("Title,Author\nDune,Frank Herbert\n"))
+		assert.ErrorIs(t, err, kindle.ErrNotClippings)
+	})
+
+	t.Run("Notes join their highlights", func(t *testing.T) {
+		attached := kindle.Attach(clippings)
+		require.Len(t, attached, 5)
+		assert.Equal(t, "I must not fear. Fear is the mind-killer.", attached[0].Text, "the extended highlight is kept")
+		assert.Equal(t, "The litany", attached[0].Note)
+		assert.Equal(t, kindle.KindNote, attached[4].Kind, "a note outside every highlight stays on its own")
+		assert.Equal(t, "Snaut is right", attached[4].Text)
+		assert.NotEqual(t, attached[0].Key(), attached[1].Key())
+	})
+}
+
+func TestKindleImport(t *testing.T) {
+	ctx := context.Background()
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	// the import runs in its own goroutine, every connection to :memory: would open a new empty database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "user1", Username: "user1", Email: "user1@example.com"}).Error)
+	bookRepo := repositories.NewBookRepository(db)
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "dune", Title: "Dune", Authors: "Frank Herbert"}))
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "solaris", Title: "Solaris", Authors: "Stanislaw Lem"}))
+	userBookRepo := repositories.NewUserBookRepository(db)
+	for _, bookID := range []string{"dune", "solaris"} {
+		require.NoError(t, userBookRepo.Create(&models.UserBook{UserGoogleId: "user1", BookID: bookID}))
+	}
+
+	provider := new(MockBookProvider)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("The Road", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Return([]*models.Book{{ID: "road", Title: "The Road", Authors: "Cormac McCarthy"}}, nil)
+	provider.On("GetBooksByQuery", handlers.NewSearchQuery("Unknown Book", handlers.QueryTypeTitle), models.SearchFilters{}, mock.Anything, 1).
+		Return([]*models.Book{}, nil)
+
+	highlightRepo := repositories.NewHighlightRepository(db)
+	importRepo := repositories.NewImportRepository(db)
+	service := services.NewImportService(
+		importRepo,
+		services.NewBookService(bookRepo).WithProvider(provider),
+		bookRepo,
+		userBookRepo,
+		repositories.NewProgressRepository(db),
+	).WithHighlights(highlightRepo)
+
+	waitForJob := func(t *testing.T, id uint) *models.ImportJob {
+		var job *models.ImportJob
+		require.Eventually(t, func() bool {
+			var err error
+			job, err = service.Get(jobID(id), "user1")
+			return err == nil && job.Status.Finished()
+		}, 5*time.Second, 20*time.Millisecond)
+		return job
+	}
+	highlights := func(t *testing.T) []*models.Highlight {
+		found, err := highlightRepo.Search("user1", "")
+		require.NoError(t, err)
+		return found
+	}
+
+	t.Run("Clippings of the library books become highlights", func(t *testing.T) {
+		started, err := service.StartKindle(ctx, "user1", "My Clippings.txt", []byte(clippingsFile), false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportSourceKindle, started.Source)
+		assert.Equal(t, 4, started.Total)
+
+		job := waitForJob(t, started.ID)
+		assert.Equal(t, models.ImportJobStatusCompleted, job.Status)
+		assert.Equal(t, 2, job.Matched)
+		assert.Equal(t, 2, job.Failed)
+		require.Len(t, job.Rows, 4)
+		assert.Equal(t, "dune", job.Rows[0].BookID)
+		assert.Equal(t, "1 highlight imported", job.Rows[0].Message)
+		assert.Contains(t, job.Rows[2].Message, "not in your books")
+
+		saved := highlights(t)
+		require.Len(t, saved, 3)
+		dune := saved[len(saved)-1]
+		assert.Equal(t, "I must not fear. Fear is the mind-killer.", dune.Text)
+		assert.Equal(t, "The litany", dune.Note)
+		assert.Equal(t, 8, *dune.Page)
+		assert.Equal(t, "kindle", dune.Tags)
+		assert.Equal(t, 2020, dune.CreatedAt.Year())
+	})
+
+	t.Run("Importing again skips the clippings and adds the missing books", func(t *testing.T) {
+		started, err := service.StartKindle(ctx, "user1", "My Clippings.txt", []byte(clippingsFile), true)
+		require.NoError(t, err)
+
+		job := waitForJob(t, started.ID)
+		assert.Equal(t, 3, job.Matched)
+		assert.Equal(t, 1, job.Failed)
+		assert.Equal(t, "0 highlights imported, 1 imported before", job.Rows[0].Message)
+		assert.Equal(t, "road", job.Rows[2].BookID)
+		assert.Equal(t, "no matching book found", job.Rows[3].Message)
+
+		_, err = userBookRepo.GetByUserAndBook("user1", "road")
+		assert.NoError(t, err, "the missing book is shelved")
+		assert.Len(t, highlights(t), 4)
+	})
+
+	t.Run("Extending a highlight or adding a note updates it", func(t *testing.T) {
+		before := highlights(t)
+		grown := clippingsFile +
+			"Dune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+			"- Your Highlight on page 8 | Location 117-121 | Added on Monday, 6 January 2020 09:00:00\r\n" +
+			"\r\n" +
+			"I must not fear. Fear is the mind-killer. Fear is the little-death.\r\n" +
+			"==========\r\n" +
+			"Dune (Dune Chronicles Book 1) (Herbert, Frank)\r\n" +
+			"- Your Note on page 8 | Location 121 | Added on Monday, 6 January 2020 09:01:00\r\n" +
+			"\r\n" +
+			"Said before the gom jabbar\r\n" +
+			"==========\r\n"
+		started, err := service.StartKindle(ctx, "user1", "My Clippings.txt", []byte(grown), false)
+		require.NoError(t, err)
+
+		job := waitForJob(t, started.ID)
+		messages := map[string]string{}
+		for _, row := range job.Rows {
+			messages[row.BookID] = row.Message
+		}
+		assert.Equal(t, "0 highlights imported, 1 updated", messages["dune"])
+
+		after := highlights(t)
+		require.Len(t, after, len(before), "the extended highlight is not added twice")
+		var dune *models.Highlight
+		for _, highlight := range after {
+			if highlight.UserBook.BookID == "dune" {
+				dune = highlight
+			}
+		}
+		require.NotNil(t, dune)
+		assert.Equal(t, "I must not fear. Fear is the mind-killer. Fear is the little-death.", dune.Text)
+		assert.Equal(t, "The litany\nSaid before the gom jabbar", dune.Note)
+	})
+
+	t.Run("Files without clippings", func(t *testing.T) {
+		_, err := service.StartKindle(ctx, "user1", "bookmarks.txt", []byte("Dune (Frank Herbert)\n- Your Bookmark on Location 3 | Added on Sunday, 5 January 2020 10:12:33\n\n\n==========\n"), false)
+		assert.ErrorIs(t, err, models.ErrImportEmptyFile)
+	})
+}
+
+func TestMatchUserBook(t *testing.T) {
+	userBooks := []*models.UserBook{
+		{BookID: "dune", Book: models.Book{ID: "dune", Title: "Dune: Deluxe Edition", Authors: "Frank Herbert"}},
+		{BookID: "solaris", Book: models.Book{ID: "solaris", Title: "Solaris", Authors: "Stanisław Lem"}},
+	}
+	assert.Equal(t, "dune", services.MatchUserBook(userBooks, "Dune (Dune Chronicles Book 1)", "Herbert, Frank").BookID)
+	assert.Equal(t, "solaris", services.MatchUserBook(userBooks, "Solaris", "").BookID, "a title without an author")
+	assert.Nil(t, services.MatchUserBook(userBooks, "Solaris", "Someone Else"))
+}
@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/isbn"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualBook_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    models.ManualBook
+		expected error
+	}{
+		{
+			name:     "Valid Book",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Pages: 680, ISBN: "0-441-01359-7", Cover: "https://example.com/lalka.jpg"},
+			expected: nil,
+		},
+		{
+			name:     "Uploaded Cover",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Cover: "data:image/png;base64,iVBORw0KGgo="},
+			expected: nil,
+		},
+		{
+			name:     "Missing Title",
+			input:    models.ManualBook{Title: " ", Authors: "Bolesław Prus"},
+			expected: models.ErrManualBookTitleRequired,
+		},
+		{
+			name:     "Missing Authors",
+			input:    models.ManualBook{Title: "Lalka"},
+			expected: models.ErrManualBookAuthorsRequired,
+		},
+		{
+			name:     "Negative Pages",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Pages: -1},
+			expected: models.ErrManualBookPagesNegative,
+		},
+		{
+			name:     "Invalid ISBN",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", ISBN: "0441013598"},
+			expected: isbn.ErrInvalidChecksum,
+		},
+		{
+			name:     "Too Many Genres",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Genres: []string{"a", "b", "c", "d", "e", "f"}},
+			expected: models.ErrManualBookTooManyGenres,
+		},
+		{
+			name:     "Invalid Cover",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Cover: "javascript:alert(1)"},
+			expected: models.ErrManualBookInvalidCover,
+		},
+		{
+			name:     "Cover Too Large",
+			input:    models.ManualBook{Title: "Lalka", Authors: "Bolesław Prus", Cover: "data:image/png;base64," + strings.Repeat("A", 2*models.ManualBookMaxCoverSize)},
+			expected: models.ErrManualBookCoverTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.input.Validate(), tt.expected)
+		})
+	}
+}
+
+func TestManualBook_Book(t *testing.T) {
+	input := models.ManualBook{Title: " Lalka ", Authors: "Bolesław Prus", Pages: 680, ISBN: "0-441-01359-7", Genres: []string{"Classics", " "}}
+
+	book := input.Book("user123")
+	other := input.Book("user123")
+
+	assert.True(t, book.IsManual())
+	assert.True(t, strings.HasPrefix(book.ID, models.ManualBookIDPrefix))
+	assert.NotEqual(t, book.ID, other.ID)
+	assert.Equal(t, "Lalka", book.Title)
+	assert.Equal(t, "user123", book.CreatedBy)
+	assert.Equal(t, "9780441013593", book.ISBN13)
+	assert.Equal(t, "0441013597", book.ISBN10)
+	assert.Len(t, book.Genres, 1)
+}
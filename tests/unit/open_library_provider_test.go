@@ -0,0 +1,243 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorded responses served by the fake Open Library server
+var openLibraryRoutes = map[string]string{
+	"/search.json":                   "search.json",
+	"/works/OL893415W.json":          "work_OL893415W.json",
+	"/works/OL893415W/editions.json": "work_OL893415W_editions.json",
+	"/books/OL7353617M.json":         "edition_OL7353617M.json",
+	"/authors/OL79034A.json":         "author_OL79034A.json",
+	"/subjects/science_fiction.json": "subject_science_fiction.json",
+}
+
+func newOpenLibraryServer(t *testing.T, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r)
+		}
+		file, ok := openLibraryRoutes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join("testdata", "openlibrary", file))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenLibraryProvider(t *testing.T) {
+	ctx := context.Background()
+	t.Run("GetBooksByQuery maps search docs", func(t *testing.T) {
+		server := newOpenLibraryServer(t, nil)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		books, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 10, 1)
+		require.NoError(t, err)
+		require.Len(t, books, 2)
+
+		dune := books[0]
+		assert.Equal(t, "OL893415W", dune.ID)
+		assert.Equal(t, "openlibrary:OL893415W", dune.ProviderWorkID)
+		assert.Equal(t, "Dune", dune.Title)
+		assert.Equal(t, "Frank Herbert", dune.Authors)
+		assert.Equal(t, "9780441013593", dune.ISBN13)
+		assert.Equal(t, "0441013597", dune.ISBN10)
+		assert.Equal(t, 604, dune.Pages)
+		assert.Equal(t, "1965", dune.PublishedDate)
+		assert.Equal(t, "https://covers.openlibrary.org/b/id/11481354-M.jpg", dune.ImageLink)
+		assert.Len(t, dune.Genres, 5)
+		assert.Equal(t, "Science fiction", dune.Genres[0].Name)
+
+		assert.Equal(t, "9780593098233", books[1].ISBN13, "isbn-10 is converted when no isbn-13 is available")
+	})
+
+	t.Run("GetBooksByQuery uses a parameter per query type", func(t *testing.T) {
+		tests := []struct {
+			queryType handlers.QueryType
+			param     string
+		}{
+			{handlers.QueryTypeTitle, "title"},
+			{handlers.QueryTypeAuthor, "author"},
+			{handlers.QueryTypeSubject, "subject"},
+			{handlers.QueryTypeISBN, "isbn"},
+		}
+
+		for _, tt := range tests {
+			var requests []*http.Request
+			server := newOpenLibraryServer(t, &requests)
+			provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+			_, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("frank herbert", tt.queryType), models.SearchFilters{}, 20, 3)
+			require.NoError(t, err)
+			require.Len(t, requests, 1)
+
+			query := requests[0].URL.Query()
+			assert.Equal(t, "frank herbert", query.Get(tt.param))
+			assert.Equal(t, "20", query.Get("limit"))
+			assert.Equal(t, "3", query.Get("page"))
+		}
+	})
+
+	t.Run("GetBooksByQuery combines fields", func(t *testing.T) {
+		var requests []*http.Request
+		server := newOpenLibraryServer(t, &requests)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+		query := handlers.ParseSearchQuery(`author:lem subject:"science fiction" "solaris station"`, handlers.QueryTypeText)
+
+		_, err := provider.GetBooksByQuery(ctx, query, models.SearchFilters{}, 20, 1)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+
+		params := requests[0].URL.Query()
+		assert.Equal(t, `"solaris station"`, params.Get("q"))
+		assert.Equal(t, "lem", params.Get("author"))
+		assert.Equal(t, `"science fiction"`, params.Get("subject"))
+		assert.False(t, params.Has("title"))
+	})
+
+	t.Run("GetBooksByQuery sends the language and order filters", func(t *testing.T) {
+		var requests []*http.Request
+		server := newOpenLibraryServer(t, &requests)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+		filters := models.SearchFilters{Language: "pl", OrderBy: models.SearchOrderNewest, MinPages: 100}
+
+		_, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("lalka", handlers.QueryTypeTitle), filters, 20, 1)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+
+		query := requests[0].URL.Query()
+		assert.Equal(t, "pol", query.Get("language"))
+		assert.Equal(t, "new", query.Get("sort"))
+		assert.False(t, query.Has("min-pages"), "page counts are filtered by the service")
+	})
+
+	t.Run("GetBooksByQuery skips magazine searches", func(t *testing.T) {
+		var requests []*http.Request
+		server := newOpenLibraryServer(t, &requests)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		books, err := provider.GetBooksByQuery(ctx, handlers.NewSearchQuery("time", handlers.QueryTypeTitle), models.SearchFilters{PrintType: models.PrintTypeMagazines}, 20, 1)
+		require.NoError(t, err)
+
+		assert.Empty(t, books)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("GetBook for a work fills isbn and pages from editions", func(t *testing.T) {
+		server := newOpenLibraryServer(t, nil)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		book, err := provider.GetBook(ctx, "OL893415W")
+		require.NoError(t, err)
+		assert.Equal(t, "OL893415W", book.ID)
+		assert.Equal(t, "Frank Herbert", book.Authors)
+		assert.True(t, strings.HasPrefix(book.Description, "Set on the desert planet Arrakis"))
+		assert.Equal(t, "9780441013593", book.ISBN13)
+		assert.Equal(t, 528, book.Pages)
+		assert.Equal(t, "https://covers.openlibrary.org/b/id/11481354-M.jpg", book.ImageLink)
+		assert.Len(t, book.Genres, 2)
+	})
+
+	t.Run("GetBook for an edition", func(t *testing.T) {
+		server := newOpenLibraryServer(t, nil)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		book, err := provider.GetBook(ctx, "OL7353617M")
+		require.NoError(t, err)
+		assert.Equal(t, "OL7353617M", book.ID)
+		assert.Equal(t, "openlibrary:OL893415W", book.ProviderWorkID)
+		assert.Equal(t, "Dune: Deluxe Edition", book.Title)
+		assert.Equal(t, "Frank Herbert", book.Authors)
+		assert.Equal(t, "Paperback edition of the classic.", book.Description)
+		assert.Equal(t, "9780441013593", book.ISBN13)
+		assert.Equal(t, 528, book.Pages)
+		assert.Equal(t, "August 2, 2005", book.PublishedDate)
+	})
+
+	t.Run("GetBook with unknown id", func(t *testing.T) {
+		server := newOpenLibraryServer(t, nil)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		_, err := provider.GetBook(ctx, "zyTCAlFPjgYC")
+		assert.ErrorIs(t, err, providers.ErrOpenLibraryUnknownID)
+
+		_, err = provider.GetBook(ctx, "abcdefgW")
+		assert.ErrorIs(t, err, providers.ErrOpenLibraryUnknownID, "a google id ending in W is not a work")
+
+		_, err = provider.GetBook(ctx, "OL1M")
+		assert.Error(t, err, "missing edition returns 404")
+	})
+
+	t.Run("GetBooksByGenre", func(t *testing.T) {
+		server := newOpenLibraryServer(t, nil)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		books, err := provider.GetBooksByGenre(ctx, "Science Fiction")
+		require.NoError(t, err)
+		require.Len(t, books, 2)
+		assert.Equal(t, "OL46125W", books[1].ID)
+		assert.Equal(t, "Solaris", books[1].Title)
+		assert.Equal(t, "Stanisław Lem", books[1].Authors)
+		assert.Equal(t, "1961", books[1].PublishedDate)
+	})
+
+	t.Run("A cancelled context aborts the request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(server.Close)
+		provider := providers.NewOpenLibraryProviderWithURL(server.URL)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := provider.GetBooksByQuery(cancelled, handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 10, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("The client timeout bounds a slow provider", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		t.Cleanup(func() {
+			close(release)
+			server.Close()
+		})
+		provider := providers.NewOpenLibraryProviderWithClient(server.URL, providers.NewHTTPClient(50*time.Millisecond))
+
+		start := time.Now()
+		_, err := provider.GetBook(ctx, "OL893415W")
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Convert ignores foreign responses", func(t *testing.T) {
+		provider := providers.NewOpenLibraryProvider()
+		assert.Nil(t, provider.Convert(providers.BookResponse{}))
+	})
+}
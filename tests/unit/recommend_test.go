@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recommendedIDs(recommendations []*models.Recommendation) []string {
+	ids := make([]string, len(recommendations))
+	for i, recommendation := range recommendations {
+		ids[i] = recommendation.Book.ID
+	}
+	return ids
+}
+
+func TestRecommendRank(t *testing.T) {
+	workID := uint(7)
+	library := []*models.UserBook{
+		{
+			BookID: "solaris",
+			Book: models.Book{
+				ID:          "solaris",
+				Title:       "Solaris",
+				Authors:     "Stanisław Lem",
+				Description: "Scientists on a space station orbit an alien ocean planet that may be a living mind.",
+				Genres:      []models.Genre{{Name: "Science Fiction"}},
+			},
+			ReadingProgress: &models.ReadingProgress{Completed: true},
+		},
+		{
+			BookID: "dune",
+			Book: models.Book{
+				ID:      "dune",
+				Title:   "Dune",
+				Authors: "Frank Herbert",
+				WorkID:  &workID,
+				Genres:  []models.Genre{{Name: "Science Fiction"}},
+			},
+		},
+	}
+	candidates := []*models.Book{
+		{ID: "cookbook", Title: "Pasta Every Day", Authors: "Anna Cook", Description: "Recipes for quick dinners.", Genres: []models.Genre{{Name: "Cooking"}}},
+		{ID: "invincible", Title: "The Invincible", Authors: "Stanisław Lem", Description: "A space crew lands on a planet ruled by a swarm of machines.", Genres: []models.Genre{{Name: "science fiction"}}},
+		{ID: "hyperion", Title: "Hyperion", Authors: "Dan Simmons", Description: "Pilgrims travel to a distant planet.", Genres: []models.Genre{{Name: "Science Fiction"}}},
+		{ID: "dune-2005", Title: "Dune", Authors: "Frank Herbert", WorkID: &workID},
+		{ID: "solaris", Title: "Solaris"},
+	}
+
+	t.Run("Ranks by genre, author and description", func(t *testing.T) {
+		recommendations := recommend.Rank(library, nil, candidates)
+
+		assert.Equal(t, []string{"invincible", "hyperion", "cookbook"}, recommendedIDs(recommendations))
+		invincible := recommendations[0]
+		assert.InDelta(t, 1, invincible.GenreScore, 1e-9)
+		assert.InDelta(t, 1, invincible.AuthorScore, 1e-9)
+		assert.Greater(t, invincible.ContentScore, recommendations[1].ContentScore)
+		assert.Zero(t, recommendations[2].Score)
+		for _, recommendation := range recommendations {
+			assert.GreaterOrEqual(t, recommendation.Score, 0.0)
+			assert.LessOrEqual(t, recommendation.Score, 1.0)
+		}
+	})
+
+	t.Run("Is deterministic", func(t *testing.T) {
+		reversed := make([]*models.Book, len(candidates))
+		for i, book := range candidates {
+			reversed[len(candidates)-1-i] = book
+		}
+
+		assert.Equal(t,
+			recommendedIDs(recommend.Rank(library, nil, candidates)),
+			recommendedIDs(recommend.Rank(library, nil, reversed)),
+		)
+	})
+
+	t.Run("Preferred genres count without a library", func(t *testing.T) {
+		recommendations := recommend.Rank(nil, []models.Genre{{Name: "Cooking"}}, candidates)
+
+		require.NotEmpty(t, recommendations)
+		assert.Equal(t, "cookbook", recommendations[0].Book.ID)
+	})
+
+	t.Run("Explains every recommendation", func(t *testing.T) {
+		recommendations := recommend.Rank(library, nil, append(slices.Clone(candidates),
+			&models.Book{ID: "cyberiad", Title: "The Cyberiad", Authors: "Stanisław Lem"},
+			&models.Book{ID: "ocean", Title: "Deep Blue", Authors: "Jane Sea", Description: "An alien ocean that may be a living mind."},
+		))
+
+		reasons := map[string]models.RecommendationReason{}
+		for _, recommendation := range recommendations {
+			reasons[recommendation.Book.ID] = recommendation.Reason
+		}
+		assert.Equal(t, models.RecommendationReason{Kind: models.ReasonGenre, Subject: "Science Fiction"}, reasons["invincible"])
+		assert.Equal(t, "Because you like Science Fiction", reasons["invincible"].String())
+		assert.Equal(t, models.RecommendationReason{Kind: models.ReasonAuthor, Subject: "Stanisław Lem"}, reasons["cyberiad"])
+		assert.Equal(t, models.RecommendationReason{Kind: models.ReasonSimilarBook, Subject: "Solaris"}, reasons["ocean"])
+		assert.Empty(t, reasons["cookbook"].String(), "nothing to explain a book that matches nothing")
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		recommendations := recommend.Rank(library, nil, candidates)
+
+		assert.Equal(t, []string{"invincible", "hyperion"}, recommendedIDs(recommend.Page(recommendations, 1, 2)))
+		assert.Equal(t, []string{"cookbook"}, recommendedIDs(recommend.Page(recommendations, 2, 2)))
+		assert.Empty(t, recommend.Page(recommendations, 3, 2))
+		assert.Empty(t, recommend.Page(recommendations, 0, 2))
+	})
+}
+
+func TestRecommendFeedback(t *testing.T) {
+	workID := uint(3)
+	library := []*models.UserBook{{BookID: "solaris", Book: models.Book{ID: "solaris", Title: "Solaris", Genres: []models.Genre{{Name: "Science Fiction"}}}}}
+	feedback := []*models.RecommendationFeedback{
+		{BookID: "dune", Kind: models.FeedbackAlreadyRead, Book: models.Book{ID: "dune", Title: "Dune", Authors: "Frank Herbert", Genres: []models.Genre{{Name: "Space Opera"}}}},
+		{BookID: "twilight", Kind: models.FeedbackNotInterested, Book: models.Book{ID: "twilight", Authors: "Stephenie Meyer", WorkID: &workID}},
+	}
+
+	t.Run("Books already read join the library as finished", func(t *testing.T) {
+		withFeedback := recommend.WithFeedback(library, append(feedback, &models.RecommendationFeedback{BookID: "solaris", Kind: models.FeedbackAlreadyRead}))
+
+		require.Len(t, withFeedback, 2)
+		assert.Equal(t, "dune", withFeedback[1].BookID)
+		assert.True(t, withFeedback[1].ReadingProgress.Completed)
+		assert.Len(t, library, 1, "the library is not changed")
+	})
+
+	t.Run("Turned down books leave and their authors sink", func(t *testing.T) {
+		candidates := []*models.Book{
+			{ID: "twilight-2008", Title: "Twilight", WorkID: &workID, Genres: []models.Genre{{Name: "Science Fiction"}}},
+			{ID: "host", Title: "The Host", Authors: "Stephenie Meyer", Genres: []models.Genre{{Name: "Science Fiction"}}},
+			{ID: "hyperion", Title: "Hyperion", Authors: "Dan Simmons", Genres: []models.Genre{{Name: "Science Fiction"}}},
+			{ID: "children", Title: "Children of Dune", Authors: "Frank Herbert", Genres: []models.Genre{{Name: "Space Opera"}}},
+			{ID: "dune", Title: "Dune", Authors: "Frank Herbert"},
+		}
+		ranked := recommend.Rank(recommend.WithFeedback(library, feedback), nil, candidates)
+		host := ranked[slices.IndexFunc(ranked, func(r *models.Recommendation) bool { return r.Book.ID == "host" })].Score
+
+		recommendations := recommend.ApplyFeedback(ranked, feedback)
+
+		assert.Equal(t, []string{"children", "hyperion", "host"}, recommendedIDs(recommendations))
+		assert.InDelta(t, host/2, recommendations[2].Score, 1e-9)
+	})
+}
@@ -0,0 +1,45 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendationFeedbackRepository(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := repositories.NewRecommendationFeedbackRepository(db)
+	svc := services.NewRecommendationFeedbackService(repo)
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "anna", Username: "anna", Email: "anna@example.com"}).Error)
+	require.NoError(t, db.Create(&models.Book{ID: "dune", Title: "Dune", Genres: []models.Genre{{Name: "Science Fiction"}}}).Error)
+
+	t.Run("Save keeps the latest feedback", func(t *testing.T) {
+		require.NoError(t, svc.Save("anna", "dune", models.FeedbackNotInterested))
+		require.NoError(t, svc.Save("anna", "dune", models.FeedbackAlreadyRead))
+
+		feedback, err := svc.GetAll("anna")
+		require.NoError(t, err)
+		require.Len(t, feedback, 1)
+		assert.Equal(t, models.FeedbackAlreadyRead, feedback[0].Kind)
+		assert.Equal(t, "Dune", feedback[0].Book.Title)
+		require.Len(t, feedback[0].Book.Genres, 1)
+	})
+
+	t.Run("Unknown feedback is rejected", func(t *testing.T) {
+		assert.ErrorIs(t, svc.Save("anna", "dune", "meh"), models.ErrFeedbackUnknownKind)
+	})
+
+	t.Run("Deleting the user deletes the feedback", func(t *testing.T) {
+		require.NoError(t, repositories.NewUserRepository(db).Delete("anna"))
+
+		var count int64
+		db.Model(&models.RecommendationFeedback{}).Count(&count)
+		assert.Zero(t, count)
+	})
+}
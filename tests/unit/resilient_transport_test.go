@@ -0,0 +1,201 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusServer answers with the statuses in order and repeats the last one after that
+func newStatusServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		for key, values := range headers {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(statuses[min(i, len(statuses)-1)])
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func getThrough(t *testing.T, client *http.Client, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestResilientTransport(t *testing.T) {
+	t.Run("Retries a retryable status until it succeeds", func(t *testing.T) {
+		server, calls := newStatusServer(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+		transport := providers.NewResilientTransport(nil).WithRetries(3, time.Millisecond, 10*time.Millisecond)
+
+		resp, err := getThrough(t, &http.Client{Transport: transport}, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Gives up after the last retry", func(t *testing.T) {
+		server, calls := newStatusServer(t, nil, http.StatusServiceUnavailable)
+		transport := providers.NewResilientTransport(nil).WithRetries(2, time.Millisecond, 10*time.Millisecond)
+
+		resp, err := getThrough(t, &http.Client{Transport: transport}, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Does not retry client errors", func(t *testing.T) {
+		server, calls := newStatusServer(t, nil, http.StatusNotFound)
+		transport := providers.NewResilientTransport(nil).WithRetries(3, time.Millisecond, 10*time.Millisecond)
+
+		resp, err := getThrough(t, &http.Client{Transport: transport}, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, providers.BreakerClosed, transport.BreakerState(hostOf(t, server.URL)))
+	})
+
+	t.Run("Waits for Retry-After", func(t *testing.T) {
+		server, calls := newStatusServer(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests, http.StatusOK)
+		transport := providers.NewResilientTransport(nil).WithRetries(3, time.Millisecond, 2*time.Second)
+
+		start := time.Now()
+		resp, err := getThrough(t, &http.Client{Transport: transport}, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Returns at once when Retry-After is beyond the maximum delay", func(t *testing.T) {
+		server, calls := newStatusServer(t, http.Header{"Retry-After": {"3600"}}, http.StatusTooManyRequests)
+		transport := providers.NewResilientTransport(nil).WithRetries(3, time.Millisecond, time.Second)
+
+		resp, err := getThrough(t, &http.Client{Transport: transport}, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("The breaker fails fast and recovers after the cool-down", func(t *testing.T) {
+		server, calls := newStatusServer(t, nil, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+		now := time.Now()
+		transport := providers.NewResilientTransport(nil).
+			WithClock(func() time.Time { return now }).
+			WithRetries(0, time.Millisecond, time.Millisecond).
+			WithBreaker(2, time.Minute)
+		client := &http.Client{Transport: transport}
+		host := hostOf(t, server.URL)
+
+		for range 2 {
+			_, err := getThrough(t, client, server.URL)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, providers.BreakerOpen, transport.BreakerState(host))
+
+		_, err := getThrough(t, client, server.URL)
+		assert.ErrorIs(t, err, models.ErrProviderUnavailable)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "degraded", transport.Health()["status"])
+
+		now = now.Add(time.Minute)
+		assert.Equal(t, providers.BreakerHalfOpen, transport.BreakerState(host))
+
+		resp, err := getThrough(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, providers.BreakerClosed, transport.BreakerState(host))
+		assert.Equal(t, "up", transport.Health()["status"])
+	})
+
+	t.Run("A failed probe reopens the breaker", func(t *testing.T) {
+		server, _ := newStatusServer(t, nil, http.StatusInternalServerError)
+		now := time.Now()
+		transport := providers.NewResilientTransport(nil).
+			WithClock(func() time.Time { return now }).
+			WithRetries(0, time.Millisecond, time.Millisecond).
+			WithBreaker(1, time.Minute)
+		client := &http.Client{Transport: transport}
+
+		_, err := getThrough(t, client, server.URL)
+		require.NoError(t, err)
+		now = now.Add(time.Minute)
+		_, err = getThrough(t, client, server.URL)
+		require.NoError(t, err)
+
+		assert.Equal(t, providers.BreakerOpen, transport.BreakerState(hostOf(t, server.URL)))
+	})
+
+	t.Run("The rate limit spaces requests beyond the burst", func(t *testing.T) {
+		server, calls := newStatusServer(t, nil, http.StatusOK)
+		transport := providers.NewResilientTransport(nil).WithRateLimit(20, 1)
+		client := &http.Client{Transport: transport}
+
+		start := time.Now()
+		for range 3 {
+			_, err := getThrough(t, client, server.URL)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(3), calls.Load())
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("A cancelled request does not wait for the rate limit", func(t *testing.T) {
+		server, _ := newStatusServer(t, nil, http.StatusOK)
+		transport := providers.NewResilientTransport(nil).WithRateLimit(0.1, 1)
+		client := &http.Client{Transport: transport}
+		_, err := getThrough(t, client, server.URL)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Do(req)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Providers report an unavailable provider", func(t *testing.T) {
+		server, _ := newStatusServer(t, nil, http.StatusTooManyRequests)
+		transport := providers.NewResilientTransport(nil).WithRetries(0, time.Millisecond, time.Millisecond)
+		provider := providers.NewOpenLibraryProviderWithClient(server.URL, &http.Client{Transport: transport})
+
+		_, err := provider.GetBooksByQuery(context.Background(), handlers.NewSearchQuery("dune", handlers.QueryTypeTitle), models.SearchFilters{}, 10, 1)
+
+		assert.ErrorIs(t, err, models.ErrProviderUnavailable)
+	})
+}
+
+func hostOf(t *testing.T, rawURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return parsed.Host
+}
@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewRating(t *testing.T) {
+	for _, rating := range []float64{1, 2.5, 5} {
+		assert.NoError(t, models.ValidateRating(rating), rating)
+	}
+	for _, rating := range []float64{0, 0.5, 3.25, 5.5} {
+		assert.ErrorIs(t, models.ValidateRating(rating), models.ErrReviewInvalidRating, rating)
+	}
+
+	stats := models.NewReviewStats([]*models.Review{{Rating: 5}, {Rating: 4.5}, {Rating: 4}, {Rating: 5}})
+	assert.Equal(t, 4, stats.Count)
+	assert.InDelta(t, 4.625, stats.Average, 0.001)
+	require.Len(t, stats.Histogram, 9)
+	assert.Equal(t, 2, stats.Histogram[8])
+	assert.Equal(t, 1, stats.Histogram[7])
+	assert.Equal(t, 4.5, stats.Rating(7))
+	assert.Equal(t, 50, stats.Share(8))
+
+	empty := models.NewReviewStats(nil)
+	assert.Zero(t, empty.Average)
+	assert.Zero(t, empty.Share(0))
+}
+
+func TestReviewService(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.Create(&models.User{GoogleId: "anna", Username: "anna", Email: "anna@example.com"}).Error)
+	require.NoError(t, db.Create(&models.User{GoogleId: "bob", Username: "bob", Email: "bob@example.com"}).Error)
+	bookRepo := repositories.NewBookRepository(db)
+	hardcover := &models.Book{ID: "hardcover", Title: "Solaris", Authors: "Stanisław Lem", ProviderWorkID: "openlibrary:OL1W"}
+	paperback := &models.Book{ID: "paperback", Title: "Solaris", Authors: "Stanisław Lem", ProviderWorkID: "openlibrary:OL1W"}
+	other := &models.Book{ID: "other", Title: "Eden", Authors: "Stanisław Lem"}
+	for _, book := range []*models.Book{hardcover, paperback, other} {
+		require.NoError(t, bookRepo.Create(book))
+	}
+
+	svc := services.NewReviewService(repositories.NewReviewRepository(db))
+
+	review, err := svc.Create("anna", hardcover, 4.5, "  A classic.  ", false)
+	require.NoError(t, err)
+	assert.Equal(t, "A classic.", review.Text)
+
+	t.Run("One review per work", func(t *testing.T) {
+		_, err := svc.Create("anna", paperback, 3, "", false)
+		assert.ErrorIs(t, err, models.ErrReviewExists)
+
+		_, err = svc.Create("bob", paperback, 3, "The ending!", true)
+		require.NoError(t, err)
+		_, err = svc.Create("bob", other, 2, "", false)
+		require.NoError(t, err)
+	})
+
+	t.Run("Stats cover every edition", func(t *testing.T) {
+		reviews, stats, err := svc.GetForBook(hardcover)
+		require.NoError(t, err)
+		assert.Len(t, reviews, 2)
+		assert.Equal(t, 2, stats.Count)
+		assert.InDelta(t, 3.75, stats.Average, 0.001)
+	})
+
+	t.Run("Ids are not SQL", func(t *testing.T) {
+		_, err := svc.Delete("anna", "0 OR 1=1")
+		assert.Error(t, err)
+		reviews, _, err := svc.GetForBook(hardcover)
+		require.NoError(t, err)
+		assert.Len(t, reviews, 2)
+	})
+
+	t.Run("Only the author changes a review", func(t *testing.T) {
+		id := fmt.Sprint(review.ID)
+		_, err := svc.Update("bob", id, 1, "", false)
+		assert.ErrorIs(t, err, models.ErrReviewNotOwner)
+		_, err = svc.Delete("bob", id)
+		assert.ErrorIs(t, err, models.ErrReviewNotOwner)
+
+		_, err = svc.Update("anna", id, 6, "", false)
+		assert.ErrorIs(t, err, models.ErrReviewInvalidRating)
+
+		updated, err := svc.Update("anna", id, 5, "Even better the second time.", true)
+		require.NoError(t, err)
+		assert.Equal(t, "hardcover", updated.Book.ID)
+
+		own, err := svc.GetOwn("anna", paperback)
+		require.NoError(t, err)
+		require.NotNil(t, own)
+		assert.Equal(t, 5.0, own.Rating)
+		assert.True(t, own.Spoiler)
+
+		_, err = svc.Delete("anna", id)
+		require.NoError(t, err)
+		own, err = svc.GetOwn("anna", paperback)
+		require.NoError(t, err)
+		assert.Nil(t, own)
+	})
+}
@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFilters(t *testing.T) {
+	t.Run("Matches", func(t *testing.T) {
+		book := &models.Book{Language: "pl", Pages: 320, PublishedDate: "1890-01-01"}
+
+		tests := []struct {
+			name    string
+			filters models.SearchFilters
+			want    bool
+		}{
+			{"no filters", models.SearchFilters{}, true},
+			{"same language", models.SearchFilters{Language: "pl"}, true},
+			{"other language", models.SearchFilters{Language: "en"}, false},
+			{"enough pages", models.SearchFilters{MinPages: 320}, true},
+			{"too few pages", models.SearchFilters{MinPages: 321}, false},
+			{"inside the range", models.SearchFilters{PublishedFrom: 1880, PublishedTo: 1890}, true},
+			{"before the range", models.SearchFilters{PublishedFrom: 1891}, false},
+			{"after the range", models.SearchFilters{PublishedTo: 1889}, false},
+			{"print type is left to providers", models.SearchFilters{PrintType: models.PrintTypeMagazines}, true},
+		}
+
+		for _, tt := range tests {
+			assert.Equal(t, tt.want, tt.filters.Matches(book), tt.name)
+		}
+	})
+
+	t.Run("Matches drops books missing the filtered field", func(t *testing.T) {
+		book := &models.Book{}
+
+		assert.False(t, models.SearchFilters{Language: "pl"}.Matches(book))
+		assert.False(t, models.SearchFilters{MinPages: 1}.Matches(book))
+		assert.False(t, models.SearchFilters{PublishedFrom: 1900}.Matches(book))
+	})
+
+	t.Run("Values round trips the form fields", func(t *testing.T) {
+		filters := models.SearchFilters{Language: "pl", OrderBy: models.SearchOrderNewest, PublishedTo: 2000, MinPages: 100}
+
+		assert.Equal(t, "language=pl&min-pages=100&order-by=newest&published-to=2000", filters.Values().Encode())
+		assert.Empty(t, models.SearchFilters{}.Values().Encode())
+	})
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":             "en",
+		"en-US":          "en",
+		"PL":             "pl",
+		"pol":            "pl",
+		"deu":            "de",
+		"ger":            "de",
+		"/languages/fre": "fr",
+		"tlh":            "tlh",
+		"":               "",
+	}
+
+	for code, want := range tests {
+		assert.Equal(t, want, models.NormalizeLanguage(code), code)
+	}
+	assert.Equal(t, "pol", models.MARCLanguage("pl"))
+	assert.Empty(t, models.MARCLanguage("tlh"))
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/handlers"
+	"github.com/FilipBudzynski/book_it/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	t.Run("ParseSearchQuery combines fields", func(t *testing.T) {
+		query := handlers.ParseSearchQuery(`author:Lem subject:"science fiction" solaris`, handlers.QueryTypeTitle)
+
+		assert.Equal(t, handlers.SearchQuery{
+			Title:   "solaris",
+			Author:  "Lem",
+			Subject: `"science fiction"`,
+		}, query)
+	})
+
+	t.Run("ParseSearchQuery keeps unknown prefixes as words", func(t *testing.T) {
+		query := handlers.ParseSearchQuery(`dune: messiah`, handlers.QueryTypeText)
+
+		assert.Equal(t, handlers.SearchQuery{Text: "dune: messiah"}, query)
+	})
+
+	t.Run("String parses back to the same query", func(t *testing.T) {
+		query := handlers.SearchQuery{
+			Text:   `"hard sf"`,
+			Title:  "the invincible",
+			Author: `"stanisław lem"`,
+			ISBN:   "9780262538435",
+		}
+
+		assert.Equal(t, `"hard sf" title:the title:invincible author:"stanisław lem" isbn:9780262538435`, query.String())
+		assert.Equal(t, query, handlers.ParseSearchQuery(query.String(), handlers.QueryTypeText))
+	})
+
+	t.Run("SplitTerms keeps phrases together", func(t *testing.T) {
+		assert.Equal(t, []string{"a", `"b c"`, `title:"d e"`}, handlers.SplitTerms(`  a "b c"   title:"d e"`))
+		assert.Equal(t, []string{`"unclosed phrase"`}, handlers.SplitTerms(`"unclosed phrase`))
+		assert.Empty(t, handlers.SplitTerms(` "" `))
+	})
+
+	t.Run("Words drops quotes and prefixes", func(t *testing.T) {
+		query := handlers.ParseSearchQuery(`author:"Stanisław Lem" solaris`, handlers.QueryTypeTitle)
+
+		assert.Equal(t, "solaris Stanisław Lem", query.Words())
+	})
+
+	t.Run("Google gives every word its keyword", func(t *testing.T) {
+		provider := providers.NewGoogleProvider().(interface {
+			QueryString(query handlers.SearchQuery) string
+		})
+		query := handlers.ParseSearchQuery(`dune messiah author:herbert subject:science fiction "desert planet"`, handlers.QueryTypeTitle)
+
+		assert.Equal(t, `intitle:dune intitle:messiah intitle:fiction intitle:"desert planet" inauthor:herbert subject:science`, provider.QueryString(query))
+		assert.Equal(t,
+			`inauthor:lem subject:"science fiction"`,
+			provider.QueryString(handlers.SearchQuery{Author: "lem", Subject: "science fiction"}),
+		)
+	})
+}
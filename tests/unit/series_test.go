@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/recommend"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSeries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *models.SeriesInfo
+	}{
+		{"The Witcher ; 1", &models.SeriesInfo{Name: "The Witcher", Position: 1}},
+		{"Wiedźmin (tom 2)", &models.SeriesInfo{Name: "Wiedźmin", Position: 2}},
+		{"Discworld #3", &models.SeriesInfo{Name: "Discworld", Position: 3}},
+		{"The Expanse, book 4.5", &models.SeriesInfo{Name: "The Expanse", Position: 4.5}},
+		{"Penguin Classics", nil},
+		{"#2", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, models.ParseSeries(tt.input), tt.input)
+	}
+	assert.Equal(t, "4.5", models.FormatPosition(4.5))
+	assert.Equal(t, "2", models.FormatPosition(2))
+}
+
+func TestSeriesNextVolume(t *testing.T) {
+	finished := func(id string) *models.UserBook {
+		return &models.UserBook{BookID: id, Book: models.Book{ID: id}, ReadingProgress: &models.ReadingProgress{Completed: true}}
+	}
+	series := &models.Series{Name: "The Witcher", Entries: []models.SeriesEntry{
+		{BookID: "w3", Book: models.Book{ID: "w3", Title: "Blood of Elves"}, Position: 3},
+		{BookID: "w1", Book: models.Book{ID: "w1", Title: "The Last Wish"}, Position: 1},
+		{BookID: "w2-en", Book: models.Book{ID: "w2-en", Title: "Sword of Destiny"}, Position: 2},
+		{BookID: "w2-pl", Book: models.Book{ID: "w2-pl", Title: "Miecz przeznaczenia"}, Position: 2},
+	}}
+
+	t.Run("Series not started", func(t *testing.T) {
+		assert.Nil(t, series.NextVolume([]*models.UserBook{{BookID: "w2-en", Book: models.Book{ID: "w2-en"}}}))
+	})
+
+	t.Run("The volume after the last one finished", func(t *testing.T) {
+		next := series.NextVolume([]*models.UserBook{finished("w1")})
+		require.NotNil(t, next)
+		assert.Equal(t, 2.0, next.Position)
+		assert.Equal(t, "w2-pl", next.Book.ID, "the first edition by title")
+	})
+
+	t.Run("The user's own edition is preferred", func(t *testing.T) {
+		next := series.NextVolume([]*models.UserBook{finished("w1"), {BookID: "w2-en", Book: models.Book{ID: "w2-en"}}})
+		require.NotNil(t, next)
+		assert.Equal(t, "w2-en", next.Book.ID)
+	})
+
+	t.Run("Finished series", func(t *testing.T) {
+		assert.Nil(t, series.NextVolume([]*models.UserBook{finished("w1"), finished("w2-pl"), finished("w3")}))
+	})
+}
+
+func TestSeriesRepository(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	bookRepo := repositories.NewBookRepository(db)
+	repo := repositories.NewSeriesRepository(db)
+	svc := services.NewSeriesService(repo)
+
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "w1", Title: "The Last Wish", Series: &models.SeriesInfo{Name: "The Witcher", ProviderID: "google:abc", Position: 1}}))
+	// the provider id finds the series without a name
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "w2", Title: "Sword of Destiny", Series: &models.SeriesInfo{ProviderID: "google:abc", Position: 2}}))
+	// an id alone does not create a series
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "x1", Title: "Unknown", Series: &models.SeriesInfo{ProviderID: "google:xyz", Position: 1}}))
+	require.NoError(t, bookRepo.Create(&models.Book{ID: "w3", Title: "Blood of Elves"}))
+
+	t.Run("Provider volumes share the series", func(t *testing.T) {
+		series, err := svc.GetForBooks([]string{"w2", "x1"})
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		assert.Equal(t, "The Witcher", series[0].Name)
+		require.Len(t, series[0].Entries, 2)
+		assert.Equal(t, "w1", series[0].Entries[0].BookID)
+	})
+
+	t.Run("Users add and remove their own volumes", func(t *testing.T) {
+		_, err := svc.AddBook("anna", "w3", " the  witcher ", 3)
+		require.NoError(t, err)
+		series, err := svc.GetForBooks([]string{"w3"})
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		assert.Len(t, series[0].Entries, 3, "the name matches the provider series")
+
+		assert.ErrorIs(t, svc.RemoveBook("bob", fmt.Sprint(series[0].ID), "w3"), models.ErrSeriesEntryNotFound)
+		assert.ErrorIs(t, svc.RemoveBook("anna", fmt.Sprint(series[0].ID), "w1"), models.ErrSeriesEntryNotFound, "provider volumes stay")
+		require.NoError(t, svc.RemoveBook("anna", fmt.Sprint(series[0].ID), "w3"))
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		_, err := svc.AddBook("anna", "w3", " ", 1)
+		assert.ErrorIs(t, err, models.ErrSeriesNameEmpty)
+		_, err = svc.AddBook("anna", "w3", "The Witcher", 0)
+		assert.ErrorIs(t, err, models.ErrSeriesInvalidPosition)
+	})
+
+	t.Run("Next volumes of the library", func(t *testing.T) {
+		next, err := svc.NextVolumes([]*models.UserBook{
+			{BookID: "w1", Book: models.Book{ID: "w1"}, ReadingProgress: &models.ReadingProgress{Completed: true}},
+		})
+		require.NoError(t, err)
+		require.Len(t, next, 1)
+		assert.Equal(t, "w2", next[0].Book.ID)
+	})
+}
+
+func TestRecommendContinueSeries(t *testing.T) {
+	series := &models.Series{Name: "The Witcher"}
+	library := []*models.UserBook{{BookID: "owned", Book: models.Book{ID: "owned"}}}
+	ranked := []*models.Recommendation{
+		{Book: &models.Book{ID: "dune"}, Score: 0.9},
+		{Book: &models.Book{ID: "w2"}, Score: 0.4},
+	}
+	next := []*models.NextVolume{
+		{Series: series, Book: &models.Book{ID: "w2"}, Position: 2},
+		{Series: series, Book: &models.Book{ID: "owned"}, Position: 5},
+	}
+
+	result := recommend.ContinueSeries(ranked, library, next)
+	require.Len(t, result, 2)
+	assert.Equal(t, "w2", result[0].Book.ID)
+	assert.Equal(t, models.ReasonSeries, result[0].Reason.Kind)
+	assert.Equal(t, "Next in The Witcher (#2)", result[0].Reason.String())
+	assert.Equal(t, "dune", result[1].Book.ID)
+}
@@ -24,6 +24,11 @@ func (m *MockUserBookRepository) Get(id string) (*models.UserBook, error) {
 	return args.Get(0).(*models.UserBook), args.Error(1)
 }
 
+func (m *MockUserBookRepository) GetByUserAndBook(userId, bookId string) (*models.UserBook, error) {
+	args := m.Called(userId, bookId)
+	return args.Get(0).(*models.UserBook), args.Error(1)
+}
+
 func (m *MockUserBookRepository) GetAllUserBooks(userId string) ([]*models.UserBook, error) {
 	args := m.Called(userId)
 	return args.Get(0).([]*models.UserBook), args.Error(1)
@@ -39,8 +44,17 @@ func (m *MockUserBookRepository) DeleteWhereBookId(bookId string) error {
 	return args.Error(0)
 }
 
-func (m *MockUserBookRepository) Search(userId, query string) ([]*models.UserBook, error) {
-	args := m.Called(userId, query)
+func (m *MockUserBookRepository) Search(userId, query string, status models.UserBookStatus) ([]*models.UserBook, error) {
+	args := m.Called(userId, query, status)
 	return args.Get(0).([]*models.UserBook), args.Error(1)
 }
 
+func (m *MockUserBookRepository) SetStatus(id uint, status models.UserBookStatus) (*models.UserBook, error) {
+	args := m.Called(id, status)
+	return args.Get(0).(*models.UserBook), args.Error(1)
+}
+
+func (m *MockUserBookRepository) FindInBatches(userId string, batchSize int, fn func(userBooks []*models.UserBook) error) error {
+	args := m.Called(userId, batchSize, fn)
+	return args.Error(0)
+}
@@ -54,9 +54,17 @@ func TestUserBookRepository(t *testing.T) {
 	})
 
 	t.Run("Search UserBooks", func(t *testing.T) {
-		results, err := repo.Search("user123", "Test")
+		results, err := repo.Search("user123", "Test", "")
 		assert.NoError(t, err)
 		assert.Len(t, results, 1)
+
+		results, err = repo.Search("user123", "Test", models.UserBookStatusWantToRead)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		results, err = repo.Search("user123", "Test", models.UserBookStatusRead)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
 	})
 }
 
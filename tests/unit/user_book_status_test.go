@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/FilipBudzynski/book_it/internal/repositories"
+	"github.com/FilipBudzynski/book_it/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserBookStatus(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		status, err := models.ParseUserBookStatus("did_not_finish")
+		assert.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusDidNotFinish, status)
+
+		status, err = models.ParseUserBookStatus("")
+		assert.NoError(t, err)
+		assert.Empty(t, status)
+
+		_, err = models.ParseUserBookStatus("abandoned")
+		assert.ErrorIs(t, err, models.ErrUserBookUnknownStatus)
+	})
+
+	t.Run("SetStatus records the dates", func(t *testing.T) {
+		started := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		finished := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+		userBook := &models.UserBook{Status: models.UserBookStatusWantToRead}
+
+		assert.True(t, userBook.SetStatus(models.UserBookStatusReading, started))
+		assert.False(t, userBook.SetStatus(models.UserBookStatusReading, finished))
+		assert.True(t, userBook.SetStatus(models.UserBookStatusRead, finished))
+
+		assert.Equal(t, started, *userBook.StartedAt)
+		assert.Equal(t, finished, *userBook.FinishedAt)
+		assert.Equal(t, finished, *userBook.StatusChangedAt)
+		require.Len(t, userBook.StatusChanges, 2)
+		assert.Equal(t, models.UserBookStatusWantToRead, userBook.StatusChanges[0].From)
+		assert.Equal(t, models.UserBookStatusRead, userBook.StatusChanges[1].To)
+		assert.True(t, userBook.IsRead())
+	})
+
+	t.Run("String is the stored code, Label the shown name", func(t *testing.T) {
+		assert.Equal(t, "did_not_finish", models.UserBookStatusDidNotFinish.String())
+		assert.Equal(t, "Did not finish", models.UserBookStatusDidNotFinish.Label())
+		assert.Equal(t, "All", models.UserBookStatus("").Label())
+	})
+
+	t.Run("FilterByStatus", func(t *testing.T) {
+		userBooks := []*models.UserBook{
+			{Status: models.UserBookStatusReading},
+			{Status: models.UserBookStatusOnHold},
+			{Status: models.UserBookStatusReading},
+		}
+		assert.Len(t, models.FilterByStatus(userBooks, models.UserBookStatusReading), 2)
+		assert.Len(t, models.FilterByStatus(userBooks, models.UserBookStatusRead), 0)
+		assert.Len(t, models.FilterByStatus(userBooks, ""), 3)
+	})
+}
+
+func TestUserBookStatusShelves(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := repositories.NewUserBookRepository(db)
+	progressRepo := repositories.NewProgressRepository(db)
+	_, _, userBook := seedTestData(t, db, repo)
+
+	t.Run("New books want to be read", func(t *testing.T) {
+		got, err := repo.Get(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusWantToRead, got.Status)
+		assert.NotNil(t, got.StatusChangedAt)
+
+		var changes int64
+		db.Model(&models.UserBookStatusChange{}).Where("user_book_id = ?", userBook.ID).Count(&changes)
+		assert.Equal(t, int64(1), changes)
+	})
+
+	progress := models.ReadingProgress{
+		UserBookID: userBook.ID,
+		BookTitle:  "Test Book",
+		StartDate:  time.Now(),
+		EndDate:    time.Now().AddDate(0, 0, 10),
+		TotalPages: 100,
+	}
+
+	t.Run("Creating progress moves the book to reading", func(t *testing.T) {
+		require.NoError(t, progressRepo.Create(progress))
+
+		got, err := repo.Get(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusReading, got.Status)
+		assert.NotNil(t, got.StartedAt)
+		assert.Nil(t, got.FinishedAt)
+	})
+
+	t.Run("Completing progress moves the book to read", func(t *testing.T) {
+		saved, err := progressRepo.GetByUserBookId(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		saved.CurrentPage = saved.TotalPages
+		require.NoError(t, progressRepo.Update(saved))
+
+		got, err := repo.Get(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusRead, got.Status)
+		assert.NotNil(t, got.FinishedAt)
+
+		changes := []models.UserBookStatusChange{}
+		db.Where("user_book_id = ?", userBook.ID).Order("id").Find(&changes)
+		require.Len(t, changes, 3)
+		assert.Equal(t, models.UserBookStatusReading, changes[2].From)
+		assert.Equal(t, models.UserBookStatusRead, changes[2].To)
+	})
+
+	t.Run("Saving completed progress again keeps the shelf", func(t *testing.T) {
+		_, err := repo.SetStatus(userBook.ID, models.UserBookStatusDidNotFinish)
+		require.NoError(t, err)
+
+		saved, err := progressRepo.GetByUserBookId(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		assert.True(t, saved.Completed)
+		saved.BookTitle = "Renamed"
+		require.NoError(t, progressRepo.Update(saved))
+
+		got, err := repo.Get(fmt.Sprintf("%d", userBook.ID))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusDidNotFinish, got.Status)
+	})
+
+	t.Run("SetStatus", func(t *testing.T) {
+		got, err := repo.SetStatus(userBook.ID, models.UserBookStatusDidNotFinish)
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusDidNotFinish, got.Status)
+
+		results, err := repo.Search("user123", "", models.UserBookStatusDidNotFinish)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, userBook.ID, results[0].ID)
+	})
+
+	t.Run("Books without a status get one from their progress", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Book{ID: "old", Title: "Old Book"}).Error)
+		require.NoError(t, db.Exec(`INSERT INTO user_books (created_at, updated_at, user_google_id, book_id, status)
+			VALUES (?, ?, 'user123', 'old', '')`, time.Now(), time.Now()).Error)
+		old := &models.UserBook{}
+		require.NoError(t, db.Where("book_id = ?", "old").First(old).Error)
+		require.NoError(t, db.Exec(`INSERT INTO reading_progresses (created_at, updated_at, user_book_id, start_date, end_date, total_pages, current_page, completed)
+			VALUES (?, ?, ?, ?, ?, 100, 40, false)`, time.Now(), time.Now(), old.ID, time.Now(), time.Now()).Error)
+
+		require.NoError(t, repositories.Migrate(db))
+
+		got, err := repo.Get(fmt.Sprintf("%d", old.ID))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserBookStatusReading, got.Status)
+		assert.NotNil(t, got.StartedAt)
+	})
+}
+
+func TestUserBookServiceSetStatus(t *testing.T) {
+	repo := new(MockUserBookRepository)
+	service := services.NewUserBookService(repo, nil)
+	userBook := &models.UserBook{UserGoogleId: "owner", Status: models.UserBookStatusReading}
+	userBook.ID = 7
+	repo.On("Get", "7").Return(userBook, nil)
+
+	_, err := service.SetStatus("someone else", "7", models.UserBookStatusOnHold)
+	assert.ErrorIs(t, err, models.ErrUserBookNotOwner)
+
+	_, err = service.SetStatus("owner", "7", "")
+	assert.ErrorIs(t, err, models.ErrUserBookUnknownStatus)
+
+	repo.On("SetStatus", uint(7), models.UserBookStatusOnHold).
+		Return(&models.UserBook{Status: models.UserBookStatusOnHold}, nil)
+	got, err := service.SetStatus("owner", "7", models.UserBookStatusOnHold)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserBookStatusOnHold, got.Status)
+	repo.AssertExpectations(t)
+}
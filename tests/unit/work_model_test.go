@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FilipBudzynski/book_it/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkMatchKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  [2]string
+		second [2]string
+		same   bool
+	}{
+		{
+			name:   "Subtitle is ignored",
+			first:  [2]string{"Dune", "Frank Herbert"},
+			second: [2]string{"Dune: Deluxe Edition", "Frank Herbert"},
+			same:   true,
+		},
+		{
+			name:   "Inverted author name",
+			first:  [2]string{"Dune", "Frank Herbert"},
+			second: [2]string{"Dune", "Herbert, Frank"},
+			same:   true,
+		},
+		{
+			name:   "Case, diacritics and article",
+			first:  [2]string{"The Cyberiad", "Stanisław Lem"},
+			second: [2]string{"CYBERIAD", "Stanislaw Lem"},
+			same:   true,
+		},
+		{
+			name:   "Co-authors are ignored",
+			first:  [2]string{"Good Omens", "Terry Pratchett, Neil Gaiman"},
+			second: [2]string{"Good Omens", "Terry Pratchett"},
+			same:   true,
+		},
+		{
+			name:   "Different title",
+			first:  [2]string{"Dune", "Frank Herbert"},
+			second: [2]string{"Dune Messiah", "Frank Herbert"},
+			same:   false,
+		},
+		{
+			name:   "Different author",
+			first:  [2]string{"Dune", "Frank Herbert"},
+			second: [2]string{"Dune", "Brian Herbert"},
+			same:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := models.WorkMatchKey(tt.first[0], tt.first[1])
+			second := models.WorkMatchKey(tt.second[0], tt.second[1])
+			assert.Equal(t, tt.same, first == second, "%q vs %q", first, second)
+		})
+	}
+}
+
+func TestDedupeByWork(t *testing.T) {
+	one, two := uint(1), uint(2)
+	books := []*models.Book{
+		{ID: "a", WorkID: &one},
+		{ID: "b", WorkID: &two},
+		{ID: "c", WorkID: &one},
+		{ID: "d"},
+		{ID: "e"},
+	}
+
+	deduped := models.DedupeByWork(books)
+
+	ids := []string{}
+	for _, book := range deduped {
+		ids = append(ids, book.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "d", "e"}, ids)
+}